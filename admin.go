@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/neira-daniel/go-chirpy/internal/auth"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+)
+
+// errLastAdmin rolls back a role change that would leave no admin.
+var errLastAdmin = errors.New("the last admin can't lose the admin role")
+
+// accessTokenKey is the key under which middlewareRequireRole stores the
+// access token of a request in its context.
+type accessTokenKey struct{}
+
+// middlewareRequireRole only lets through requests carrying the access token
+// of a login whose user has the given role, or one that includes it. Tokens
+// limited to scopes, like personal access tokens, have no role.
+func (cfg *apiConfig) middlewareRequireRole(role auth.Role, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accessToken, ok := cfg.authenticate(w, r, loginOnly)
+		if !ok {
+			return
+		}
+		if !accessToken.Role.Includes(role) {
+			log.Printf("%v user %q without the %v role tried to reach %v", securityTag, accessToken.UserID, role, r.URL.Path)
+			respondWithError(w, http.StatusForbidden, "unauthorized action")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), accessTokenKey{}, accessToken)))
+	})
+}
+
+// accessTokenFromContext returns the access token that middlewareRequireRole
+// checked for the request.
+func accessTokenFromContext(ctx context.Context) auth.AccessToken {
+	accessToken, _ := ctx.Value(accessTokenKey{}).(auth.AccessToken)
+	return accessToken
+}
+
+func (cfg *apiConfig) handlerSetUserRole(w http.ResponseWriter, r *http.Request) {
+	admin := accessTokenFromContext(r.Context())
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "request error: not a valid user UUID")
+		return
+	}
+
+	type payload struct {
+		Role string `json:"role"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	var data payload
+	if err := decoder.Decode(&data); err != nil {
+		log.Print(fmt.Errorf("%v decoding non-conforming JSON request: %w", errorTag, err))
+		respondWithError(w, http.StatusBadRequest, "non-conforming JSON received")
+		return
+	}
+	role, err := auth.ParseRole(data.Role)
+	if err != nil {
+		respondWithFieldErrors(w, http.StatusBadRequest, "request error: invalid role", map[string][]string{
+			"role": {err.Error()},
+		})
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "user doesn't exist")
+		return
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting user %q: %w", errorTag, userID, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve user")
+		return
+	}
+	if auth.Role(user.Role) == role {
+		respondWithJSON(w, http.StatusOK, addTagsToUser(user, "", ""))
+		return
+	}
+
+	// the admins are locked while the role changes, so that two of them
+	// demoting each other at once can't leave none
+	err = cfg.withTx(r.Context(), func(qtx *database.Queries) error {
+		admins, err := qtx.LockAdmins(r.Context())
+		if err != nil {
+			return fmt.Errorf("locking admins: %w", err)
+		}
+		if role != auth.RoleAdmin && slices.Contains(admins, userID) && len(admins) <= 1 {
+			return errLastAdmin
+		}
+		// the access tokens of logins carry the old role, so they stop
+		// working, while personal access tokens and OAuth grants, which have
+		// no role, are left alone
+		user, err = qtx.SetUserRole(r.Context(), database.SetUserRoleParams{
+			Role: string(role),
+			ID:   userID,
+		})
+		if err != nil {
+			return fmt.Errorf("setting role: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, errLastAdmin) {
+		respondWithError(w, http.StatusConflict, "the last admin can't lose the admin role")
+		return
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v setting role of user %q: %w", errorTag, userID, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't set role")
+		return
+	}
+
+	log.Printf("%v user %q made a %v by administrator %q", securityTag, userID, role, admin.UserID)
+	respondWithJSON(w, http.StatusOK, addTagsToUser(user, "", ""))
+}
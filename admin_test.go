@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neira-daniel/go-chirpy/internal/auth"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+)
+
+func TestSetUserRoleRevokesLoginTokensOnly(t *testing.T) {
+	cfg := testDB(t)
+	key, err := auth.NewHMACKey("test", []byte("test secret"))
+	if err != nil {
+		t.Fatalf("creating key: %v", err)
+	}
+	keys, err := auth.NewKeySet(key)
+	if err != nil {
+		t.Fatalf("creating key set: %v", err)
+	}
+	cfg.signingKeys.Store(keys)
+	cfg.validator = auth.DefaultValidator()
+
+	admin := testUser(t, cfg, "admin@example.com")
+	if _, err := cfg.db.SetUserRole(t.Context(), database.SetUserRoleParams{
+		Role: string(auth.RoleAdmin),
+		ID:   admin.ID,
+	}); err != nil {
+		t.Fatalf("making admin: %v", err)
+	}
+	user := testUser(t, cfg, "user@example.com")
+
+	loginToken, err := keys.MakeJWT(auth.AccessToken{
+		UserID:       user.ID,
+		TokenVersion: user.TokenVersion,
+		Role:         auth.RoleUser,
+	}, time.Hour)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	personalToken := auth.MakePersonalAccessToken()
+	if _, err := cfg.db.CreatePersonalAccessToken(t.Context(), database.CreatePersonalAccessTokenParams{
+		Name:      "test",
+		TokenHash: auth.HashRefreshToken(personalToken),
+		Scopes:    []string{string(auth.ScopeChirpsRead)},
+		UserID:    user.ID,
+	}); err != nil {
+		t.Fatalf("creating personal access token: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPut, "/admin/users/"+user.ID.String()+"/role", strings.NewReader(`{"role": "moderator"}`))
+	r.SetPathValue("userID", user.ID.String())
+	r = r.WithContext(context.WithValue(r.Context(), accessTokenKey{}, auth.AccessToken{UserID: admin.ID, Role: auth.RoleAdmin}))
+	w := httptest.NewRecorder()
+	cfg.handlerSetUserRole(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got code %d when setting role: %v", w.Code, w.Body)
+	}
+
+	if _, err := cfg.validateAccessToken(t.Context(), loginToken); !errors.Is(err, auth.ErrTokenRevoked) {
+		t.Errorf("got %v when validating the login token with the old role, expecting %v", err, auth.ErrTokenRevoked)
+	}
+	if _, err := cfg.validateAccessToken(t.Context(), personalToken); err != nil {
+		t.Errorf("got %v when validating the personal access token", err)
+	}
+	newToken, err := keys.MakeJWT(auth.AccessToken{
+		UserID:       user.ID,
+		TokenVersion: user.TokenVersion,
+		Role:         auth.RoleModerator,
+	}, time.Hour)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	if _, err := cfg.validateAccessToken(t.Context(), newToken); err != nil {
+		t.Errorf("got %v when validating a login token with the new role", err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/neira-daniel/go-chirpy/internal/auth"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+)
+
+// runCommand runs one of the administration commands the server binary takes
+// instead of serving requests.
+func runCommand(ctx context.Context, db *database.Queries, args []string) error {
+	switch args[0] {
+	case "create-admin":
+		return createAdmin(ctx, db, args[1:], os.Stdin)
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// createAdmin gives the admin role to the first admin, creating their account
+// when there's none with the given email. The password of a new account is
+// read from the first line of stdin. Once there's an admin, roles are given
+// with PUT /admin/users/{userID}/role instead.
+func createAdmin(ctx context.Context, db *database.Queries, args []string, stdin io.Reader) error {
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	email := flags.String("email", "", "email of the account to make an admin")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if !isBareEmail(*email) {
+		return errors.New("-email must be a valid address")
+	}
+
+	admins, err := db.CountAdmins(ctx)
+	if err != nil {
+		return fmt.Errorf("counting admins: %w", err)
+	}
+	if admins > 0 {
+		return errors.New("there's an admin already: give roles with PUT /admin/users/{userID}/role")
+	}
+
+	user, err := db.GetUserByEmail(ctx, *email)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		user, err = createAdminAccount(ctx, db, *email, stdin)
+		if err != nil {
+			return err
+		}
+	case err != nil:
+		return fmt.Errorf("getting user: %w", err)
+	}
+
+	if _, err := db.SetUserRole(ctx, database.SetUserRoleParams{
+		Role: string(auth.RoleAdmin),
+		ID:   user.ID,
+	}); err != nil {
+		return fmt.Errorf("setting role: %w", err)
+	}
+	log.Printf("%v user %q is now an admin", securityTag, user.Email)
+	return nil
+}
+
+// createAdminAccount registers the account of the first admin, with a password
+// read from stdin that must meet the password policy like any other.
+func createAdminAccount(ctx context.Context, db *database.Queries, email string, stdin io.Reader) (database.User, error) {
+	fmt.Fprintf(os.Stderr, "no account uses %v: type a password to create it\n", email)
+	password, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return database.User{}, fmt.Errorf("reading password: %w", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+
+	policy, err := loadPasswordPolicy()
+	if err != nil {
+		return database.User{}, fmt.Errorf("loading password policy: %w", err)
+	}
+	problems, err := policy.Check(password, email)
+	if err != nil {
+		return database.User{}, fmt.Errorf("checking password against the policy: %w", err)
+	}
+	if len(problems) > 0 {
+		return database.User{}, fmt.Errorf("password rejected: %v", strings.Join(problems, "; "))
+	}
+	passwords, err := loadPasswords()
+	if err != nil {
+		return database.User{}, fmt.Errorf("loading password hasher: %w", err)
+	}
+	hashedPassword, err := passwords.Hash(password)
+	if err != nil {
+		return database.User{}, fmt.Errorf("hashing password: %w", err)
+	}
+
+	user, err := db.CreateUser(ctx, database.CreateUserParams{
+		Email:          email,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		return database.User{}, fmt.Errorf("creating user: %w", err)
+	}
+	log.Printf("%v user %q created", successTag, user.Email)
+	return user, nil
+}
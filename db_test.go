@@ -0,0 +1,82 @@
+package main
+
+import (
+	"database/sql"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+)
+
+// testDB returns a configuration backed by the Postgres server in TEST_DB_URL,
+// on a schema of its own that is migrated up and dropped when the test ends.
+// Tests using it are skipped when TEST_DB_URL isn't set.
+func testDB(t *testing.T) *apiConfig {
+	t.Helper()
+	dbURL, ok := os.LookupEnv("TEST_DB_URL")
+	if !ok {
+		t.Skip("TEST_DB_URL isn't set")
+	}
+
+	admin, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("creating schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("dropping schema: %v", err)
+		}
+	})
+
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		t.Fatalf("parsing TEST_DB_URL: %v", err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	conn, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	migrations, err := filepath.Glob(filepath.Join("sql", "schema", "*.sql"))
+	if err != nil {
+		t.Fatalf("listing migrations: %v", err)
+	}
+	for _, migration := range migrations {
+		data, err := os.ReadFile(migration)
+		if err != nil {
+			t.Fatalf("reading migration: %v", err)
+		}
+		up, _, _ := strings.Cut(string(data), "-- +goose Down")
+		if _, err := conn.Exec(up); err != nil {
+			t.Fatalf("running %v: %v", migration, err)
+		}
+	}
+
+	return &apiConfig{db: database.New(conn), conn: conn}
+}
+
+// testUser registers a user with the given email.
+func testUser(t *testing.T, cfg *apiConfig, email string) database.User {
+	t.Helper()
+	user, err := cfg.db.CreateUser(t.Context(), database.CreateUserParams{
+		Email:          email,
+		HashedPassword: "unused",
+	})
+	if err != nil {
+		t.Fatalf("creating user %v: %v", email, err)
+	}
+	return user
+}
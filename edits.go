@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neira-daniel/go-chirpy/internal/auth"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+	"github.com/neira-daniel/go-chirpy/internal/moderation"
+)
+
+// editWindow is how long after posting a chirp its author can still edit it.
+// Chirpy Red members can edit their chirps at any time.
+const editWindow = time.Hour
+
+// ChirpVersion is one of the bodies a chirp had. CreatedAt is when the body
+// was written, be it when the chirp was posted or when it was edited.
+type ChirpVersion struct {
+	Version   int32     `json:"version"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ChirpHistory struct {
+	ChirpID  uuid.UUID      `json:"chirp_id"`
+	Versions []ChirpVersion `json:"versions"`
+}
+
+func (cfg *apiConfig) handlerEditChirp(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+	if !cfg.canPost(w, r, userID) {
+		return
+	}
+	chirp, ok := cfg.chirpFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if userID != chirp.UserID {
+		log.Printf("%v user tried to edit chirp from another user", warningTag)
+		respondWithError(w, http.StatusForbidden, "unauthorized action")
+		return
+	}
+	if chirp.RechirpOf.Valid && !chirp.IsQuote {
+		respondWithError(w, http.StatusBadRequest, "request error: plain rechirps can't be edited")
+		return
+	}
+
+	type jsonRequest struct {
+		Body string `json:"body"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	var data jsonRequest
+	if err := decoder.Decode(&data); err != nil {
+		log.Print(fmt.Errorf("%v decoding non-conforming JSON request: %w", errorTag, err))
+		respondWithError(w, http.StatusBadRequest, "non-conforming JSON received")
+		return
+	}
+
+	// plain rechirps are the only chirps without a body, so an empty one
+	// would make a quote chirp look like one
+	if data.Body == "" {
+		respondWithError(w, http.StatusBadRequest, "request error: body is required")
+		return
+	}
+	if err := validateChirp(data.Body); err != nil {
+		log.Printf("%v invalid chirp", warningTag)
+		respondWithError(w, http.StatusBadRequest, "invalid chirp")
+		return
+	}
+	moderated, ok := cfg.moderateChirp(w, data.Body)
+	if !ok {
+		return
+	}
+
+	if time.Since(chirp.CreatedAt) > editWindow {
+		user, err := cfg.db.GetUserByID(r.Context(), userID)
+		if err != nil {
+			log.Print(fmt.Errorf("%v getting user %q from the database: %w", errorTag, userID, err))
+			respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve user")
+			return
+		}
+		if !user.IsChirpyRed {
+			respondWithError(w, http.StatusForbidden, fmt.Sprintf("chirps can only be edited within %v of posting them", editWindow))
+			return
+		}
+	}
+
+	// an edit that doesn't change anything isn't worth a new version
+	if moderated.Text != chirp.Body {
+		err := cfg.withTx(r.Context(), func(qtx *database.Queries) error {
+			// the lock keeps concurrent edits from taking the same version number
+			current, err := qtx.GetChirpByIDForUpdate(r.Context(), chirp.ID)
+			if err != nil {
+				return fmt.Errorf("locking chirp: %w", err)
+			}
+			writtenAt := current.CreatedAt
+			if current.EditedAt.Valid {
+				writtenAt = current.EditedAt.Time
+			}
+			if err := qtx.SaveChirpRevision(r.Context(), database.SaveChirpRevisionParams{
+				ChirpID:   current.ID,
+				Body:      current.Body,
+				CreatedAt: writtenAt,
+			}); err != nil {
+				return fmt.Errorf("saving revision: %w", err)
+			}
+
+			chirp, err = qtx.EditChirp(r.Context(), database.EditChirpParams{
+				Body: moderated.Text,
+				ID:   current.ID,
+			})
+			if err != nil {
+				return fmt.Errorf("editing chirp: %w", err)
+			}
+
+			if err := flagChirp(r.Context(), qtx, chirp.ID, moderated.Words(moderation.ActionFlag)); err != nil {
+				return err
+			}
+
+			// hashtags and mentions are indexed again from the new body
+			if err := qtx.UntagChirp(r.Context(), chirp.ID); err != nil {
+				return fmt.Errorf("deleting hashtags: %w", err)
+			}
+			if err := qtx.DeleteMentions(r.Context(), chirp.ID); err != nil {
+				return fmt.Errorf("deleting mentions: %w", err)
+			}
+			return indexChirp(r.Context(), qtx, chirp)
+		})
+		if err != nil {
+			log.Print(fmt.Errorf("%v editing chirp in the database: %w", errorTag, err))
+			respondWithError(w, http.StatusInternalServerError, "database error: couldn't edit chirp")
+			return
+		}
+	}
+
+	chirpsWithTags, err := cfg.presentChirps(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, []database.Chirp{chirp})
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting edited chirp details: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve chirp")
+		return
+	}
+
+	log.Printf("%v chirp %q edited", successTag, chirp.ID)
+	respondWithJSON(w, http.StatusOK, chirpsWithTags[0])
+}
+
+func (cfg *apiConfig) handlerGETChirpHistory(w http.ResponseWriter, r *http.Request) {
+	chirp, ok := cfg.chirpFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := cfg.db.GetChirpRevisions(r.Context(), chirp.ID)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting revisions of chirp %q from the database: %w", errorTag, chirp.ID, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve chirp history")
+		return
+	}
+
+	versions := make([]ChirpVersion, 0, len(revisions)+1)
+	for _, revision := range revisions {
+		versions = append(versions, ChirpVersion{
+			Version:   revision.Version,
+			Body:      revision.Body,
+			CreatedAt: revision.CreatedAt,
+		})
+	}
+	// the body the chirp has now is its latest version
+	current := ChirpVersion{
+		Version:   int32(len(revisions) + 1),
+		Body:      chirp.Body,
+		CreatedAt: chirp.CreatedAt,
+	}
+	if chirp.EditedAt.Valid {
+		current.CreatedAt = chirp.EditedAt.Time
+	}
+	versions = append(versions, current)
+
+	respondWithJSON(w, http.StatusOK, ChirpHistory{ChirpID: chirp.ID, Versions: versions})
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neira-daniel/go-chirpy/internal/auth"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+	"github.com/neira-daniel/go-chirpy/internal/mail"
+)
+
+const (
+	// maxEmailLength is the longest email address SMTP can deliver to
+	maxEmailLength = 254
+	// emailVerificationDuration is how long email verification links work for
+	emailVerificationDuration = 24 * time.Hour
+	// emailVerificationLimit is how many verification emails a user can get
+	// per hour
+	emailVerificationLimit = 3
+)
+
+// isBareEmail reports whether an email is a bare address, like
+// user@example.com, that SMTP can deliver to.
+func isBareEmail(email string) bool {
+	address, err := netmail.ParseAddress(email)
+	return err == nil && address.Name == "" && address.Address == email && len(email) <= maxEmailLength
+}
+
+// parseEmail checks that an email is a bare address, like user@example.com.
+// When it isn't, it responds to the client itself and returns false.
+func parseEmail(w http.ResponseWriter, email string) (string, bool) {
+	if !isBareEmail(email) {
+		respondWithFieldErrors(w, http.StatusBadRequest, "request error: email isn't a valid address", map[string][]string{
+			"email": {"isn't a valid address"},
+		})
+		return "", false
+	}
+	return email, true
+}
+
+// startEmailVerification stores a token that proves the user owns the email
+// once they follow the link we send them. The email must be sent with
+// sendVerificationEmail after the transaction of qtx is committed.
+func startEmailVerification(ctx context.Context, qtx *database.Queries, userID uuid.UUID, email string) (string, error) {
+	token, _ := auth.MakeRefreshToken()
+	if err := qtx.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		TokenHash: auth.HashRefreshToken(token),
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: time.Now().UTC().Add(emailVerificationDuration),
+	}); err != nil {
+		return "", fmt.Errorf("storing email verification token: %w", err)
+	}
+	return token, nil
+}
+
+func (cfg *apiConfig) sendVerificationEmail(email, token string) {
+	link := fmt.Sprintf("%s/app/verify-email?token=%s", cfg.baseURL, url.QueryEscape(token))
+	cfg.sendEmail(mail.Message{
+		To:      email,
+		Subject: "Verify your email for Chirpy",
+		Body: fmt.Sprintf("To confirm this is the email of your Chirpy account, follow this link within the next %v:\n\n%s\n\nIf you don't have a Chirpy account, you can ignore this email.\n",
+			emailVerificationDuration, link),
+	})
+}
+
+// canSendVerificationEmail tells whether the user may get another verification
+// email under emailVerificationLimit. When they can't, it responds to the
+// client itself and returns false.
+func (cfg *apiConfig) canSendVerificationEmail(w http.ResponseWriter, r *http.Request, userID uuid.UUID) bool {
+	sent, err := cfg.db.CountEmailVerificationTokensSince(r.Context(), database.CountEmailVerificationTokensSinceParams{
+		UserID: userID,
+		Since:  time.Now().UTC().Add(-time.Hour),
+	})
+	if err != nil {
+		log.Print(fmt.Errorf("%v counting email verification tokens: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't count email verification tokens")
+		return false
+	}
+	if sent >= emailVerificationLimit {
+		log.Printf("%v too many verification emails requested by user %q", securityTag, userID)
+		respondWithError(w, http.StatusTooManyRequests, "too many verification emails sent, try again later")
+		return false
+	}
+	return true
+}
+
+// canPost tells whether the user may post chirps under the email verification
+// policy. When they can't, it responds to the client itself and returns false.
+func (cfg *apiConfig) canPost(w http.ResponseWriter, r *http.Request, userID uuid.UUID) bool {
+	if !cfg.verifyToPost {
+		return true
+	}
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting user from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve user")
+		return false
+	}
+	if !user.EmailVerifiedAt.Valid {
+		log.Printf("%v user %q tried to post without a verified email", warningTag, userID)
+		respondWithError(w, http.StatusForbidden, "verify your email before posting")
+		return false
+	}
+	return true
+}
+
+func (cfg *apiConfig) handlerRequestEmailVerification(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r, auth.ScopeAccountWrite)
+	if !ok {
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting user from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve user")
+		return
+	}
+	// a pending change is what's left to verify, even when the current email
+	// was never verified
+	var email string
+	switch {
+	case user.PendingEmail.Valid:
+		email = user.PendingEmail.String
+	case !user.EmailVerifiedAt.Valid:
+		email = user.Email
+	default:
+		respondWithError(w, http.StatusConflict, "email is already verified")
+		return
+	}
+
+	if !cfg.canSendVerificationEmail(w, r, userID) {
+		return
+	}
+
+	token, err := startEmailVerification(r.Context(), cfg.db, userID, email)
+	if err != nil {
+		log.Print(fmt.Errorf("%v %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't store email verification token")
+		return
+	}
+	cfg.sendVerificationEmail(email, token)
+
+	log.Printf("%v verification email requested by user %q", successTag, userID)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (cfg *apiConfig) handlerConfirmEmailVerification(w http.ResponseWriter, r *http.Request) {
+	type payload struct {
+		Token string `json:"token"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	var data payload
+	if err := decoder.Decode(&data); err != nil {
+		log.Print(fmt.Errorf("%v decoding non-conforming JSON request: %w", errorTag, err))
+		respondWithError(w, http.StatusBadRequest, "non-conforming JSON received")
+		return
+	}
+	if data.Token == "" {
+		respondWithError(w, http.StatusBadRequest, "request error: token is required")
+		return
+	}
+
+	var user database.User
+	var previousEmail string
+	var invalidToken bool
+	err := cfg.withTx(r.Context(), func(qtx *database.Queries) error {
+		verification, err := qtx.UseEmailVerificationToken(r.Context(), auth.HashRefreshToken(data.Token))
+		if errors.Is(err, sql.ErrNoRows) {
+			invalidToken = true
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("using email verification token: %w", err)
+		}
+
+		current, err := qtx.GetUserByID(r.Context(), verification.UserID)
+		if err != nil {
+			return fmt.Errorf("getting user: %w", err)
+		}
+		// tokens sent to an email the user no longer has, or no longer
+		// wants to change to, are stale
+		if verification.Email != current.Email && verification.Email != current.PendingEmail.String {
+			invalidToken = true
+			return nil
+		}
+		previousEmail = current.Email
+
+		user, err = qtx.VerifyEmail(r.Context(), database.VerifyEmailParams{
+			Email: verification.Email,
+			ID:    verification.UserID,
+		})
+		return err
+	})
+	if isUniqueViolation(err, "users_email_key") {
+		respondWithError(w, http.StatusConflict, "email already taken")
+		return
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v verifying email: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't verify email")
+		return
+	}
+	if invalidToken {
+		log.Printf("%v got invalid email verification token", warningTag)
+		respondWithError(w, http.StatusBadRequest, "request error: invalid or expired email verification token")
+		return
+	}
+
+	if previousEmail != user.Email {
+		cfg.sendEmail(mail.Message{
+			To:      previousEmail,
+			Subject: "The email of your Chirpy account changed",
+			Body:    fmt.Sprintf("The email of your Chirpy account was changed to %s.\n\nIf it wasn't you, reset your password right away.\n", user.Email),
+		})
+		log.Printf("%v email of user %q changed", securityTag, user.ID)
+	}
+	log.Printf("%v email of user %q verified", successTag, user.ID)
+	respondWithJSON(w, http.StatusOK, addTagsToUser(user, "", ""))
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neira-daniel/go-chirpy/internal/auth"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+)
+
+type Follow struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"followed_at"`
+}
+
+type FollowsPage struct {
+	Users      []Follow   `json:"users"`
+	Pagination Pagination `json:"pagination"`
+}
+
+func followCursor(follow Follow) pageCursor {
+	return pageCursor{CreatedAt: follow.CreatedAt, ID: follow.UserID}
+}
+
+// userFromPath returns the ID of the registered user named in the URL. When
+// that's not possible, it responds to the client itself and returns false.
+func (cfg *apiConfig) userFromPath(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	match := r.PathValue("userID")
+	if match == "" {
+		respondWithError(w, http.StatusBadRequest, "request error: missing user ID")
+		return uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(match)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "request error: not a valid user UUID")
+		return uuid.Nil, false
+	}
+
+	if _, err := cfg.db.GetUserByID(r.Context(), userID); err != nil {
+		log.Print(fmt.Errorf("%v user id=%q not found: %w", warningTag, userID, err))
+		respondWithError(w, http.StatusNotFound, "user doesn't exist")
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+func (cfg *apiConfig) handlerFollowUser(w http.ResponseWriter, r *http.Request) {
+	followerID, ok := cfg.authenticateUser(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+	followeeID, ok := cfg.userFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if followerID == followeeID {
+		respondWithError(w, http.StatusBadRequest, "request error: users can't follow themselves")
+		return
+	}
+
+	// following someone twice is not an error: the relationship just stays put
+	if _, err := cfg.db.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	}); err != nil {
+		log.Print(fmt.Errorf("%v storing follow in the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't follow user")
+		return
+	}
+
+	log.Printf("%v user %q follows %q", successTag, followerID, followeeID)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	followerID, ok := cfg.authenticateUser(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+	followeeID, ok := cfg.userFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	rowsAffected, err := cfg.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		log.Print(fmt.Errorf("%v deleting follow from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't unfollow user")
+		return
+	}
+
+	if rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "user isn't being followed")
+		return
+	}
+
+	log.Printf("%v user %q unfollowed %q", successTag, followerID, followeeID)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGETFollowers(w http.ResponseWriter, r *http.Request) {
+	cfg.respondWithFollows(w, r, "followers", func(ctx context.Context, userID uuid.UUID, page pageRequest) ([]Follow, error) {
+		cursorCreatedAt, cursorID := page.cursorArgs()
+		follows := []Follow{}
+		if page.backward() {
+			rows, err := cfg.db.ListFollowersAscending(ctx, database.ListFollowersAscendingParams{
+				UserID:          userID,
+				CursorCreatedAt: cursorCreatedAt,
+				CursorID:        cursorID,
+				PageSize:        page.fetchSize(),
+			})
+			for _, row := range rows {
+				follows = append(follows, Follow(row))
+			}
+			return follows, err
+		}
+		rows, err := cfg.db.ListFollowersDescending(ctx, database.ListFollowersDescendingParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageSize:        page.fetchSize(),
+		})
+		for _, row := range rows {
+			follows = append(follows, Follow(row))
+		}
+		return follows, err
+	})
+}
+
+func (cfg *apiConfig) handlerGETFollowing(w http.ResponseWriter, r *http.Request) {
+	cfg.respondWithFollows(w, r, "followed users", func(ctx context.Context, userID uuid.UUID, page pageRequest) ([]Follow, error) {
+		cursorCreatedAt, cursorID := page.cursorArgs()
+		follows := []Follow{}
+		if page.backward() {
+			rows, err := cfg.db.ListFollowingAscending(ctx, database.ListFollowingAscendingParams{
+				UserID:          userID,
+				CursorCreatedAt: cursorCreatedAt,
+				CursorID:        cursorID,
+				PageSize:        page.fetchSize(),
+			})
+			for _, row := range rows {
+				follows = append(follows, Follow(row))
+			}
+			return follows, err
+		}
+		rows, err := cfg.db.ListFollowingDescending(ctx, database.ListFollowingDescendingParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageSize:        page.fetchSize(),
+		})
+		for _, row := range rows {
+			follows = append(follows, Follow(row))
+		}
+		return follows, err
+	})
+}
+
+// listFollowsFunc reads a page of the follows of a user, reading upwards when
+// the page goes backward.
+type listFollowsFunc func(ctx context.Context, userID uuid.UUID, page pageRequest) ([]Follow, error)
+
+// respondWithFollows responds with a page of the follows of the user named in
+// the URL, read with list. noun names the follows in errors.
+func (cfg *apiConfig) respondWithFollows(w http.ResponseWriter, r *http.Request, noun string, list listFollowsFunc) {
+	userID, ok := cfg.userFromPath(w, r)
+	if !ok {
+		return
+	}
+	page, err := parsePageRequest(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("request error: %v", err))
+		return
+	}
+
+	// follows are listed newest first, so going back means reading upwards
+	follows, err := list(r.Context(), userID, page)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting %v from the database: %w", errorTag, noun, err))
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("database error: couldn't retrieve %v", noun))
+		return
+	}
+
+	follows, next, prev := paginate(follows, page, followCursor)
+	respondWithJSON(w, http.StatusOK, FollowsPage{
+		Users:      follows,
+		Pagination: setPaginationLinks(w, r, page, next, prev),
+	})
+}
+
+func (cfg *apiConfig) handlerTimeline(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r, auth.ScopeChirpsRead)
+	if !ok {
+		return
+	}
+	page, err := parsePageRequest(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("request error: %v", err))
+		return
+	}
+
+	// the timeline is always newest first, so going back means reading upwards
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	var chirps []database.Chirp
+	if page.backward() {
+		chirps, err = cfg.db.ListTimelineAscending(r.Context(), database.ListTimelineAscendingParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageSize:        page.fetchSize(),
+		})
+	} else {
+		chirps, err = cfg.db.ListTimelineDescending(r.Context(), database.ListTimelineDescendingParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageSize:        page.fetchSize(),
+		})
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting timeline from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve timeline")
+		return
+	}
+
+	chirps, next, prev := paginate(chirps, page, chirpCursor)
+	chirpsWithTags, err := cfg.presentChirps(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, chirps)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting chirp details from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve chirps")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, ChirpsPage{
+		Chirps:     chirpsWithTags,
+		Pagination: setPaginationLinks(w, r, page, next, prev),
+	})
+}
@@ -11,3 +11,7 @@ require github.com/joho/godotenv v1.5.1
 require golang.org/x/crypto v0.38.0
 
 require github.com/golang-jwt/jwt/v5 v5.2.2
+
+require golang.org/x/text v0.25.0
+
+require golang.org/x/sys v0.33.0 // indirect
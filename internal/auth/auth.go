@@ -2,72 +2,128 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
-	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
-const cost = 10
-
-func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), cost)
-	if err != nil {
-		return "", fmt.Errorf("hashing password: %w", err)
-	}
-	return string(hashedPassword), nil
+// AccessToken is what an access token tells about its bearer.
+type AccessToken struct {
+	UserID uuid.UUID
+	// SessionID is the family of the refresh token the access token was
+	// issued along with. It's uuid.Nil for tokens issued before sessions
+	// were tracked.
+	SessionID uuid.UUID
+	// TokenVersion must match the token version of the user for the access
+	// token to be valid, which lets us revoke every token of a user at once
+	TokenVersion int32
+	// Scopes limit what the access token can be used for. It's nil for the
+	// tokens of a login, which can be used for anything
+	Scopes []Scope
+	// ClientID is the OAuth client the access token was issued to. It's
+	// uuid.Nil for the tokens of a login
+	ClientID uuid.UUID
+	// Role is the role of the user when the access token was issued. Only the
+	// tokens of a login have one
+	Role Role
+	// IssuedAt and ExpiresAt are only set on validated access tokens
+	IssuedAt  time.Time
+	ExpiresAt time.Time
 }
 
-func CheckPasswordHash(hash, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+// claims are the claims of the JWTs we issue.
+type claims struct {
+	jwt.RegisteredClaims
+	SessionID    string `json:"sid,omitempty"`
+	TokenVersion int32  `json:"ver"`
+	// Scope and ClientID follow RFC 9068, with the scopes separated by spaces
+	Scope    string `json:"scope,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	Role     string `json:"role,omitempty"`
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	if tokenSecret == "" {
-		return "", errors.New("got empty tokenSecret")
-	}
-
-	now := time.Now().UTC()
-	claims := &jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(now),
-		ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
-		Subject:   userID.String(),
+// MakeJWT signs an access token with HS256 and the given secret. It's
+// equivalent to calling KeySet.MakeJWT on a set with a single HMAC key.
+func MakeJWT(accessToken AccessToken, tokenSecret string, expiresIn time.Duration) (string, error) {
+	keys, err := hmacKeySet(tokenSecret)
+	if err != nil {
+		return "", err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(tokenSecret))
+	return keys.MakeJWT(accessToken, expiresIn)
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (any, error) {
-		return []byte(tokenSecret), nil
-	})
+// MakeJWT signs an access token with the current signing key of the set,
+// whose ID goes in the kid header of the token.
+func (ks *KeySet) MakeJWT(accessToken AccessToken, expiresIn time.Duration) (string, error) {
+	// an empty scope claim would make the token unrestricted
+	if accessToken.Scopes != nil && len(accessToken.Scopes) == 0 {
+		return "", errors.New("access token must have at least one scope")
+	}
+	now := time.Now().UTC()
+	key, err := ks.SigningKey(now)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("parsing JWT: %w", err)
+		return "", err
 	}
 
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
-	if !ok {
-		return uuid.Nil, errors.New("unknown claims type, can't proceed")
+	claims := &claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+			Subject:   accessToken.UserID.String(),
+		},
+		TokenVersion: accessToken.TokenVersion,
+	}
+	if accessToken.SessionID != uuid.Nil {
+		claims.SessionID = accessToken.SessionID.String()
+	}
+	if accessToken.Scopes != nil {
+		claims.Scope = FormatScopes(accessToken.Scopes)
 	}
+	if accessToken.ClientID != uuid.Nil {
+		claims.ClientID = accessToken.ClientID.String()
+	}
+	if accessToken.Role != "" {
+		claims.Role = string(accessToken.Role)
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.signingKey)
+}
 
-	userIDString, err := claims.GetSubject()
+// ValidateJWT validates an access token signed with HS256 and the given
+// secret. It's equivalent to calling KeySet.ValidateJWT on a set with a single
+// HMAC key.
+func ValidateJWT(tokenString, tokenSecret string, tokenVersion func(userID uuid.UUID) (int32, error)) (AccessToken, error) {
+	keys, err := hmacKeySet(tokenSecret)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("getting subject field from JWT: %w", err)
+		return AccessToken{}, err
 	}
+	return keys.ValidateJWT(tokenString, tokenVersion)
+}
 
-	userID, err := uuid.Parse(userIDString)
+// ValidateJWT validates an access token, which must be signed by a key of the
+// set that isn't retired, with the default validator.
+func (ks *KeySet) ValidateJWT(tokenString string, tokenVersion func(userID uuid.UUID) (int32, error)) (AccessToken, error) {
+	return DefaultValidator().Validate(ks, tokenString, tokenVersion)
+}
+
+// hmacKeySet returns a set with a single HMAC key without an ID.
+func hmacKeySet(secret string) (*KeySet, error) {
+	key, err := NewHMACKey("", []byte(secret))
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("transforming user id of type string into uuid type: %w", err)
+		return nil, err
 	}
-
-	return userID, nil
+	return NewKeySet(key)
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -94,6 +150,14 @@ func MakeRefreshToken() (string, error) {
 	return hex.EncodeToString(key), nil
 }
 
+// HashRefreshToken returns the form in which refresh tokens are stored, so
+// that the tokens in the database can't be used by whoever reads them. Refresh
+// tokens are random enough that a fast hash is as good as a slow one.
+func HashRefreshToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
 	authHeader := headers.Get("Authorization")
 	if authHeader == "" {
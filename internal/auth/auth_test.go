@@ -1,9 +1,11 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"testing"
 	"time"
 
@@ -143,23 +145,122 @@ func TestJWTManagement(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			tokenString, err := MakeJWT(test.userID, test.tokenSecret, test.expiresIn)
+			tokenString, err := MakeJWT(AccessToken{UserID: test.userID}, test.tokenSecret, test.expiresIn)
 			if (err != nil) != test.createTokenError {
 				t.Errorf("can't create JWT: %v", err)
 			}
 
-			recoveredUserID, err := ValidateJWT(tokenString, test.alternativeTokenSecret)
+			accessToken, err := ValidateJWT(tokenString, test.alternativeTokenSecret, nil)
 			if (err != nil) != test.validateTokenError {
 				t.Errorf("can't parse JWT: %v", err)
 			}
 
-			if (test.userID != recoveredUserID) != test.userValidationError {
+			if (test.userID != accessToken.UserID) != test.userValidationError {
 				t.Errorf("expected test.userID == recoveredUserID to be %v, but it isn't", test.userValidationError)
 			}
 		})
 	}
 }
 
+func TestJWTTokenVersion(t *testing.T) {
+	user := uuid.New()
+	session := uuid.New()
+	tokenSecret := "this token is secret"
+
+	tokenString, err := MakeJWT(AccessToken{UserID: user, SessionID: session, TokenVersion: 3}, tokenSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("can't create JWT: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		currentVersion int32
+		expectedError  error
+	}{
+		{
+			name:           "Assert token with the current version is valid",
+			currentVersion: 3,
+			expectedError:  nil,
+		},
+		{
+			name:           "Assert token with an outdated version is revoked",
+			currentVersion: 4,
+			expectedError:  ErrTokenRevoked,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			accessToken, err := ValidateJWT(tokenString, tokenSecret, func(userID uuid.UUID) (int32, error) {
+				if userID != user {
+					t.Errorf("asked for the token version of %v instead of %v", userID, user)
+				}
+				return test.currentVersion, nil
+			})
+			if !errors.Is(err, test.expectedError) {
+				t.Fatalf("got error %v when expecting %v", err, test.expectedError)
+			}
+			if err == nil && (accessToken.SessionID != session || accessToken.TokenVersion != 3) {
+				t.Errorf("got %+v, claims weren't recovered", accessToken)
+			}
+		})
+	}
+}
+
+func TestJWTScopes(t *testing.T) {
+	tokenSecret := "this token is secret"
+	client := uuid.New()
+
+	tests := []struct {
+		name        string
+		accessToken AccessToken
+		createError bool
+	}{
+		{
+			name:        "Assert tokens of a login have no scopes",
+			accessToken: AccessToken{UserID: uuid.New()},
+		},
+		{
+			name: "Assert scopes and client are recovered",
+			accessToken: AccessToken{
+				UserID:   uuid.New(),
+				Scopes:   []Scope{ScopeChirpsRead, ScopeChirpsWrite},
+				ClientID: client,
+			},
+		},
+		{
+			name:        "Assert tokens can't be limited to no scopes",
+			accessToken: AccessToken{UserID: uuid.New(), Scopes: []Scope{}},
+			createError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokenString, err := MakeJWT(test.accessToken, tokenSecret, time.Hour)
+			if (err != nil) != test.createError {
+				t.Fatalf("got error %v when creating JWT, expected error: %v", err, test.createError)
+			}
+			if err != nil {
+				return
+			}
+			accessToken, err := ValidateJWT(tokenString, tokenSecret, nil)
+			if err != nil {
+				t.Fatalf("can't parse JWT: %v", err)
+			}
+			if !slices.Equal(accessToken.Scopes, test.accessToken.Scopes) || accessToken.ClientID != test.accessToken.ClientID {
+				t.Errorf("got %+v, claims weren't recovered", accessToken)
+			}
+			if (accessToken.Scopes == nil) != (test.accessToken.Scopes == nil) {
+				t.Errorf("got scopes %#v, expected %#v", accessToken.Scopes, test.accessToken.Scopes)
+			}
+			if accessToken.ExpiresAt.Sub(accessToken.IssuedAt) != time.Hour {
+				t.Errorf("got token issued at %v and expiring at %v, expected it to last an hour", accessToken.IssuedAt, accessToken.ExpiresAt)
+			}
+		})
+	}
+}
+
 func TestBearerToken(t *testing.T) {
 	tokenString := "+pK7C2P"
 
@@ -227,3 +328,31 @@ func TestBearerToken(t *testing.T) {
 		})
 	}
 }
+
+func TestHashRefreshToken(t *testing.T) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("can't make refresh token: %v", err)
+	}
+	otherToken, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("can't make refresh token: %v", err)
+	}
+
+	hash := HashRefreshToken(token)
+	if hash == token {
+		t.Error("hash is the same as the token")
+	}
+	if HashRefreshToken(token) != hash {
+		t.Error("hashing the same token twice gave different hashes")
+	}
+	if HashRefreshToken(otherToken) == hash {
+		t.Error("different tokens gave the same hash")
+	}
+
+	// the hash must match the one computed by the database migration
+	const expected = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if got := HashRefreshToken("hello"); got != expected {
+		t.Errorf("got %q when expecting %q", got, expected)
+	}
+}
@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is a key that signs or verifies JWTs with a single algorithm.
+type Key struct {
+	// ID goes in the kid header of the tokens signed with the key. Only the
+	// key kept for tokens signed with the old shared secret has an empty ID.
+	ID     string
+	method jwt.SigningMethod
+	// signingKey is nil for keys that can only verify tokens
+	signingKey   any
+	verifyingKey any
+	// ActiveFrom is when the key starts signing tokens, while RetireAt, when
+	// not zero, is when it stops verifying them. Keys are published in the
+	// JWKS before they become active, so that whoever verifies our tokens
+	// knows them by the time they're used.
+	ActiveFrom time.Time
+	RetireAt   time.Time
+}
+
+// NewHMACKey returns a key that signs and verifies tokens with HS256, for
+// deployments with a single server that share no keys with anyone.
+func NewHMACKey(id string, secret []byte) (Key, error) {
+	if len(secret) == 0 {
+		return Key{}, errors.New("got empty secret")
+	}
+	return Key{ID: id, method: jwt.SigningMethodHS256, signingKey: secret, verifyingKey: secret}, nil
+}
+
+// ParseKey reads a PEM-encoded key. Private keys, in PKCS #8, PKCS #1 or SEC 1
+// form, can sign and verify tokens, while public keys can only verify them.
+// The algorithm follows from the type of the key: RS256 for RSA keys, ES256 or
+// ES384 for ECDSA keys on P-256 or P-384, and EdDSA for Ed25519 keys.
+func ParseKey(id string, pemBytes []byte) (Key, error) {
+	if id == "" {
+		return Key{}, errors.New("key ID can't be empty")
+	}
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return Key{}, errors.New("no PEM data found")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return Key{}, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return Key{}, fmt.Errorf("parsing key: %w", err)
+	}
+
+	key := Key{ID: id}
+	if signer, ok := parsed.(crypto.Signer); ok {
+		key.signingKey = signer
+		parsed = signer.Public()
+	}
+	key.verifyingKey = parsed
+
+	switch public := parsed.(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < 2048 {
+			return Key{}, fmt.Errorf("RSA keys must be at least 2048 bits long, got %d", public.N.BitLen())
+		}
+		key.method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		switch public.Curve {
+		case elliptic.P256():
+			key.method = jwt.SigningMethodES256
+		case elliptic.P384():
+			key.method = jwt.SigningMethodES384
+		default:
+			return Key{}, fmt.Errorf("unsupported curve %s", public.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return Key{}, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	return key, nil
+}
+
+// Algorithm returns the JWT algorithm of the key, like RS256.
+func (k Key) Algorithm() string {
+	return k.method.Alg()
+}
+
+// VerifyOnly returns a copy of the key that can't sign tokens.
+func (k Key) VerifyOnly() Key {
+	k.signingKey = nil
+	return k
+}
+
+func (k Key) retired(now time.Time) bool {
+	return !k.RetireAt.IsZero() && !now.Before(k.RetireAt)
+}
+
+// KeySet holds every key we sign or verify tokens with. A KeySet is immutable
+// and safe for concurrent use.
+type KeySet struct {
+	keys []Key
+}
+
+// NewKeySet returns a set with the given keys, which must have different IDs.
+func NewKeySet(keys ...Key) (*KeySet, error) {
+	seen := map[string]struct{}{}
+	for _, key := range keys {
+		if _, ok := seen[key.ID]; ok {
+			return nil, fmt.Errorf("key ID %q is repeated", key.ID)
+		}
+		seen[key.ID] = struct{}{}
+	}
+	return &KeySet{keys: slices.Clone(keys)}, nil
+}
+
+// SigningKey returns the key that signs tokens at the given time: of the keys
+// that can sign, the one that became active the latest.
+func (ks *KeySet) SigningKey(now time.Time) (Key, error) {
+	var signingKey Key
+	found := false
+	for _, key := range ks.keys {
+		if key.signingKey == nil || now.Before(key.ActiveFrom) || key.retired(now) {
+			continue
+		}
+		if !found || key.ActiveFrom.After(signingKey.ActiveFrom) {
+			signingKey = key
+			found = true
+		}
+	}
+	if !found {
+		return Key{}, errors.New("no active signing key")
+	}
+	return signingKey, nil
+}
+
+// verifyingKey returns the key with the given ID, unless it's retired.
+func (ks *KeySet) verifyingKey(id string, now time.Time) (Key, error) {
+	for _, key := range ks.keys {
+		if key.ID != id {
+			continue
+		}
+		if key.retired(now) {
+			return Key{}, fmt.Errorf("key %q is retired", id)
+		}
+		return key, nil
+	}
+	return Key{}, fmt.Errorf("unknown key %q", id)
+}
+
+// keyFunc finds the key that verifies a token. Every key is bound to a single
+// algorithm, so a token can't pick a weaker one or use a public key as an HMAC
+// secret.
+func (ks *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	id, _ := token.Header["kid"].(string)
+	key, err := ks.verifyingKey(id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.Algorithm() {
+		return nil, fmt.Errorf("key %q doesn't use algorithm %s", id, token.Method.Alg())
+	}
+	return key.verifyingKey, nil
+}
+
+// JWK is a public key in the JSON Web Key format of RFC 7517.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the set that aren't retired, including the
+// ones that aren't active yet. HMAC keys are secret and left out.
+func (ks *KeySet) JWKS(now time.Time) JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		if key.retired(now) {
+			continue
+		}
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Algorithm()}
+		switch public := key.verifyingKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			jwk.KeyType = "EC"
+			jwk.Curve = public.Curve.Params().Name
+			size := (public.Curve.Params().BitSize + 7) / 8
+			jwk.X = base64.RawURLEncoding.EncodeToString(public.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(public.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+// LoadKeySet reads the keys listed in a JSON manifest like this one:
+//
+//	{
+//	  "keys": [
+//	    {"kid": "2026-10", "file": "2026-10.pem", "active_from": "2026-10-01T00:00:00Z", "retire_at": "2027-01-02T00:00:00Z"},
+//	    {"kid": "2027-01", "file": "2027-01.pem", "active_from": "2027-01-01T00:00:00Z"}
+//	  ]
+//	}
+//
+// Files are PEM-encoded keys, as read by ParseKey, with paths relative to the
+// manifest. active_from and retire_at are optional. The extra keys, like an
+// HMAC key kept to verify the tokens issued before switching to the manifest,
+// are added to the set as they are.
+func LoadKeySet(path string, extra ...Key) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest struct {
+		Keys []struct {
+			ID         string    `json:"kid"`
+			File       string    `json:"file"`
+			ActiveFrom time.Time `json:"active_from"`
+			RetireAt   time.Time `json:"retire_at"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+
+	keys := make([]Key, 0, len(manifest.Keys)+len(extra))
+	for _, entry := range manifest.Keys {
+		file := entry.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+		pemBytes, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", entry.ID, err)
+		}
+		key, err := ParseKey(entry.ID, pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", entry.ID, err)
+		}
+		key.ActiveFrom = entry.ActiveFrom
+		key.RetireAt = entry.RetireAt
+		keys = append(keys, key)
+	}
+	return NewKeySet(append(keys, extra...)...)
+}
@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func privateKeyPEM(t *testing.T, key any) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("can't marshal private key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func publicKeyPEM(t *testing.T, key any) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("can't marshal public key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestAsymmetricKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("can't generate RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("can't generate ECDSA key: %v", err)
+	}
+	edPublicKey, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("can't generate Ed25519 key: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		privateKey []byte
+		publicKey  []byte
+		algorithm  string
+		keyType    string
+	}{
+		{
+			name:       "Assert RSA keys sign with RS256",
+			privateKey: privateKeyPEM(t, rsaKey),
+			publicKey:  publicKeyPEM(t, &rsaKey.PublicKey),
+			algorithm:  "RS256",
+			keyType:    "RSA",
+		},
+		{
+			name:       "Assert P-256 keys sign with ES256",
+			privateKey: privateKeyPEM(t, ecKey),
+			publicKey:  publicKeyPEM(t, &ecKey.PublicKey),
+			algorithm:  "ES256",
+			keyType:    "EC",
+		},
+		{
+			name:       "Assert Ed25519 keys sign with EdDSA",
+			privateKey: privateKeyPEM(t, edKey),
+			publicKey:  publicKeyPEM(t, edPublicKey),
+			algorithm:  "EdDSA",
+			keyType:    "OKP",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signingKey, err := ParseKey("signing", test.privateKey)
+			if err != nil {
+				t.Fatalf("can't parse private key: %v", err)
+			}
+			if signingKey.Algorithm() != test.algorithm {
+				t.Fatalf("got algorithm %v when expecting %v", signingKey.Algorithm(), test.algorithm)
+			}
+			signingSet, err := NewKeySet(signingKey)
+			if err != nil {
+				t.Fatalf("can't create key set: %v", err)
+			}
+
+			user := uuid.New()
+			tokenString, err := signingSet.MakeJWT(AccessToken{UserID: user}, time.Hour)
+			if err != nil {
+				t.Fatalf("can't create JWT: %v", err)
+			}
+
+			token, _, err := jwt.NewParser().ParseUnverified(tokenString, &claims{})
+			if err != nil {
+				t.Fatalf("can't parse JWT: %v", err)
+			}
+			if token.Header["kid"] != "signing" || token.Header["alg"] != test.algorithm {
+				t.Errorf("got header %v", token.Header)
+			}
+
+			// a service that only knows the public key can verify the token
+			verifyingKey, err := ParseKey("signing", test.publicKey)
+			if err != nil {
+				t.Fatalf("can't parse public key: %v", err)
+			}
+			verifyingSet, err := NewKeySet(verifyingKey)
+			if err != nil {
+				t.Fatalf("can't create key set: %v", err)
+			}
+			accessToken, err := verifyingSet.ValidateJWT(tokenString, nil)
+			if err != nil {
+				t.Fatalf("can't validate JWT: %v", err)
+			}
+			if accessToken.UserID != user {
+				t.Errorf("got user %v when expecting %v", accessToken.UserID, user)
+			}
+			if _, err := verifyingSet.MakeJWT(AccessToken{UserID: user}, time.Hour); err == nil {
+				t.Errorf("signed a JWT with a public key")
+			}
+
+			jwks := verifyingSet.JWKS(time.Now())
+			if len(jwks.Keys) != 1 || jwks.Keys[0].KeyType != test.keyType || jwks.Keys[0].KeyID != "signing" {
+				t.Errorf("got JWKS %+v", jwks)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	now := time.Now()
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("can't generate ECDSA key: %v", err)
+	}
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("can't generate ECDSA key: %v", err)
+	}
+
+	current, err := ParseKey("current", privateKeyPEM(t, ecKey))
+	if err != nil {
+		t.Fatalf("can't parse key: %v", err)
+	}
+	current.ActiveFrom = now.Add(-24 * time.Hour)
+	next, err := ParseKey("next", privateKeyPEM(t, newKey))
+	if err != nil {
+		t.Fatalf("can't parse key: %v", err)
+	}
+	next.ActiveFrom = now.Add(24 * time.Hour)
+	legacy, err := NewHMACKey("", []byte("this token is secret"))
+	if err != nil {
+		t.Fatalf("can't create HMAC key: %v", err)
+	}
+
+	keys, err := NewKeySet(current, next, legacy.VerifyOnly())
+	if err != nil {
+		t.Fatalf("can't create key set: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		at          time.Time
+		expectedKey string
+		publicKeys  int
+	}{
+		{
+			name:        "Assert current key signs before the next one is active",
+			at:          now,
+			expectedKey: "current",
+			publicKeys:  2,
+		},
+		{
+			name:        "Assert next key signs once it's active",
+			at:          now.Add(48 * time.Hour),
+			expectedKey: "next",
+			publicKeys:  2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := keys.SigningKey(test.at)
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if key.ID != test.expectedKey {
+				t.Errorf("got key %q when expecting %q", key.ID, test.expectedKey)
+			}
+			if jwks := keys.JWKS(test.at); len(jwks.Keys) != test.publicKeys {
+				t.Errorf("got %d public keys when expecting %d", len(jwks.Keys), test.publicKeys)
+			}
+		})
+	}
+
+	t.Run("Assert tokens signed with the legacy secret are still valid", func(t *testing.T) {
+		tokenString, err := MakeJWT(AccessToken{UserID: uuid.New()}, "this token is secret", time.Hour)
+		if err != nil {
+			t.Fatalf("can't create JWT: %v", err)
+		}
+		if _, err := keys.ValidateJWT(tokenString, nil); err != nil {
+			t.Errorf("got error %v", err)
+		}
+	})
+
+	t.Run("Assert retired keys don't verify tokens", func(t *testing.T) {
+		tokenString, err := keys.MakeJWT(AccessToken{UserID: uuid.New()}, time.Hour)
+		if err != nil {
+			t.Fatalf("can't create JWT: %v", err)
+		}
+		current.RetireAt = now.Add(-time.Minute)
+		retired, err := NewKeySet(current, next)
+		if err != nil {
+			t.Fatalf("can't create key set: %v", err)
+		}
+		if _, err := retired.ValidateJWT(tokenString, nil); err == nil {
+			t.Errorf("validated a JWT signed with a retired key")
+		}
+	})
+
+	t.Run("Assert keys only verify tokens of their own algorithm", func(t *testing.T) {
+		// an attacker signs a token with HS256 using the public key as secret
+		secret := publicKeyPEM(t, &ecKey.PublicKey)
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, &claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   uuid.NewString(),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			},
+		})
+		token.Header["kid"] = "current"
+		tokenString, err := token.SignedString(secret)
+		if err != nil {
+			t.Fatalf("can't create JWT: %v", err)
+		}
+		if _, err := keys.ValidateJWT(tokenString, nil); err == nil {
+			t.Errorf("validated a JWT with the wrong algorithm")
+		}
+	})
+}
+
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("can't generate Ed25519 key: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "2026-10.pem"), privateKeyPEM(t, edKey), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	manifest := `{"keys": [{"kid": "2026-10", "file": "2026-10.pem", "active_from": "2026-10-01T00:00:00Z"}]}`
+	if err := os.WriteFile(filepath.Join(dir, "keys.json"), []byte(manifest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadKeySet(filepath.Join(dir, "keys.json"))
+	if err != nil {
+		t.Fatalf("can't load key set: %v", err)
+	}
+	key, err := keys.SigningKey(time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if key.ID != "2026-10" || key.Algorithm() != "EdDSA" {
+		t.Errorf("got key %q with algorithm %v", key.ID, key.Algorithm())
+	}
+	if _, err := keys.SigningKey(time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Errorf("got a signing key before any key was active")
+	}
+}
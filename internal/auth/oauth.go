@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// FormatScopes joins scopes the way OAuth sends them: separated by spaces.
+func FormatScopes(scopes []Scope) string {
+	names := make([]string, len(scopes))
+	for i, scope := range scopes {
+		names[i] = string(scope)
+	}
+	return strings.Join(names, " ")
+}
+
+// ParseScopeString checks a list of scope names separated by spaces, as in the
+// scope parameter of OAuth requests and the scope claim of access tokens.
+func ParseScopeString(s string) ([]Scope, error) {
+	return ParseScopes(strings.Fields(s))
+}
+
+// IsCodeVerifier reports whether a PKCE code verifier has the length and
+// characters RFC 7636 asks for.
+func IsCodeVerifier(verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	for _, r := range verifier {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		case r == '-', r == '.', r == '_', r == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// IsCodeChallenge reports whether a PKCE code challenge could have been made
+// with the S256 method: the unpadded base64url encoding of a SHA-256 hash.
+func IsCodeChallenge(challenge string) bool {
+	hash, err := base64.RawURLEncoding.DecodeString(challenge)
+	return err == nil && len(hash) == sha256.Size
+}
+
+// VerifyPKCE checks a PKCE code verifier against the code challenge it should
+// have been made into with the S256 method. The plain method isn't supported,
+// as OAuth 2.1 recommends.
+func VerifyPKCE(verifier, challenge string) bool {
+	if !IsCodeVerifier(verifier) {
+		return false
+	}
+	hash := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(hash[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// ValidateRedirectURI checks that an OAuth client can register a redirect URI.
+// OAuth 2.1 allows HTTPS URIs, HTTP URIs on the loopback interface for native
+// apps, and private-use schemes in reverse domain name form, like
+// com.example.app:/callback. Fragments aren't allowed in any of them.
+func ValidateRedirectURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("not a valid URI: %w", err)
+	}
+	if u.Fragment != "" || strings.Contains(uri, "#") {
+		return errors.New("must not have a fragment")
+	}
+	switch {
+	case u.Scheme == "https":
+		if u.Host == "" {
+			return errors.New("must have a host")
+		}
+	case u.Scheme == "http":
+		if !isLoopback(u.Hostname()) {
+			return errors.New("must use https unless it's on the loopback interface")
+		}
+	case strings.Contains(u.Scheme, "."):
+		if u.Opaque != "" {
+			return errors.New("must have a path starting with a slash")
+		}
+	default:
+		return fmt.Errorf("scheme %q isn't allowed", u.Scheme)
+	}
+	return nil
+}
+
+// MatchRedirectURI reports whether a redirect URI sent by a client is one of
+// those it registered. URIs must match exactly, except for the port of those
+// on the loopback interface, which native apps pick when they start.
+func MatchRedirectURI(registered []string, uri string) bool {
+	for _, candidate := range registered {
+		if candidate == uri {
+			return true
+		}
+	}
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "http" || !isLoopback(u.Hostname()) {
+		return false
+	}
+	for _, candidate := range registered {
+		c, err := url.Parse(candidate)
+		if err != nil || c.Scheme != "http" || !isLoopback(c.Hostname()) {
+			continue
+		}
+		if c.Hostname() == u.Hostname() && c.Path == u.Path && c.RawQuery == u.RawQuery {
+			return true
+		}
+	}
+	return false
+}
+
+// isLoopback reports whether a host is a loopback IP address. The name
+// localhost isn't accepted, as it may resolve somewhere else.
+func isLoopback(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestScopeString(t *testing.T) {
+	scopes, err := ParseScopeString("chirps:read  chirps:write chirps:read")
+	if err != nil {
+		t.Fatalf("ParseScopeString() error = %v", err)
+	}
+	if expected := []Scope{ScopeChirpsRead, ScopeChirpsWrite}; !slices.Equal(scopes, expected) {
+		t.Errorf("ParseScopeString() = %v, expected %v", scopes, expected)
+	}
+	if s := FormatScopes(scopes); s != "chirps:read chirps:write" {
+		t.Errorf("FormatScopes() = %q", s)
+	}
+	if _, err := ParseScopeString(" "); err == nil {
+		t.Error("ParseScopeString() accepted no scopes")
+	}
+}
+
+func TestPKCE(t *testing.T) {
+	// the example of RFC 7636, appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	hash := sha256.Sum256([]byte(strings.Repeat("a", 43)))
+	otherChallenge := base64.RawURLEncoding.EncodeToString(hash[:])
+
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		expected  bool
+	}{
+		{
+			name:      "matching verifier",
+			verifier:  verifier,
+			challenge: challenge,
+			expected:  true,
+		},
+		{
+			name:      "verifier of another challenge",
+			verifier:  verifier,
+			challenge: otherChallenge,
+			expected:  false,
+		},
+		{
+			name:      "plain method",
+			verifier:  verifier,
+			challenge: verifier,
+			expected:  false,
+		},
+		{
+			name:      "short verifier",
+			verifier:  "abc",
+			challenge: "ungWv48Bz-pBQUDeXa4iI7ADYaOWF3qctBD_YfIAFa0",
+			expected:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyPKCE(tt.verifier, tt.challenge); got != tt.expected {
+				t.Errorf("VerifyPKCE() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+
+	if !IsCodeChallenge(challenge) {
+		t.Errorf("%q isn't recognized as a code challenge", challenge)
+	}
+	if IsCodeChallenge("not a challenge") {
+		t.Error("a string that isn't base64url is recognized as a code challenge")
+	}
+	if IsCodeVerifier(strings.Repeat("a", 42) + "!") {
+		t.Error("a verifier with a forbidden character is accepted")
+	}
+}
+
+func TestValidateRedirectURI(t *testing.T) {
+	tests := []struct {
+		uri       string
+		expectErr bool
+	}{
+		{uri: "https://example.com/callback"},
+		{uri: "http://127.0.0.1/callback"},
+		{uri: "http://[::1]:8000/callback"},
+		{uri: "com.example.app:/callback"},
+		{uri: "http://example.com/callback", expectErr: true},
+		{uri: "http://localhost/callback", expectErr: true},
+		{uri: "https://example.com/callback#state", expectErr: true},
+		{uri: "https:///callback", expectErr: true},
+		{uri: "javascript:alert(1)", expectErr: true},
+		{uri: "/callback", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			err := ValidateRedirectURI(tt.uri)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("ValidateRedirectURI() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestMatchRedirectURI(t *testing.T) {
+	registered := []string{"https://example.com/callback", "http://127.0.0.1/callback"}
+
+	tests := []struct {
+		uri      string
+		expected bool
+	}{
+		{uri: "https://example.com/callback", expected: true},
+		{uri: "http://127.0.0.1:51004/callback", expected: true},
+		{uri: "https://example.com/callback/", expected: false},
+		{uri: "https://example.com:8443/callback", expected: false},
+		{uri: "https://example.com/callback?next=/admin", expected: false},
+		{uri: "http://127.0.0.1:51004/other", expected: false},
+		{uri: "http://[::1]:51004/callback", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			if got := MatchRedirectURI(registered, tt.uri); got != tt.expected {
+				t.Errorf("MatchRedirectURI() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrPasswordMismatch is returned when a password doesn't match its hash.
+var ErrPasswordMismatch = errors.New("password doesn't match")
+
+// Hasher hashes passwords in a format it recognizes later on.
+type Hasher interface {
+	Hash(password string) (string, error)
+	// Recognizes tells whether a hash is in the format of the hasher.
+	Recognizes(hash string) bool
+	// Compare returns ErrPasswordMismatch when the password doesn't match a
+	// hash the hasher recognizes.
+	Compare(hash, password string) error
+	// NeedsRehash tells whether a hash the hasher recognizes was made with
+	// parameters other than its current ones.
+	NeedsRehash(hash string) bool
+}
+
+// Passwords hashes new passwords with Current, while checking stored hashes
+// with whichever hasher recognizes them, so that hashes made by hashers we no
+// longer use keep working until they're replaced.
+type Passwords struct {
+	Current Hasher
+	Legacy  []Hasher
+}
+
+// DefaultPasswords hashes passwords with argon2id and its default parameters,
+// and recognizes the bcrypt hashes we used to make.
+func DefaultPasswords() Passwords {
+	return Passwords{
+		Current: Argon2idHasher{Params: DefaultArgon2idParams},
+		Legacy:  []Hasher{BcryptHasher{Cost: bcrypt.DefaultCost}},
+	}
+}
+
+func (p Passwords) Hash(password string) (string, error) {
+	return p.Current.Hash(password)
+}
+
+// Check compares a password with its hash. When it matches, it also tells
+// whether the hash should be replaced with a new one made with Hash, because
+// it was made by a legacy hasher or with outdated parameters.
+func (p Passwords) Check(hash, password string) (rehash bool, err error) {
+	if p.Current.Recognizes(hash) {
+		if err := p.Current.Compare(hash, password); err != nil {
+			return false, err
+		}
+		return p.Current.NeedsRehash(hash), nil
+	}
+	for _, hasher := range p.Legacy {
+		if hasher.Recognizes(hash) {
+			if err := hasher.Compare(hash, password); err != nil {
+				return false, err
+			}
+			return true, nil
+		}
+	}
+	return false, errors.New("unknown password hash format")
+}
+
+// HashPassword hashes a password with the default hasher.
+func HashPassword(password string) (string, error) {
+	return DefaultPasswords().Hash(password)
+}
+
+// CheckPasswordHash returns nil when the password matches its hash.
+func CheckPasswordHash(hash, password string) error {
+	_, err := DefaultPasswords().Check(hash, password)
+	return err
+}
+
+// Argon2idParams are the cost parameters of argon2id.
+type Argon2idParams struct {
+	// Memory is in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams are the minimum parameters recommended by OWASP.
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// ParseArgon2idParams reads parameters written as in PHC strings, like
+// m=19456,t=2,p=1. Parameters that are left out keep their default value.
+func ParseArgon2idParams(s string) (Argon2idParams, error) {
+	params := DefaultArgon2idParams
+	for _, field := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return Argon2idParams{}, fmt.Errorf("malformed parameter %q", field)
+		}
+		var n uint32
+		if _, err := fmt.Sscanf(value, "%d", &n); err != nil || fmt.Sprint(n) != value || n == 0 {
+			return Argon2idParams{}, fmt.Errorf("invalid value of parameter %q", field)
+		}
+		switch name {
+		case "m":
+			params.Memory = n
+		case "t":
+			params.Iterations = n
+		case "p":
+			if n > 255 {
+				return Argon2idParams{}, fmt.Errorf("invalid value of parameter %q", field)
+			}
+			params.Parallelism = uint8(n)
+		default:
+			return Argon2idParams{}, fmt.Errorf("unknown parameter %q", name)
+		}
+	}
+	if params.Memory < 8*uint32(params.Parallelism) {
+		return Argon2idParams{}, errors.New("memory must be at least 8 KiB per thread")
+	}
+	return params, nil
+}
+
+func (p Argon2idParams) String() string {
+	return fmt.Sprintf("m=%d,t=%d,p=%d", p.Memory, p.Iterations, p.Parallelism)
+}
+
+// Argon2idHasher hashes passwords with argon2id into PHC strings, like
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>, which carry the parameters
+// and salt needed to check them.
+type Argon2idHasher struct {
+	Params Argon2idParams
+}
+
+var phcEncoding = base64.RawStdEncoding
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.Params.SaltLength)
+	rand.Read(salt)
+	key := argon2.IDKey([]byte(password), salt, h.Params.Iterations, h.Params.Memory, h.Params.Parallelism, h.Params.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$%v$%s$%s", argon2.Version, h.Params, phcEncoding.EncodeToString(salt), phcEncoding.EncodeToString(key)), nil
+}
+
+func (h Argon2idHasher) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+// decode splits a PHC string into its parameters, salt and key.
+func (h Argon2idHasher) decode(hash string) (Argon2idParams, []byte, []byte, error) {
+	fields := strings.Split(hash, "$")
+	if len(fields) != 6 || fields[1] != "argon2id" {
+		return Argon2idParams{}, nil, nil, errors.New("malformed argon2id hash")
+	}
+	if fields[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return Argon2idParams{}, nil, nil, fmt.Errorf("unsupported argon2id version %q", fields[2])
+	}
+	params, err := ParseArgon2idParams(fields[3])
+	if err != nil {
+		return Argon2idParams{}, nil, nil, fmt.Errorf("parsing argon2id parameters: %w", err)
+	}
+	salt, err := phcEncoding.DecodeString(fields[4])
+	if err != nil {
+		return Argon2idParams{}, nil, nil, fmt.Errorf("decoding salt: %w", err)
+	}
+	key, err := phcEncoding.DecodeString(fields[5])
+	if err != nil {
+		return Argon2idParams{}, nil, nil, fmt.Errorf("decoding key: %w", err)
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
+
+func (h Argon2idHasher) Compare(hash, password string) error {
+	params, salt, key, err := h.decode(hash)
+	if err != nil {
+		return err
+	}
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+func (h Argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, _, err := h.decode(hash)
+	return err != nil || params != h.Params
+}
+
+// BcryptHasher hashes passwords with bcrypt, which only takes into account
+// their first 72 bytes.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", fmt.Errorf("hashing password: %w", err)
+	}
+	return string(hashedPassword), nil
+}
+
+func (h BcryptHasher) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (h BcryptHasher) Compare(hash, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrPasswordMismatch
+	}
+	return err
+}
+
+func (h BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
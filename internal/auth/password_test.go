@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswords(t *testing.T) {
+	// cheap parameters keep the test fast
+	params := Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	passwords := Passwords{
+		Current: Argon2idHasher{Params: params},
+		Legacy:  []Hasher{BcryptHasher{Cost: bcrypt.MinCost}},
+	}
+	password := "thisFANTASTICpassword"
+
+	argon2idHash, err := passwords.Hash(password)
+	if err != nil {
+		t.Fatalf("can't hash password: %v", err)
+	}
+	outdatedHash, err := Argon2idHasher{Params: Argon2idParams{Memory: 32, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}}.Hash(password)
+	if err != nil {
+		t.Fatalf("can't hash password: %v", err)
+	}
+	bcryptHash, err := BcryptHasher{Cost: bcrypt.MinCost}.Hash(password)
+	if err != nil {
+		t.Fatalf("can't hash password: %v", err)
+	}
+	// bcrypt would ignore everything after the first 72 bytes
+	longPassword := strings.Repeat("a", 72)
+	longHash, err := passwords.Hash(longPassword + "b")
+	if err != nil {
+		t.Fatalf("can't hash password: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		hash          string
+		password      string
+		expectedError error
+		rehash        bool
+	}{
+		{
+			name:     "Assert argon2id hash matches",
+			hash:     argon2idHash,
+			password: password,
+		},
+		{
+			name:          "Assert wrong password doesn't match",
+			hash:          argon2idHash,
+			password:      "thisFANTASTICpasswor",
+			expectedError: ErrPasswordMismatch,
+		},
+		{
+			name:     "Assert argon2id hash with outdated parameters is rehashed",
+			hash:     outdatedHash,
+			password: password,
+			rehash:   true,
+		},
+		{
+			name:     "Assert bcrypt hash is rehashed",
+			hash:     bcryptHash,
+			password: password,
+			rehash:   true,
+		},
+		{
+			name:          "Assert wrong password doesn't match bcrypt hash",
+			hash:          bcryptHash,
+			password:      "we are checking",
+			expectedError: ErrPasswordMismatch,
+		},
+		{
+			name:          "Assert long passwords aren't truncated",
+			hash:          longHash,
+			password:      longPassword + "c",
+			expectedError: ErrPasswordMismatch,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rehash, err := passwords.Check(test.hash, test.password)
+			if !errors.Is(err, test.expectedError) {
+				t.Fatalf("got error %v when expecting %v", err, test.expectedError)
+			}
+			if rehash != test.rehash {
+				t.Errorf("got rehash %v when expecting %v", rehash, test.rehash)
+			}
+		})
+	}
+
+	if !strings.HasPrefix(argon2idHash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("got hash %q, which isn't a PHC string", argon2idHash)
+	}
+	if _, err := passwords.Check("not a valid hash", password); err == nil {
+		t.Errorf("checked a hash in an unknown format")
+	}
+}
+
+func TestParseArgon2idParams(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expected      Argon2idParams
+		expectedError bool
+	}{
+		{
+			name:     "Assert parameters are parsed",
+			input:    "m=65536,t=3,p=4",
+			expected: Argon2idParams{Memory: 65536, Iterations: 3, Parallelism: 4, SaltLength: 16, KeyLength: 32},
+		},
+		{
+			name:     "Assert missing parameters keep their default",
+			input:    "t=4",
+			expected: Argon2idParams{Memory: 19456, Iterations: 4, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		},
+		{
+			name:          "Assert unknown parameter",
+			input:         "m=65536,x=1",
+			expectedError: true,
+		},
+		{
+			name:          "Assert zero value",
+			input:         "t=0",
+			expectedError: true,
+		},
+		{
+			name:          "Assert malformed value",
+			input:         "m=64k",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params, err := ParseArgon2idParams(test.input)
+			if (err != nil) != test.expectedError {
+				t.Fatalf("got error %v", err)
+			}
+			if err == nil && params != test.expected {
+				t.Errorf("got %+v when expecting %+v", params, test.expected)
+			}
+		})
+	}
+}
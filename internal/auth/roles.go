@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Role is what a user is allowed to do beyond using their own account.
+type Role string
+
+const (
+	// RoleUser is the role every user starts with.
+	RoleUser Role = "user"
+	// RoleModerator can also delete the chirps of other users.
+	RoleModerator Role = "moderator"
+	// RoleAdmin can also use the administrator endpoints and give roles to
+	// other users.
+	RoleAdmin Role = "admin"
+)
+
+// Roles are every role, each allowed to do whatever the ones before it can.
+var Roles = []Role{RoleUser, RoleModerator, RoleAdmin}
+
+// ParseRole checks the name of a role.
+func ParseRole(name string) (Role, error) {
+	role := Role(name)
+	if !slices.Contains(Roles, role) {
+		return "", fmt.Errorf("unknown role %q", name)
+	}
+	return role, nil
+}
+
+// Includes reports whether a role is allowed to do whatever the given one
+// can. Unknown roles, like the missing role of access tokens limited to
+// scopes, include none.
+func (r Role) Includes(other Role) bool {
+	rank, otherRank := slices.Index(Roles, r), slices.Index(Roles, other)
+	return rank >= 0 && otherRank >= 0 && rank >= otherRank
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseRole(t *testing.T) {
+	tests := []struct {
+		name      string
+		expected  Role
+		expectErr bool
+	}{
+		{name: "user", expected: RoleUser},
+		{name: "moderator", expected: RoleModerator},
+		{name: "admin", expected: RoleAdmin},
+		{name: "Admin", expectErr: true},
+		{name: "", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role, err := ParseRole(tt.name)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("ParseRole() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if role != tt.expected {
+				t.Errorf("ParseRole() = %q, expected %q", role, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRoleIncludes(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     Role
+		other    Role
+		expected bool
+	}{
+		{name: "admins can do what moderators can", role: RoleAdmin, other: RoleModerator, expected: true},
+		{name: "admins can do what admins can", role: RoleAdmin, other: RoleAdmin, expected: true},
+		{name: "moderators can do what users can", role: RoleModerator, other: RoleUser, expected: true},
+		{name: "moderators can't do what admins can", role: RoleModerator, other: RoleAdmin, expected: false},
+		{name: "users can't do what moderators can", role: RoleUser, other: RoleModerator, expected: false},
+		{name: "tokens without a role can't do what users can", role: "", other: RoleUser, expected: false},
+		{name: "unknown roles include nothing", role: "root", other: RoleUser, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.role.Includes(tt.other); got != tt.expected {
+				t.Errorf("Includes() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestJWTRole(t *testing.T) {
+	tokenSecret := "this token is secret"
+
+	for _, role := range []Role{"", RoleUser, RoleAdmin} {
+		t.Run(string(role), func(t *testing.T) {
+			tokenString, err := MakeJWT(AccessToken{UserID: uuid.New(), Role: role}, tokenSecret, time.Hour)
+			if err != nil {
+				t.Fatalf("can't create JWT: %v", err)
+			}
+			accessToken, err := ValidateJWT(tokenString, tokenSecret, nil)
+			if err != nil {
+				t.Fatalf("can't parse JWT: %v", err)
+			}
+			if accessToken.Role != role {
+				t.Errorf("got role %q, expected %q", accessToken.Role, role)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Scope is a part of the API a token limited to scopes can be used for.
+type Scope string
+
+const (
+	// ScopeChirpsRead allows reading chirps as the user, like their timeline
+	// and mentions.
+	ScopeChirpsRead Scope = "chirps:read"
+	// ScopeChirpsWrite allows posting, editing, deleting, liking and
+	// rechirping chirps and following users.
+	ScopeChirpsWrite Scope = "chirps:write"
+	// ScopeAccountWrite allows changing the email, password and handle of
+	// the user.
+	ScopeAccountWrite Scope = "account:write"
+)
+
+// Scopes are every scope that can be granted, in the order they're listed.
+var Scopes = []Scope{ScopeChirpsRead, ScopeChirpsWrite, ScopeAccountWrite}
+
+// ParseScopes checks a list of scope names, dropping repetitions. At least
+// one scope must be given.
+func ParseScopes(names []string) ([]Scope, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+	scopes := []Scope{}
+	for _, name := range names {
+		scope := Scope(name)
+		if !slices.Contains(Scopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q", name)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// HasScope reports whether an access token can be used for the given scope.
+// Tokens of a login aren't limited to scopes and can be used for anything.
+func (t AccessToken) HasScope(scope Scope) bool {
+	return t.Scopes == nil || slices.Contains(t.Scopes, scope)
+}
+
+// personalAccessTokenPrefix starts every personal access token, which tells
+// them apart from JWTs and lets secret scanners find leaked ones.
+const personalAccessTokenPrefix = "chirpy_pat_"
+
+// MakePersonalAccessToken returns a random personal access token. Like
+// refresh tokens, only their hash, from HashRefreshToken, should be stored.
+func MakePersonalAccessToken() string {
+	// crypto/rand.Read never returns an error
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return personalAccessTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+}
+
+// IsPersonalAccessToken reports whether a bearer token looks like a personal
+// access token rather than a JWT.
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, personalAccessTokenPrefix)
+}
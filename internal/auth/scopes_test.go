@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		name      string
+		names     []string
+		expected  []Scope
+		expectErr bool
+	}{
+		{
+			name:     "every scope",
+			names:    []string{"chirps:read", "chirps:write", "account:write"},
+			expected: []Scope{ScopeChirpsRead, ScopeChirpsWrite, ScopeAccountWrite},
+		},
+		{
+			name:     "repeated scopes",
+			names:    []string{"chirps:read", "chirps:read"},
+			expected: []Scope{ScopeChirpsRead},
+		},
+		{
+			name:      "no scopes",
+			names:     []string{},
+			expectErr: true,
+		},
+		{
+			name:      "unknown scope",
+			names:     []string{"chirps:read", "admin"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scopes, err := ParseScopes(tt.names)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("ParseScopes() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if !slices.Equal(scopes, tt.expected) {
+				t.Errorf("ParseScopes() = %v, expected %v", scopes, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    AccessToken
+		scope    Scope
+		expected bool
+	}{
+		{
+			name:     "login tokens have every scope",
+			token:    AccessToken{},
+			scope:    ScopeAccountWrite,
+			expected: true,
+		},
+		{
+			name:     "granted scope",
+			token:    AccessToken{Scopes: []Scope{ScopeChirpsRead, ScopeChirpsWrite}},
+			scope:    ScopeChirpsWrite,
+			expected: true,
+		},
+		{
+			name:     "scope not granted",
+			token:    AccessToken{Scopes: []Scope{ScopeChirpsRead}},
+			scope:    ScopeChirpsWrite,
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.HasScope(tt.scope); got != tt.expected {
+				t.Errorf("HasScope(%q) = %v, expected %v", tt.scope, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPersonalAccessToken(t *testing.T) {
+	token := MakePersonalAccessToken()
+	if !IsPersonalAccessToken(token) {
+		t.Errorf("%q isn't recognized as a personal access token", token)
+	}
+	if token == MakePersonalAccessToken() {
+		t.Error("two personal access tokens are the same")
+	}
+	if IsPersonalAccessToken("eyJhbGciOiJIUzI1NiJ9.e30.c2lnbmF0dXJl") {
+		t.Error("a JWT is recognized as a personal access token")
+	}
+}
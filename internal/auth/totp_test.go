@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTP(t *testing.T) {
+	// the SHA-1 secret of the test vectors in RFC 6238, whose codes are the
+	// last 6 digits of the 8 given there
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		name         string
+		at           time.Time
+		code         string
+		lastStep     int64
+		expectedStep int64
+		expectedErr  error
+	}{
+		{
+			name:         "Assert RFC 6238 vector at 59",
+			at:           time.Unix(59, 0),
+			code:         "287082",
+			expectedStep: 1,
+		},
+		{
+			name:         "Assert RFC 6238 vector at 1111111109",
+			at:           time.Unix(1111111109, 0),
+			code:         "081804",
+			expectedStep: 37037036,
+		},
+		{
+			name:         "Assert RFC 6238 vector at 1234567890",
+			at:           time.Unix(1234567890, 0),
+			code:         "005924",
+			expectedStep: 41152263,
+		},
+		{
+			name:         "Assert code of the previous period is accepted",
+			at:           time.Unix(89, 0),
+			code:         "287 082",
+			expectedStep: 1,
+		},
+		{
+			name:        "Assert code two periods old is rejected",
+			at:          time.Unix(119, 0),
+			code:        "287082",
+			expectedErr: ErrInvalidTOTPCode,
+		},
+		{
+			name:        "Assert used code is rejected",
+			at:          time.Unix(59, 0),
+			code:        "287082",
+			lastStep:    1,
+			expectedErr: ErrInvalidTOTPCode,
+		},
+		{
+			name:        "Assert wrong code is rejected",
+			at:          time.Unix(59, 0),
+			code:        "287083",
+			expectedErr: ErrInvalidTOTPCode,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			step, err := ValidateTOTP(secret, test.code, test.at, test.lastStep)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("got error %v when expecting %v", err, test.expectedErr)
+			}
+			if step != test.expectedStep {
+				t.Errorf("got step %d when expecting %d", step, test.expectedStep)
+			}
+		})
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("Chirpy", "user@example.com", "JBSWY3DPEHPK3PXP")
+	expected := "otpauth://totp/Chirpy:user@example.com?algorithm=SHA1&digits=6&issuer=Chirpy&period=30&secret=JBSWY3DPEHPK3PXP"
+	if uri != expected {
+		t.Errorf("got %q when expecting %q", uri, expected)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes := GenerateRecoveryCodes(10)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("got malformed code %q", code)
+		}
+		if seen[code] {
+			t.Errorf("got repeated code %q", code)
+		}
+		seen[code] = true
+	}
+
+	if HashRecoveryCode(codes[0]) != HashRecoveryCode(" "+strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))) {
+		t.Errorf("hash of %q depends on its format", codes[0])
+	}
+}
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// Issuer is the issuer of the access tokens we sign.
+	Issuer = "chirpy"
+	// Audience is the audience of the access tokens we sign: the Chirpy API.
+	Audience = "chirpy-api"
+	// DefaultLeeway is how far apart the clocks of the servers issuing and
+	// validating a token may be.
+	DefaultLeeway = 30 * time.Second
+)
+
+// Errors returned when validating access tokens. They are wrapped along with
+// the underlying error, so they must be checked with errors.Is.
+var (
+	ErrTokenMalformed        = errors.New("token is malformed")
+	ErrTokenExpired          = errors.New("token is expired")
+	ErrTokenNotValidYet      = errors.New("token isn't valid yet")
+	ErrTokenSignatureInvalid = errors.New("token signature is invalid")
+	ErrTokenWrongIssuer      = errors.New("token has the wrong issuer")
+	ErrTokenWrongAudience    = errors.New("token has the wrong audience")
+	ErrTokenMissingClaim     = errors.New("token is missing a required claim")
+	// ErrTokenRevoked is returned when validating an access token that was
+	// issued before the credentials of its user changed.
+	ErrTokenRevoked = errors.New("token was revoked")
+)
+
+// Validator checks access tokens against what we expect of them.
+type Validator struct {
+	// Issuer is the expected issuer. When empty, it isn't checked.
+	Issuer string
+	// Audiences are the audiences we accept, of which the token must name at
+	// least one. When empty, the audience isn't checked.
+	Audiences []string
+	// Algorithms are the signing algorithms we accept. When empty, a token may
+	// use any algorithm, though always the one of the key that signed it.
+	Algorithms []string
+	// Leeway is added to the expiration time and subtracted from the not
+	// before and issued at times of the token.
+	Leeway time.Duration
+	// RequiredClaims are the names of the claims the token must have, like
+	// "exp".
+	RequiredClaims []string
+}
+
+// DefaultValidator returns a validator for the access tokens we sign.
+func DefaultValidator() Validator {
+	return Validator{
+		Issuer:         Issuer,
+		Audiences:      []string{Audience},
+		Algorithms:     []string{"RS256", "ES256", "ES384", "EdDSA", "HS256"},
+		Leeway:         DefaultLeeway,
+		RequiredClaims: []string{"sub", "exp", "iat"},
+	}
+}
+
+// Validate checks the signature, timestamps and claims of an access token,
+// which must be signed by a key of the set that isn't retired. When
+// tokenVersion isn't nil, it's called with the ID of the user to get their
+// current token version, and tokens issued with an older one are rejected with
+// ErrTokenRevoked.
+func (v Validator) Validate(keys *KeySet, tokenString string, tokenVersion func(userID uuid.UUID) (int32, error)) (AccessToken, error) {
+	options := []jwt.ParserOption{jwt.WithLeeway(v.Leeway), jwt.WithIssuedAt()}
+	if len(v.Algorithms) > 0 {
+		options = append(options, jwt.WithValidMethods(v.Algorithms))
+	}
+	token, err := jwt.NewParser(options...).ParseWithClaims(tokenString, &claims{}, keys.keyFunc)
+	if err != nil {
+		return AccessToken{}, validationError(err)
+	}
+
+	claims, ok := token.Claims.(*claims)
+	if !ok {
+		return AccessToken{}, errors.New("unknown claims type, can't proceed")
+	}
+
+	if err := v.checkRequiredClaims(tokenString); err != nil {
+		return AccessToken{}, err
+	}
+	if v.Issuer != "" && claims.Issuer != v.Issuer {
+		return AccessToken{}, fmt.Errorf("%w: got %q", ErrTokenWrongIssuer, claims.Issuer)
+	}
+	if len(v.Audiences) > 0 && !slices.ContainsFunc(claims.Audience, func(audience string) bool {
+		return slices.Contains(v.Audiences, audience)
+	}) {
+		return AccessToken{}, fmt.Errorf("%w: got %q", ErrTokenWrongAudience, claims.Audience)
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return AccessToken{}, fmt.Errorf("%w: transforming user id of type string into uuid type: %w", ErrTokenMalformed, err)
+	}
+
+	accessToken := AccessToken{UserID: userID, TokenVersion: claims.TokenVersion}
+	// validators that don't require them may accept tokens without these
+	if claims.IssuedAt != nil {
+		accessToken.IssuedAt = claims.IssuedAt.Time
+	}
+	if claims.ExpiresAt != nil {
+		accessToken.ExpiresAt = claims.ExpiresAt.Time
+	}
+	if claims.SessionID != "" {
+		accessToken.SessionID, err = uuid.Parse(claims.SessionID)
+		if err != nil {
+			return AccessToken{}, fmt.Errorf("%w: transforming session id of type string into uuid type: %w", ErrTokenMalformed, err)
+		}
+	}
+	if claims.Scope != "" {
+		accessToken.Scopes, err = ParseScopeString(claims.Scope)
+		if err != nil {
+			return AccessToken{}, fmt.Errorf("%w: parsing scopes: %w", ErrTokenMalformed, err)
+		}
+	}
+	if claims.ClientID != "" {
+		accessToken.ClientID, err = uuid.Parse(claims.ClientID)
+		if err != nil {
+			return AccessToken{}, fmt.Errorf("%w: transforming client id of type string into uuid type: %w", ErrTokenMalformed, err)
+		}
+	}
+	if claims.Role != "" {
+		accessToken.Role, err = ParseRole(claims.Role)
+		if err != nil {
+			return AccessToken{}, fmt.Errorf("%w: %w", ErrTokenMalformed, err)
+		}
+	}
+
+	if tokenVersion != nil {
+		currentVersion, err := tokenVersion(userID)
+		if err != nil {
+			return AccessToken{}, fmt.Errorf("getting token version of user: %w", err)
+		}
+		if accessToken.TokenVersion < currentVersion {
+			return AccessToken{}, ErrTokenRevoked
+		}
+	}
+
+	return accessToken, nil
+}
+
+// checkRequiredClaims checks that a token, whose signature was already
+// verified, has every required claim.
+func (v Validator) checkRequiredClaims(tokenString string) error {
+	if len(v.RequiredClaims) == 0 {
+		return nil
+	}
+	mapClaims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, mapClaims); err != nil {
+		return validationError(err)
+	}
+	for _, name := range v.RequiredClaims {
+		if _, ok := mapClaims[name]; !ok {
+			return fmt.Errorf("%w: %q", ErrTokenMissingClaim, name)
+		}
+	}
+	return nil
+}
+
+// validationError wraps an error returned by the JWT parser with the error of
+// ours that describes it.
+func validationError(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return fmt.Errorf("%w: %w", ErrTokenExpired, err)
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return fmt.Errorf("%w: %w", ErrTokenNotValidYet, err)
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return fmt.Errorf("%w: %w", ErrTokenSignatureInvalid, err)
+	default:
+		return fmt.Errorf("%w: %w", ErrTokenMalformed, err)
+	}
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestValidator(t *testing.T) {
+	tokenSecret := "this token is secret"
+	keys, err := hmacKeySet(tokenSecret)
+	if err != nil {
+		t.Fatalf("can't create key set: %v", err)
+	}
+	now := time.Now()
+
+	// validClaims returns the claims of a token the default validator accepts
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss": Issuer,
+			"aud": []string{Audience},
+			"sub": uuid.NewString(),
+			"iat": now.Unix(),
+			"exp": now.Add(time.Hour).Unix(),
+		}
+	}
+
+	tests := []struct {
+		name          string
+		claims        func() jwt.MapClaims
+		secret        string
+		validator     func() Validator
+		expectedError error
+	}{
+		{
+			name:          "Assert valid token",
+			claims:        validClaims,
+			expectedError: nil,
+		},
+		{
+			name: "Assert expired token",
+			claims: func() jwt.MapClaims {
+				claims := validClaims()
+				claims["exp"] = now.Add(-time.Minute).Unix()
+				return claims
+			},
+			expectedError: ErrTokenExpired,
+		},
+		{
+			name: "Assert token expired within the leeway",
+			claims: func() jwt.MapClaims {
+				claims := validClaims()
+				claims["exp"] = now.Add(-DefaultLeeway / 2).Unix()
+				return claims
+			},
+			expectedError: nil,
+		},
+		{
+			name: "Assert token issued in the future",
+			claims: func() jwt.MapClaims {
+				claims := validClaims()
+				claims["iat"] = now.Add(time.Minute).Unix()
+				return claims
+			},
+			expectedError: ErrTokenNotValidYet,
+		},
+		{
+			name: "Assert token not valid before some time",
+			claims: func() jwt.MapClaims {
+				claims := validClaims()
+				claims["nbf"] = now.Add(time.Minute).Unix()
+				return claims
+			},
+			expectedError: ErrTokenNotValidYet,
+		},
+		{
+			name:          "Assert bad signature",
+			claims:        validClaims,
+			secret:        "this token is super mega secret and wrong",
+			expectedError: ErrTokenSignatureInvalid,
+		},
+		{
+			name: "Assert wrong issuer",
+			claims: func() jwt.MapClaims {
+				claims := validClaims()
+				claims["iss"] = "someone"
+				return claims
+			},
+			expectedError: ErrTokenWrongIssuer,
+		},
+		{
+			name: "Assert wrong audience",
+			claims: func() jwt.MapClaims {
+				claims := validClaims()
+				claims["aud"] = []string{"another-api"}
+				return claims
+			},
+			expectedError: ErrTokenWrongAudience,
+		},
+		{
+			name: "Assert any accepted audience is enough",
+			claims: func() jwt.MapClaims {
+				claims := validClaims()
+				claims["aud"] = []string{"another-api", "reports"}
+				return claims
+			},
+			validator: func() Validator {
+				validator := DefaultValidator()
+				validator.Audiences = []string{Audience, "reports"}
+				return validator
+			},
+			expectedError: nil,
+		},
+		{
+			name: "Assert missing required claim",
+			claims: func() jwt.MapClaims {
+				claims := validClaims()
+				delete(claims, "exp")
+				return claims
+			},
+			expectedError: ErrTokenMissingClaim,
+		},
+		{
+			name:   "Assert algorithm not allowed",
+			claims: validClaims,
+			validator: func() Validator {
+				validator := DefaultValidator()
+				validator.Algorithms = []string{"EdDSA"}
+				return validator
+			},
+			expectedError: ErrTokenSignatureInvalid,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			secret := test.secret
+			if secret == "" {
+				secret = tokenSecret
+			}
+			tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, test.claims()).SignedString([]byte(secret))
+			if err != nil {
+				t.Fatalf("can't create JWT: %v", err)
+			}
+
+			validator := DefaultValidator()
+			if test.validator != nil {
+				validator = test.validator()
+			}
+			_, err = validator.Validate(keys, tokenString, nil)
+			if !errors.Is(err, test.expectedError) {
+				t.Errorf("got error %v when expecting %v", err, test.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package chirptext
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxHashtagLength is the longest hashtag, in characters, we keep track of.
+// Longer ones are ignored altogether instead of being cut short.
+const MaxHashtagLength = 100
+
+// Hashtags returns the normalized hashtags found in a chirp, in order of
+// appearance and without repetitions.
+//
+// A hashtag is a # followed by letters, digits, combining marks or
+// underscores, in any script, with at least one letter among them. The # must
+// be at the start of the text or follow a character that can't be part of a
+// word, so neither `C#` nor `issue#12` count.
+func Hashtags(body string) []string {
+	tags := []string{}
+	seen := map[string]struct{}{}
+
+	for _, entity := range scanEntities(body, '#') {
+		if !strings.ContainsFunc(entity.Text, unicode.IsLetter) {
+			continue
+		}
+		if utf8.RuneCountInString(entity.Text) > MaxHashtagLength {
+			continue
+		}
+		tag := NormalizeHashtag(entity.Text)
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// NormalizeHashtag maps every way of writing a hashtag to a single form so
+// that #Café, #CAFÉ and #café are considered the same tag. A leading #
+// is dropped.
+func NormalizeHashtag(tag string) string {
+	tag = strings.TrimPrefix(tag, "#")
+	return norm.NFC.String(strings.ToLower(norm.NFKC.String(tag)))
+}
+
+// entity is a run of word characters that follows a sigil like # or @.
+type entity struct {
+	Text string
+	// Start and End are offsets, in bytes, of the entity in the text,
+	// sigil included
+	Start int
+	End   int
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_'
+}
+
+// scanEntities finds the words in text prefixed with sigil.
+func scanEntities(text string, sigil rune) []entity {
+	var entities []entity
+	previous := ' '
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if r != sigil || isWordRune(previous) || previous == sigil {
+			previous = r
+			i += size
+			continue
+		}
+
+		end := i + size
+		for end < len(text) {
+			next, nextSize := utf8.DecodeRuneInString(text[end:])
+			if !isWordRune(next) {
+				break
+			}
+			end += nextSize
+		}
+
+		if end > i+size {
+			entities = append(entities, entity{Text: text[i+size : end], Start: i, End: end})
+		}
+		previous = r
+		if end > i+size {
+			previous, _ = utf8.DecodeLastRuneInString(text[:end])
+		}
+		i = end
+	}
+
+	return entities
+}
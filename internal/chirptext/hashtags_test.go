@@ -0,0 +1,91 @@
+package chirptext
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestHashtags(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected []string
+	}{
+		{
+			name:     "no hashtags",
+			body:     "just chirping",
+			expected: []string{},
+		},
+		{
+			name:     "hashtags anywhere",
+			body:     "#golang is fun, #gophers! (#chirpy)",
+			expected: []string{"golang", "gophers", "chirpy"},
+		},
+		{
+			name:     "repeated hashtags are normalized and kept once",
+			body:     "#Go #GO #go",
+			expected: []string{"go"},
+		},
+		{
+			name:     "unicode hashtags",
+			body:     "#Café #日本語 #ñandú #Straße",
+			expected: []string{"café", "日本語", "ñandú", "straße"},
+		},
+		{
+			name:     "combining marks",
+			body:     "#café #café",
+			expected: []string{"café"},
+		},
+		{
+			name:     "underscores and digits",
+			body:     "#web_dev #2024goals",
+			expected: []string{"web_dev", "2024goals"},
+		},
+		{
+			name:     "numbers alone aren't hashtags",
+			body:     "we're #1 #2024",
+			expected: []string{},
+		},
+		{
+			name:     "sigil must start a word",
+			body:     "C# and issue#12 and ##double",
+			expected: []string{},
+		},
+		{
+			name:     "lone sigils",
+			body:     "# #! #",
+			expected: []string{},
+		},
+		{
+			name:     "hashtags that are too long are ignored",
+			body:     "#" + strings.Repeat("a", MaxHashtagLength+1) + " #ok",
+			expected: []string{"ok"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Hashtags(test.body); !slices.Equal(got, test.expected) {
+				t.Errorf("got %q when expecting %q", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizeHashtag(t *testing.T) {
+	tests := []struct {
+		tag      string
+		expected string
+	}{
+		{tag: "#GoLang", expected: "golang"},
+		{tag: "ＧＯ", expected: "go"},
+		{tag: "café", expected: "café"},
+	}
+
+	for _, test := range tests {
+		if got := NormalizeHashtag(test.tag); got != test.expected {
+			t.Errorf("got %q when expecting %q", got, test.expected)
+		}
+	}
+}
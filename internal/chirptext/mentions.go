@@ -0,0 +1,58 @@
+package chirptext
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxHandleLength is the longest handle, in characters, a user can pick.
+const MaxHandleLength = 30
+
+// Mention is an @handle found in a chirp. Start and End delimit it, @
+// included, as offsets in Unicode code points so clients can highlight it.
+type Mention struct {
+	Handle string
+	Start  int
+	End    int
+}
+
+// ValidHandle reports whether handle can be picked by a user: it must be made
+// of 1 to MaxHandleLength ASCII letters, digits or underscores.
+func ValidHandle(handle string) bool {
+	if handle == "" || len(handle) > MaxHandleLength {
+		return false
+	}
+	for _, r := range handle {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeHandle maps a handle to the form used to compare handles, which
+// are case insensitive. A leading @ is dropped.
+func NormalizeHandle(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(handle, "@"))
+}
+
+// Mentions returns every @handle in a chirp, in order of appearance. An @
+// that follows a word character, like the one of an email address, doesn't
+// start a mention, and neither does one followed by an invalid handle.
+func Mentions(body string) []Mention {
+	mentions := []Mention{}
+
+	for _, entity := range scanEntities(body, '@') {
+		if !ValidHandle(entity.Text) {
+			continue
+		}
+		start := utf8.RuneCountInString(body[:entity.Start])
+		mentions = append(mentions, Mention{
+			Handle: entity.Text,
+			Start:  start,
+			End:    start + utf8.RuneCountInString(body[entity.Start:entity.End]),
+		})
+	}
+
+	return mentions
+}
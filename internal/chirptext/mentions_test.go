@@ -0,0 +1,85 @@
+package chirptext
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected []Mention
+	}{
+		{
+			name:     "no mentions",
+			body:     "just chirping",
+			expected: []Mention{},
+		},
+		{
+			name: "mentions anywhere",
+			body: "@alice meet @Bob_2, (@carol)!",
+			expected: []Mention{
+				{Handle: "alice", Start: 0, End: 6},
+				{Handle: "Bob_2", Start: 12, End: 18},
+				{Handle: "carol", Start: 21, End: 27},
+			},
+		},
+		{
+			name: "repeated mentions are all reported",
+			body: "@alice @alice",
+			expected: []Mention{
+				{Handle: "alice", Start: 0, End: 6},
+				{Handle: "alice", Start: 7, End: 13},
+			},
+		},
+		{
+			name: "offsets count code points",
+			body: "¡Hola, ñandú! @alice",
+			expected: []Mention{
+				{Handle: "alice", Start: 14, End: 20},
+			},
+		},
+		{
+			name:     "email addresses aren't mentions",
+			body:     "write to alice@example.com",
+			expected: []Mention{},
+		},
+		{
+			name:     "invalid handles aren't mentions",
+			body:     "@ñandú @" + strings.Repeat("a", MaxHandleLength+1) + " @@alice",
+			expected: []Mention{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Mentions(test.body); !slices.Equal(got, test.expected) {
+				t.Errorf("got %v when expecting %v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestValidHandle(t *testing.T) {
+	tests := []struct {
+		handle   string
+		expected bool
+	}{
+		{handle: "alice", expected: true},
+		{handle: "Bob_2", expected: true},
+		{handle: strings.Repeat("a", MaxHandleLength), expected: true},
+		{handle: "", expected: false},
+		{handle: strings.Repeat("a", MaxHandleLength+1), expected: false},
+		{handle: "ñandú", expected: false},
+		{handle: "bob smith", expected: false},
+		{handle: "@alice", expected: false},
+	}
+
+	for _, test := range tests {
+		if got := ValidHandle(test.handle); got != test.expected {
+			t.Errorf("ValidHandle(%q): got %v when expecting %v", test.handle, got, test.expected)
+		}
+	}
+}
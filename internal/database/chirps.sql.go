@@ -7,13 +7,16 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const deleteChirpByID = `-- name: DeleteChirpByID :exec
 DELETE FROM chirps
 WHERE id = $1
+   OR (rechirp_of = $1 AND NOT is_quote)
 `
 
 func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) error {
@@ -21,8 +24,40 @@ func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const editChirp = `-- name: EditChirp :one
+UPDATE chirps
+SET body = $1,
+    updated_at = now() AT TIME ZONE 'UTC',
+    edited_at = now() AT TIME ZONE 'UTC'
+WHERE id = $2
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, thread_id, rechirp_of, is_quote, edited_at
+`
+
+type EditChirpParams struct {
+	Body string
+	ID   uuid.UUID
+}
+
+func (q *Queries) EditChirp(ctx context.Context, arg EditChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, editChirp, arg.Body, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.ThreadID,
+		&i.RechirpOf,
+		&i.IsQuote,
+		&i.EditedAt,
+	)
+	return i, err
+}
+
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, rechirp_of, is_quote, edited_at
 FROM chirps
 WHERE id = $1
 `
@@ -36,18 +71,190 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.ThreadID,
+		&i.RechirpOf,
+		&i.IsQuote,
+		&i.EditedAt,
+	)
+	return i, err
+}
+
+const getChirpByIDForUpdate = `-- name: GetChirpByIDForUpdate :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, rechirp_of, is_quote, edited_at
+FROM chirps
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetChirpByIDForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByIDForUpdate, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.ThreadID,
+		&i.RechirpOf,
+		&i.IsQuote,
+		&i.EditedAt,
 	)
 	return i, err
 }
 
-const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, rechirp_of, is_quote, edited_at
+FROM chirps
+WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.RechirpOf,
+			&i.IsQuote,
+			&i.EditedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRechirpCounts = `-- name: GetRechirpCounts :many
+SELECT
+    rechirp_of::uuid AS chirp_id,
+    COUNT(*) FILTER (WHERE NOT is_quote) AS rechirp_count,
+    COUNT(*) FILTER (WHERE is_quote) AS quote_count
+FROM chirps
+WHERE rechirp_of = ANY($1::uuid[])
+GROUP BY rechirp_of
+`
+
+type GetRechirpCountsRow struct {
+	ChirpID      uuid.UUID
+	RechirpCount int64
+	QuoteCount   int64
+}
+
+func (q *Queries) GetRechirpCounts(ctx context.Context, chirpIds []uuid.UUID) ([]GetRechirpCountsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRechirpCounts, pq.Array(chirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRechirpCountsRow
+	for rows.Next() {
+		var i GetRechirpCountsRow
+		if err := rows.Scan(
+			&i.ChirpID,
+			&i.RechirpCount,
+			&i.QuoteCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getThread = `-- name: GetThread :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, rechirp_of, is_quote, edited_at
+FROM chirps
+WHERE thread_id = $1
+ORDER BY created_at ASC, id ASC
+`
+
+func (q *Queries) GetThread(ctx context.Context, threadID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getThread, threadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.RechirpOf,
+			&i.IsQuote,
+			&i.EditedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsAscending = `-- name: ListChirpsAscending :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, rechirp_of, is_quote, edited_at
 FROM chirps
-ORDER BY created_at ASC
+WHERE ($1::uuid IS NULL OR user_id = $1)
+  AND (
+    $2::timestamp IS NULL
+    OR (created_at, id) > ($2, $3::uuid)
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT $4
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+type ListChirpsAscendingParams struct {
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageSize        int32
+}
+
+func (q *Queries) ListChirpsAscending(ctx context.Context, arg ListChirpsAscendingParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAscending,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -61,6 +268,11 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.RechirpOf,
+			&i.IsQuote,
+			&i.EditedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -75,14 +287,32 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getChirpsByAuthor = `-- name: GetChirpsByAuthor :many
-SELECT id, created_at, updated_at, body, user_id
+const listChirpsDescending = `-- name: ListChirpsDescending :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, rechirp_of, is_quote, edited_at
 FROM chirps
-WHERE user_id = $1
+WHERE ($1::uuid IS NULL OR user_id = $1)
+  AND (
+    $2::timestamp IS NULL
+    OR (created_at, id) < ($2, $3::uuid)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT $4
 `
 
-func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByAuthor, userID)
+type ListChirpsDescendingParams struct {
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageSize        int32
+}
+
+func (q *Queries) ListChirpsDescending(ctx context.Context, arg ListChirpsDescendingParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDescending,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -96,6 +326,129 @@ func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.RechirpOf,
+			&i.IsQuote,
+			&i.EditedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTimelineAscending = `-- name: ListTimelineAscending :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.thread_id, chirps.rechirp_of, chirps.is_quote, chirps.edited_at
+FROM chirps
+JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = $1
+  AND (
+    $2::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > ($2, $3::uuid)
+  )
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT $4
+`
+
+type ListTimelineAscendingParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageSize        int32
+}
+
+func (q *Queries) ListTimelineAscending(ctx context.Context, arg ListTimelineAscendingParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTimelineAscending,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.RechirpOf,
+			&i.IsQuote,
+			&i.EditedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTimelineDescending = `-- name: ListTimelineDescending :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.thread_id, chirps.rechirp_of, chirps.is_quote, chirps.edited_at
+FROM chirps
+JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = $1
+  AND (
+    $2::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < ($2, $3::uuid)
+  )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $4
+`
+
+type ListTimelineDescendingParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageSize        int32
+}
+
+func (q *Queries) ListTimelineDescending(ctx context.Context, arg ListTimelineDescendingParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTimelineDescending,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.RechirpOf,
+			&i.IsQuote,
+			&i.EditedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -111,24 +464,42 @@ func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Ch
 }
 
 const saveChirp = `-- name: SaveChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (
-    gen_random_uuid(),
+WITH new_chirp AS (
+    SELECT gen_random_uuid() AS id
+)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, thread_id, rechirp_of, is_quote)
+SELECT
+    new_chirp.id,
     now() AT TIME ZONE 'UTC',
     now() AT TIME ZONE 'UTC',
     $1,
-    $2
-)
-RETURNING id, created_at, updated_at, body, user_id
+    $2,
+    $3,
+    COALESCE($4::uuid, new_chirp.id),
+    $5,
+    $6
+FROM new_chirp
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, thread_id, rechirp_of, is_quote, edited_at
 `
 
 type SaveChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	InReplyTo uuid.NullUUID
+	ThreadID  uuid.NullUUID
+	RechirpOf uuid.NullUUID
+	IsQuote   bool
 }
 
 func (q *Queries) SaveChirp(ctx context.Context, arg SaveChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, saveChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, saveChirp,
+		arg.Body,
+		arg.UserID,
+		arg.InReplyTo,
+		arg.ThreadID,
+		arg.RechirpOf,
+		arg.IsQuote,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -136,6 +507,11 @@ func (q *Queries) SaveChirp(ctx context.Context, arg SaveChirpParams) (Chirp, er
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.ThreadID,
+		&i.RechirpOf,
+		&i.IsQuote,
+		&i.EditedAt,
 	)
 	return i, err
 }
@@ -8,59 +8,243 @@ package database
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const getRefreshToken = `-- name: GetRefreshToken :one
-SELECT token, created_at, updated_at, user_id, expires_at, revoked_at
+SELECT token_hash, created_at, updated_at, user_id, expires_at, revoked_at, family_id, rotated_at, user_agent, ip_address, device_name, last_used_at, client_id, scopes
 FROM refresh_tokens
-WHERE token = $1
+WHERE token_hash = $1
 `
 
-func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, getRefreshToken, token)
+func (q *Queries) GetRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshToken, tokenHash)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
+		&i.TokenHash,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.RotatedAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.DeviceName,
+		&i.LastUsedAt,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
 	)
 	return i, err
 }
 
+const listSessions = `-- name: ListSessions :many
+SELECT
+    family_id,
+    (
+        SELECT MIN(family.created_at)
+        FROM refresh_tokens AS family
+        WHERE family.family_id = refresh_tokens.family_id
+    )::timestamp AS signed_in_at,
+    last_used_at,
+    expires_at,
+    user_agent,
+    ip_address,
+    device_name,
+    client_id
+FROM refresh_tokens
+WHERE user_id = $1
+  AND revoked_at IS NULL
+  AND expires_at > now() AT TIME ZONE 'UTC'
+ORDER BY last_used_at DESC, family_id
+`
+
+type ListSessionsRow struct {
+	FamilyID   uuid.UUID
+	SignedInAt time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+	UserAgent  string
+	IpAddress  string
+	DeviceName sql.NullString
+	ClientID   uuid.NullUUID
+}
+
+func (q *Queries) ListSessions(ctx context.Context, userID uuid.UUID) ([]ListSessionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listSessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSessionsRow
+	for rows.Next() {
+		var i ListSessionsRow
+		if err := rows.Scan(
+			&i.FamilyID,
+			&i.SignedInAt,
+			&i.LastUsedAt,
+			&i.ExpiresAt,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.DeviceName,
+			&i.ClientID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const revokeAccess = `-- name: RevokeAccess :execresult
 UPDATE refresh_tokens
 SET updated_at = now() AT TIME ZONE 'UTC',
     revoked_at = now() AT TIME ZONE 'UTC'
-WHERE token = $1
+WHERE token_hash = $1
+`
+
+func (q *Queries) RevokeAccess(ctx context.Context, tokenHash string) (sql.Result, error) {
+	return q.db.ExecContext(ctx, revokeAccess, tokenHash)
+}
+
+const revokeAllSessions = `-- name: RevokeAllSessions :exec
+UPDATE refresh_tokens
+SET updated_at = now() AT TIME ZONE 'UTC',
+    revoked_at = now() AT TIME ZONE 'UTC'
+WHERE user_id = $1
+  AND revoked_at IS NULL
 `
 
-func (q *Queries) RevokeAccess(ctx context.Context, token string) (sql.Result, error) {
-	return q.db.ExecContext(ctx, revokeAccess, token)
+func (q *Queries) RevokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeAllSessions, userID)
+	return err
+}
+
+const revokeOtherSessions = `-- name: RevokeOtherSessions :exec
+UPDATE refresh_tokens
+SET updated_at = now() AT TIME ZONE 'UTC',
+    revoked_at = now() AT TIME ZONE 'UTC'
+WHERE user_id = $1
+  AND family_id <> $2
+  AND revoked_at IS NULL
+`
+
+type RevokeOtherSessionsParams struct {
+	UserID       uuid.UUID
+	KeepFamilyID uuid.UUID
+}
+
+func (q *Queries) RevokeOtherSessions(ctx context.Context, arg RevokeOtherSessionsParams) error {
+	_, err := q.db.ExecContext(ctx, revokeOtherSessions, arg.UserID, arg.KeepFamilyID)
+	return err
+}
+
+const revokeSession = `-- name: RevokeSession :execrows
+UPDATE refresh_tokens
+SET updated_at = now() AT TIME ZONE 'UTC',
+    revoked_at = now() AT TIME ZONE 'UTC'
+WHERE family_id = $1
+  AND user_id = $2
+  AND revoked_at IS NULL
+`
+
+type RevokeSessionParams struct {
+	FamilyID uuid.UUID
+	UserID   uuid.UUID
+}
+
+func (q *Queries) RevokeSession(ctx context.Context, arg RevokeSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeSession, arg.FamilyID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeTokenFamily = `-- name: RevokeTokenFamily :exec
+UPDATE refresh_tokens
+SET updated_at = now() AT TIME ZONE 'UTC',
+    revoked_at = now() AT TIME ZONE 'UTC'
+WHERE family_id = $1
+  AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeTokenFamily, familyID)
+	return err
+}
+
+const rotateRefreshToken = `-- name: RotateRefreshToken :execrows
+UPDATE refresh_tokens
+SET updated_at = now() AT TIME ZONE 'UTC',
+    revoked_at = now() AT TIME ZONE 'UTC',
+    rotated_at = now() AT TIME ZONE 'UTC'
+WHERE token_hash = $1
+  AND revoked_at IS NULL
+`
+
+func (q *Queries) RotateRefreshToken(ctx context.Context, tokenHash string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rotateRefreshToken, tokenHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 const storeRefreshToken = `-- name: StoreRefreshToken :exec
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
+INSERT INTO refresh_tokens (
+    token_hash, created_at, updated_at, user_id, expires_at, family_id,
+    user_agent, ip_address, device_name, last_used_at, client_id, scopes
+)
 VALUES (
     $1,
     now() AT TIME ZONE 'UTC',
     now() AT TIME ZONE 'UTC',
     $2,
-    now() AT TIME ZONE 'UTC' + $3::integer * INTERVAL '1 day'
+    now() AT TIME ZONE 'UTC' + $3::integer * INTERVAL '1 day',
+    $4,
+    $5,
+    $6,
+    $7,
+    now() AT TIME ZONE 'UTC',
+    $8,
+    $9::text[]
 )
 `
 
 type StoreRefreshTokenParams struct {
-	Token  string
-	UserID uuid.UUID
-	Days   int32
+	TokenHash  string
+	UserID     uuid.UUID
+	Days       int32
+	FamilyID   uuid.UUID
+	UserAgent  string
+	IpAddress  string
+	DeviceName sql.NullString
+	ClientID   uuid.NullUUID
+	Scopes     []string
 }
 
 func (q *Queries) StoreRefreshToken(ctx context.Context, arg StoreRefreshTokenParams) error {
-	_, err := q.db.ExecContext(ctx, storeRefreshToken, arg.Token, arg.UserID, arg.Days)
+	_, err := q.db.ExecContext(ctx, storeRefreshToken,
+		arg.TokenHash,
+		arg.UserID,
+		arg.Days,
+		arg.FamilyID,
+		arg.UserAgent,
+		arg.IpAddress,
+		arg.DeviceName,
+		arg.ClientID,
+		pq.Array(arg.Scopes),
+	)
 	return err
 }
@@ -0,0 +1,194 @@
+// Package lockout slows down password guessing by tracking failed attempts,
+// making clients wait longer after each one and locking them out for a while
+// after too many.
+package lockout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Policy is how failed attempts are punished.
+type Policy struct {
+	// FreeAttempts is how many failures in a row are allowed before a
+	// client has to wait between attempts
+	FreeAttempts int
+	// BaseDelay is the wait after the first failure past the free ones. It
+	// doubles with each failure after that, up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutAttempts is how many failures in a row lock the client out for
+	// LockoutDuration. Each failure after the lockout ends locks it again
+	LockoutAttempts int
+	LockoutDuration time.Duration
+	// Window is how long failures are remembered for after the last one
+	Window time.Duration
+}
+
+// Validate checks that the policy makes sense.
+func (p Policy) Validate() error {
+	switch {
+	case p.FreeAttempts < 0:
+		return errors.New("free attempts can't be negative")
+	case p.LockoutAttempts <= p.FreeAttempts:
+		return fmt.Errorf("lockout after %d attempts leaves no room for the %d free ones", p.LockoutAttempts, p.FreeAttempts)
+	case p.BaseDelay <= 0 || p.MaxDelay < p.BaseDelay:
+		return fmt.Errorf("delays must be positive and the maximum delay %v can't be shorter than the base delay %v", p.MaxDelay, p.BaseDelay)
+	case p.LockoutDuration <= 0:
+		return errors.New("lockout duration must be positive")
+	case p.Window < p.LockoutDuration:
+		return fmt.Errorf("failures must be remembered for longer than the lockout duration %v", p.LockoutDuration)
+	}
+	return nil
+}
+
+// State is what's known of the failed attempts of a client.
+type State struct {
+	// Failures is how many attempts in a row failed
+	Failures    int
+	LastFailure time.Time
+}
+
+// current returns the state as it stands at now, forgetting failures that
+// are too old.
+func (p Policy) current(state State, now time.Time) State {
+	if state.Failures > 0 && now.Sub(state.LastFailure) > p.Window {
+		return State{}
+	}
+	return state
+}
+
+// RetryAt returns when a client in the given state can try again.
+func (p Policy) RetryAt(state State) time.Time {
+	switch {
+	case state.Failures >= p.LockoutAttempts:
+		return state.LastFailure.Add(p.LockoutDuration)
+	case state.Failures > p.FreeAttempts:
+		delay := p.BaseDelay
+		for i := p.FreeAttempts + 1; i < state.Failures && delay < p.MaxDelay; i++ {
+			delay *= 2
+		}
+		return state.LastFailure.Add(min(delay, p.MaxDelay))
+	default:
+		return time.Time{}
+	}
+}
+
+// Store keeps the failed attempts of clients by key. Stores shared by every
+// instance of the server, like a database, make the limits apply to all of
+// them together.
+type Store interface {
+	// Get returns the state of a key, which is the zero State when it has
+	// no failures
+	Get(ctx context.Context, key string) (State, error)
+	// Fail records a failure of a key at now and returns its new state.
+	// Failures that happened before forgetBefore must be discarded first
+	Fail(ctx context.Context, key string, now, forgetBefore time.Time) (State, error)
+	// Attempt records a failure of a key at now, as Fail does, but only when
+	// allowed accepts the state the key has. The check and the failure must
+	// be atomic, so that concurrent attempts see each other. It returns the
+	// state of the key afterwards and whether the failure was recorded
+	Attempt(ctx context.Context, key string, now, forgetBefore time.Time, allowed func(State) bool) (State, bool, error)
+	// Forgive drops one failure of a key, as when an attempt recorded by
+	// Attempt succeeds
+	Forgive(ctx context.Context, key string) error
+	// Reset forgets the failures of a key
+	Reset(ctx context.Context, key string) error
+}
+
+// Limiter applies a policy to the failures kept in a store.
+type Limiter struct {
+	Store  Store
+	Policy Policy
+	// now returns the current time, and can be replaced in tests
+	now func() time.Time
+}
+
+// NewLimiter returns a limiter that applies the policy to the failures kept
+// in the store.
+func NewLimiter(store Store, policy Policy) *Limiter {
+	return &Limiter{Store: store, Policy: policy, now: time.Now}
+}
+
+// Wait returns how long a client must wait before trying again, which is 0
+// when it can try right away. locked reports whether it's locked out rather
+// than just slowed down.
+func (l *Limiter) Wait(ctx context.Context, key string) (wait time.Duration, locked bool, err error) {
+	state, err := l.Store.Get(ctx, key)
+	if err != nil {
+		return 0, false, fmt.Errorf("getting failures of %q: %w", key, err)
+	}
+	wait, locked = l.wait(state)
+	return wait, locked, nil
+}
+
+// Fail records a failed attempt of a client and returns how long it must
+// wait before trying again.
+func (l *Limiter) Fail(ctx context.Context, key string) (wait time.Duration, locked bool, err error) {
+	now := l.now()
+	state, err := l.Store.Fail(ctx, key, now, now.Add(-l.Policy.Window))
+	if err != nil {
+		return 0, false, fmt.Errorf("recording failure of %q: %w", key, err)
+	}
+	wait, locked = l.wait(state)
+	return wait, locked, nil
+}
+
+// Reservation is the outcome of Limiter.Reserve.
+type Reservation struct {
+	// Allowed reports whether the client could try. Otherwise, nothing was
+	// recorded
+	Allowed bool
+	// Wait is how long the client must wait before trying again: right now
+	// when it wasn't allowed to try, or after a failure when it was
+	Wait time.Duration
+	// Locked reports whether the wait is a lockout rather than a delay
+	Locked bool
+}
+
+// Reserve checks that a client can try right away and, when it can, records
+// the attempt as a failure before it's made, so that concurrent attempts
+// can't all get past the limits before any of them fails. Attempts that
+// succeed must be given back with Forgive or Reset.
+func (l *Limiter) Reserve(ctx context.Context, key string) (Reservation, error) {
+	now := l.now()
+	state, allowed, err := l.Store.Attempt(ctx, key, now, now.Add(-l.Policy.Window), func(state State) bool {
+		wait, _ := l.waitAt(state, now)
+		return wait == 0
+	})
+	if err != nil {
+		return Reservation{}, fmt.Errorf("reserving attempt of %q: %w", key, err)
+	}
+	wait, locked := l.waitAt(state, now)
+	return Reservation{Allowed: allowed, Wait: wait, Locked: locked}, nil
+}
+
+// Forgive gives back an attempt reserved with Reserve that succeeded, while
+// keeping the failures before it.
+func (l *Limiter) Forgive(ctx context.Context, key string) error {
+	if err := l.Store.Forgive(ctx, key); err != nil {
+		return fmt.Errorf("forgiving attempt of %q: %w", key, err)
+	}
+	return nil
+}
+
+// Reset forgets the failures of a client, as after it succeeds or when an
+// administrator unlocks it.
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	if err := l.Store.Reset(ctx, key); err != nil {
+		return fmt.Errorf("resetting failures of %q: %w", key, err)
+	}
+	return nil
+}
+
+func (l *Limiter) wait(state State) (time.Duration, bool) {
+	return l.waitAt(state, l.now())
+}
+
+func (l *Limiter) waitAt(state State, now time.Time) (time.Duration, bool) {
+	state = l.Policy.current(state, now)
+	wait := max(l.Policy.RetryAt(state).Sub(now), 0)
+	return wait, wait > 0 && state.Failures >= l.Policy.LockoutAttempts
+}
@@ -0,0 +1,195 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	FreeAttempts:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        4 * time.Second,
+	LockoutAttempts: 8,
+	LockoutDuration: time.Minute,
+	Window:          time.Hour,
+}
+
+func TestPolicyRetryAt(t *testing.T) {
+	last := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		failures int
+		expected time.Time
+	}{
+		{name: "no failures", failures: 0, expected: time.Time{}},
+		{name: "last free attempt", failures: 3, expected: time.Time{}},
+		{name: "first delayed attempt", failures: 4, expected: last.Add(time.Second)},
+		{name: "delay doubles", failures: 5, expected: last.Add(2 * time.Second)},
+		{name: "delay reaches the maximum", failures: 6, expected: last.Add(4 * time.Second)},
+		{name: "delay stays at the maximum", failures: 7, expected: last.Add(4 * time.Second)},
+		{name: "locked out", failures: 8, expected: last.Add(time.Minute)},
+		{name: "locked out again", failures: 9, expected: last.Add(time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testPolicy.RetryAt(State{Failures: tt.failures, LastFailure: last}); !got.Equal(tt.expected) {
+				t.Errorf("RetryAt() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(*Policy)
+		expectErr bool
+	}{
+		{name: "valid policy", modify: func(p *Policy) {}},
+		{name: "lockout before the free attempts run out", modify: func(p *Policy) { p.LockoutAttempts = 3 }, expectErr: true},
+		{name: "maximum delay shorter than the base one", modify: func(p *Policy) { p.MaxDelay = time.Millisecond }, expectErr: true},
+		{name: "window shorter than the lockout", modify: func(p *Policy) { p.Window = time.Second }, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testPolicy
+			tt.modify(&policy)
+			if err := policy.Validate(); (err != nil) != tt.expectErr {
+				t.Errorf("Validate() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(NewMemoryStore(), testPolicy)
+	limiter.now = func() time.Time { return now }
+
+	fail := func(expectedWait time.Duration, expectedLocked bool) {
+		t.Helper()
+		wait, locked, err := limiter.Fail(ctx, "walt")
+		if err != nil {
+			t.Fatalf("Fail() error = %v", err)
+		}
+		if wait != expectedWait || locked != expectedLocked {
+			t.Fatalf("Fail() = %v, %v, expected %v, %v", wait, locked, expectedWait, expectedLocked)
+		}
+	}
+	check := func(expectedWait time.Duration, expectedLocked bool) {
+		t.Helper()
+		wait, locked, err := limiter.Wait(ctx, "walt")
+		if err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+		if wait != expectedWait || locked != expectedLocked {
+			t.Fatalf("Wait() = %v, %v, expected %v, %v", wait, locked, expectedWait, expectedLocked)
+		}
+	}
+
+	for range testPolicy.FreeAttempts {
+		fail(0, false)
+	}
+	fail(time.Second, false)
+	now = now.Add(500 * time.Millisecond)
+	check(500*time.Millisecond, false)
+
+	for _, delay := range []time.Duration{2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		now = now.Add(time.Minute)
+		fail(delay, false)
+	}
+	now = now.Add(time.Minute)
+	fail(time.Minute, true)
+	now = now.Add(30 * time.Second)
+	check(30*time.Second, true)
+
+	if err := limiter.Reset(ctx, "walt"); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+	check(0, false)
+
+	// failures are forgotten once the window passes
+	for range testPolicy.FreeAttempts + 1 {
+		fail(0, false)
+		now = now.Add(testPolicy.Window + time.Second)
+	}
+	check(0, false)
+}
+
+func TestLimiterReserve(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(NewMemoryStore(), testPolicy)
+	limiter.now = func() time.Time { return now }
+
+	// a burst of attempts only gets the free ones, plus the one whose failure
+	// starts the delays
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reservation, err := limiter.Reserve(ctx, "walt")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if reservation.Allowed {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := int(allowed.Load()); got != testPolicy.FreeAttempts+1 {
+		t.Fatalf("%d attempts were allowed, expected %d", got, testPolicy.FreeAttempts+1)
+	}
+
+	reservation, err := limiter.Reserve(ctx, "walt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reservation.Allowed || reservation.Wait != time.Second {
+		t.Fatalf("Reserve() = %+v, expected to wait a second", reservation)
+	}
+
+	// giving back the last attempt lifts the delay
+	if err := limiter.Forgive(ctx, "walt"); err != nil {
+		t.Fatal(err)
+	}
+	reservation, err = limiter.Reserve(ctx, "walt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reservation.Allowed || reservation.Wait != time.Second || reservation.Locked {
+		t.Fatalf("Reserve() = %+v, expected an allowed attempt followed by a second of delay", reservation)
+	}
+}
+
+func TestMemoryStorePrunes(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+
+	if _, err := store.Fail(ctx, "old", now, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Hour)
+	state, err := store.Fail(ctx, "new", now, now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Failures != 1 {
+		t.Errorf("got %d failures, expected 1", state.Failures)
+	}
+	if _, ok := store.states["old"]; ok {
+		t.Error("failures older than the window weren't pruned")
+	}
+}
@@ -0,0 +1,81 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps failures in memory. It's only shared by the limiters of
+// a single server instance and it's emptied when the server restarts.
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]State
+	// pruned is when failures that are too old to matter were last dropped
+	pruned time.Time
+}
+
+// NewMemoryStore returns an empty store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string]State)}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.states[key], nil
+}
+
+func (s *MemoryStore) Fail(_ context.Context, key string, now, forgetBefore time.Time) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fail(key, now, forgetBefore), nil
+}
+
+func (s *MemoryStore) Attempt(_ context.Context, key string, now, forgetBefore time.Time, allowed func(State) bool) (State, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state := s.states[key]; !allowed(state) {
+		return state, false, nil
+	}
+	return s.fail(key, now, forgetBefore), true, nil
+}
+
+func (s *MemoryStore) Forgive(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state, ok := s.states[key]; ok && state.Failures > 0 {
+		state.Failures--
+		s.states[key] = state
+	}
+	return nil
+}
+
+// fail records a failure of a key. The mutex must be held.
+func (s *MemoryStore) fail(key string, now, forgetBefore time.Time) State {
+	// keys of clients that stopped failing are dropped from time to time so
+	// that the store doesn't grow forever
+	if s.pruned.Before(forgetBefore) {
+		for k, state := range s.states {
+			if state.LastFailure.Before(forgetBefore) {
+				delete(s.states, k)
+			}
+		}
+		s.pruned = now
+	}
+
+	state := s.states[key]
+	if state.LastFailure.Before(forgetBefore) {
+		state = State{}
+	}
+	state = State{Failures: state.Failures + 1, LastFailure: now}
+	s.states[key] = state
+	return state
+}
+
+func (s *MemoryStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, key)
+	return nil
+}
@@ -0,0 +1,78 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	date := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		msg           Message
+		expectedError bool
+		contains      []string
+	}{
+		{
+			name: "Assert message is formatted",
+			msg:  Message{To: "user@example.com", Subject: "Reset your password", Body: "Hello\nthere"},
+			contains: []string{
+				"From: Chirpy <no-reply@chirpy.example>\r\n",
+				"To: user@example.com\r\n",
+				"Subject: Reset your password\r\n",
+				"Date: Sat, 17 Oct 2026 12:00:00 +0000\r\n",
+				"\r\n\r\nHello\r\nthere",
+			},
+		},
+		{
+			name:     "Assert non-ASCII subject is encoded",
+			msg:      Message{To: "user@example.com", Subject: "Contraseña", Body: "Hola"},
+			contains: []string{"Subject: =?utf-8?q?Contrase=C3=B1a?=\r\n"},
+		},
+		{
+			name:          "Assert line breaks in headers are rejected",
+			msg:           Message{To: "user@example.com\r\nBcc: other@example.com", Subject: "Hi", Body: "Hi"},
+			expectedError: true,
+		},
+		{
+			name:          "Assert invalid recipient is rejected",
+			msg:           Message{To: "not an address", Subject: "Hi", Body: "Hi"},
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := Format("Chirpy <no-reply@chirpy.example>", test.msg, date)
+			if (err != nil) != test.expectedError {
+				t.Fatalf("got error %v", err)
+			}
+			for _, expected := range test.contains {
+				if !strings.Contains(string(data), expected) {
+					t.Errorf("%q not found in message:\n%s", expected, data)
+				}
+			}
+		})
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	mailer := FileMailer{Dir: dir, From: "no-reply@chirpy.example"}
+	if err := mailer.Send(context.Background(), Message{To: "user@example.com", Subject: "Hi", Body: "Hello"}); err != nil {
+		t.Fatalf("can't send email: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("can't read directory: %v", err)
+	}
+	if len(entries) != 1 || !strings.HasSuffix(entries[0].Name(), "-user_at_example.com.eml") {
+		t.Fatalf("got entries %v", entries)
+	}
+}
@@ -0,0 +1,205 @@
+// Package moderation checks user-generated text against lists of words that
+// shouldn't be posted as they are.
+package moderation
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Action is what happens to text that contains a word of the list.
+type Action string
+
+const (
+	// ActionMask replaces the word with asterisks.
+	ActionMask Action = "mask"
+	// ActionFlag keeps the word but marks the text for review.
+	ActionFlag Action = "flag"
+	// ActionReject refuses the text altogether.
+	ActionReject Action = "reject"
+)
+
+// mask is what masked words are replaced with, whatever their length.
+const mask = "****"
+
+// ParseAction returns the action with the given name.
+func ParseAction(name string) (Action, error) {
+	switch action := Action(strings.ToLower(name)); action {
+	case ActionMask, ActionFlag, ActionReject:
+		return action, nil
+	default:
+		return "", fmt.Errorf("unknown action %q", name)
+	}
+}
+
+// severity orders actions so that the strictest one wins when a word is
+// listed more than once.
+func (a Action) severity() int {
+	return slices.Index([]Action{ActionMask, ActionFlag, ActionReject}, a)
+}
+
+// Rule is a word of the list along with what to do when it's found.
+type Rule struct {
+	Word   string
+	Action Action
+}
+
+// Filter finds the words of a list in text. A Filter is immutable and safe
+// for concurrent use.
+type Filter struct {
+	rules map[string]Rule
+	// maxLength is the length, in folded characters, of the longest word
+	maxLength int
+}
+
+// NewFilter compiles a list of rules into a filter. Words must be a single
+// word each, without whitespace.
+func NewFilter(rules []Rule) (*Filter, error) {
+	filter := &Filter{rules: make(map[string]Rule, len(rules))}
+	for _, rule := range rules {
+		if rule.Action.severity() < 0 {
+			return nil, fmt.Errorf("word %q: unknown action %q", rule.Word, rule.Action)
+		}
+		if strings.ContainsFunc(rule.Word, unicode.IsSpace) {
+			return nil, fmt.Errorf("word %q: words can't contain whitespace", rule.Word)
+		}
+		folded := foldWord(rule.Word)
+		if folded == "" {
+			return nil, fmt.Errorf("word %q: nothing to match", rule.Word)
+		}
+
+		if listed, ok := filter.rules[folded]; ok && listed.Action.severity() >= rule.Action.severity() {
+			continue
+		}
+		filter.rules[folded] = rule
+		filter.maxLength = max(filter.maxLength, utf8.RuneCountInString(folded))
+	}
+	return filter, nil
+}
+
+// Match is a word of the list found in text.
+type Match struct {
+	Word   string
+	Action Action
+	// Start and End are offsets, in bytes, of the match in the text
+	Start int
+	End   int
+}
+
+// Result is what a filter found in text.
+type Result struct {
+	// Text is the checked text with the words to mask replaced. Everything
+	// else, whitespace and punctuation included, is left as it was.
+	Text    string
+	Matches []Match
+}
+
+// Rejected reports whether the text contains words that make it unacceptable.
+func (r Result) Rejected() bool {
+	return len(r.Words(ActionReject)) > 0
+}
+
+// Words returns the words of the list with the given action that were found,
+// without repetitions.
+func (r Result) Words(action Action) []string {
+	words := []string{}
+	for _, match := range r.Matches {
+		if match.Action == action && !slices.Contains(words, match.Word) {
+			words = append(words, match.Word)
+		}
+	}
+	return words
+}
+
+// segment is a run of letters, digits and combining marks, or a single leet
+// symbol, within a word of the text.
+type segment struct {
+	folded string
+	start  int
+	end    int
+}
+
+// Check looks for the words of the list in text.
+//
+// Text is split into words at whitespace. Within them, words of the list are
+// found regardless of case, accents, look-alike letters from other scripts or
+// digits and symbols standing for letters. Punctuation between letters is
+// ignored, so "k.e.r.f.u.f.f.l.e" is a match too, but a match must start and
+// end at the edges of a run of letters, so that words of the list aren't
+// found within longer, harmless ones.
+func (f *Filter) Check(text string) Result {
+	var matches []Match
+	var segments []segment
+	inSegment := false
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case unicode.IsSpace(r):
+			matches = append(matches, f.match(segments)...)
+			segments = segments[:0]
+			inSegment = false
+		case isLeetSymbol(r):
+			segments = append(segments, segment{folded: fold(r), start: i, end: i + size})
+			inSegment = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			if !inSegment {
+				segments = append(segments, segment{start: i})
+				inSegment = true
+			}
+			last := &segments[len(segments)-1]
+			last.folded += fold(r)
+			last.end = i + size
+		default:
+			inSegment = false
+		}
+		i += size
+	}
+	matches = append(matches, f.match(segments)...)
+
+	return Result{Text: maskMatches(text, matches), Matches: matches}
+}
+
+// match finds words of the list in the segments of a word of the text.
+func (f *Filter) match(segments []segment) []Match {
+	var matches []Match
+	for i := range segments {
+		var folded strings.Builder
+		for j := i; j < len(segments); j++ {
+			folded.WriteString(segments[j].folded)
+			if utf8.RuneCountInString(folded.String()) > f.maxLength {
+				break
+			}
+			if rule, ok := f.rules[folded.String()]; ok {
+				matches = append(matches, Match{
+					Word:   rule.Word,
+					Action: rule.Action,
+					Start:  segments[i].start,
+					End:    segments[j].end,
+				})
+			}
+		}
+	}
+	return matches
+}
+
+// maskMatches replaces the matches to mask in text. Overlapping matches are
+// masked as one.
+func maskMatches(text string, matches []Match) string {
+	var masked strings.Builder
+	end := 0
+	for _, match := range matches {
+		if match.Action != ActionMask || match.End <= end {
+			continue
+		}
+		if match.Start >= end {
+			masked.WriteString(text[end:match.Start])
+			masked.WriteString(mask)
+		}
+		end = match.End
+	}
+	masked.WriteString(text[end:])
+	return masked.String()
+}
@@ -0,0 +1,165 @@
+package moderation
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	filter, err := NewFilter([]Rule{
+		{Word: "kerfuffle", Action: ActionMask},
+		{Word: "sharbert", Action: ActionMask},
+		{Word: "fornax", Action: ActionReject},
+		{Word: "gizmo", Action: ActionFlag},
+	})
+	if err != nil {
+		t.Fatalf("NewFilter() returned error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+		rejected bool
+		flagged  []string
+	}{
+		{
+			name:     "nothing to do",
+			text:     "just chirping",
+			expected: "just chirping",
+			flagged:  []string{},
+		},
+		{
+			name:     "whole words",
+			text:     "what a kerfuffle that sharbert made",
+			expected: "what a **** that **** made",
+			flagged:  []string{},
+		},
+		{
+			name:     "case and punctuation around the word",
+			text:     "Kerfuffle! What a (SHARBERT), really.",
+			expected: "****! What a (****), really.",
+			flagged:  []string{},
+		},
+		{
+			name:     "spacing is preserved",
+			text:     "  kerfuffle\n\tand  more ",
+			expected: "  ****\n\tand  more ",
+			flagged:  []string{},
+		},
+		{
+			name:     "punctuation within the word",
+			text:     "a k.e.r.f-u_f*f.l.e indeed",
+			expected: "a **** indeed",
+			flagged:  []string{},
+		},
+		{
+			name:     "leetspeak",
+			text:     "k3rfuff1e and $h@rb3rt",
+			expected: "**** and ****",
+			flagged:  []string{},
+		},
+		{
+			name:     "confusables and accents",
+			text:     "kеrfufflе ＳＨＡＲＢＥＲＴ kérfüfflè",
+			expected: "**** **** ****",
+			flagged:  []string{},
+		},
+		{
+			name:     "words within longer words are left alone",
+			text:     "kerfuffles and unsharbert",
+			expected: "kerfuffles and unsharbert",
+			flagged:  []string{},
+		},
+		{
+			name:     "words next to other words are found",
+			text:     "total.kerfuffle @sharbert",
+			expected: "total.**** @****",
+			flagged:  []string{},
+		},
+		{
+			name:     "rejected words",
+			text:     "look at F0rnax!",
+			expected: "look at F0rnax!",
+			rejected: true,
+			flagged:  []string{},
+		},
+		{
+			name:     "flagged words are kept",
+			text:     "a gizmo and a GIZMO, and a kerfuffle",
+			expected: "a gizmo and a GIZMO, and a ****",
+			flagged:  []string{"gizmo"},
+		},
+		{
+			name:     "invalid UTF-8 is left alone",
+			text:     "kerfuffle \xff sharbert",
+			expected: "**** \xff ****",
+			flagged:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := filter.Check(tt.text)
+			if result.Text != tt.expected {
+				t.Errorf("Check(%q).Text = %q, expected %q", tt.text, result.Text, tt.expected)
+			}
+			if result.Rejected() != tt.rejected {
+				t.Errorf("Check(%q).Rejected() = %v, expected %v", tt.text, result.Rejected(), tt.rejected)
+			}
+			if flagged := result.Words(ActionFlag); !slices.Equal(flagged, tt.flagged) {
+				t.Errorf("Check(%q).Words(ActionFlag) = %q, expected %q", tt.text, flagged, tt.flagged)
+			}
+		})
+	}
+}
+
+func TestNewFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		rules     []Rule
+		expectErr bool
+	}{
+		{
+			name:  "valid rules",
+			rules: []Rule{{Word: "kerfuffle", Action: ActionMask}, {Word: "fornax", Action: ActionReject}},
+		},
+		{
+			name:      "unknown action",
+			rules:     []Rule{{Word: "kerfuffle", Action: "hide"}},
+			expectErr: true,
+		},
+		{
+			name:      "more than one word",
+			rules:     []Rule{{Word: "two words", Action: ActionMask}},
+			expectErr: true,
+		},
+		{
+			name:      "nothing to match",
+			rules:     []Rule{{Word: "́", Action: ActionMask}},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFilter(tt.rules)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("NewFilter() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestNewFilterStrictestActionWins(t *testing.T) {
+	filter, err := NewFilter([]Rule{
+		{Word: "fornax", Action: ActionReject},
+		{Word: "FORNAX", Action: ActionMask},
+	})
+	if err != nil {
+		t.Fatalf("NewFilter() returned error: %v", err)
+	}
+	if result := filter.Check("fornax"); !result.Rejected() {
+		t.Errorf("Check(%q).Rejected() = false, expected true", "fornax")
+	}
+}
@@ -0,0 +1,65 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// confusables maps letters from other scripts to the Latin letters they look
+// like, so that a Cyrillic "е" can't stand in for an "e". It only holds
+// lowercase letters because folding lowercases characters first.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'с': 'c', 'ԁ': 'd', 'е': 'e', 'ё': 'e', 'һ': 'h',
+	'н': 'h', 'і': 'i', 'ї': 'i', 'ј': 'j', 'к': 'k', 'м': 'm', 'о': 'o',
+	'р': 'p', 'ԛ': 'q', 'ѕ': 's', 'т': 't', 'у': 'y', 'х': 'x', 'ԝ': 'w',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v',
+	'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x',
+}
+
+// leetspeak maps digits and symbols to the letters they usually replace. The
+// letter l is folded into i too, as 1, | and ! are used for both.
+var leetspeak = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't',
+	'@': 'a', '$': 's', '!': 'i', '|': 'i', '+': 't', 'l': 'i',
+}
+
+// fold returns the lowercase Latin letters a character looks like. Accents
+// are dropped and compatibility characters, like fullwidth letters, are
+// decomposed, so the result may be empty or longer than one letter.
+func fold(r rune) string {
+	var folded strings.Builder
+	for _, c := range norm.NFKD.String(string(r)) {
+		if unicode.Is(unicode.Mn, c) {
+			continue
+		}
+		c = unicode.ToLower(c)
+		if latin, ok := confusables[c]; ok {
+			c = latin
+		}
+		if letter, ok := leetspeak[c]; ok {
+			c = letter
+		}
+		folded.WriteRune(c)
+	}
+	return folded.String()
+}
+
+func foldWord(word string) string {
+	var folded strings.Builder
+	for _, r := range word {
+		folded.WriteString(fold(r))
+	}
+	return folded.String()
+}
+
+// isLeetSymbol reports whether a character is a symbol that may stand for a
+// letter. Such symbols are also used as punctuation, as in "wow!", so they
+// are matched on their own instead of as part of the word next to them.
+func isLeetSymbol(r rune) bool {
+	_, ok := leetspeak[r]
+	return ok && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
@@ -0,0 +1,113 @@
+package moderation
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Loader fetches the list of words from wherever it's kept.
+type Loader interface {
+	Load(ctx context.Context) ([]Rule, error)
+}
+
+// LoaderFunc lets ordinary functions be used as loaders.
+type LoaderFunc func(ctx context.Context) ([]Rule, error)
+
+func (f LoaderFunc) Load(ctx context.Context) ([]Rule, error) {
+	return f(ctx)
+}
+
+// FileLoader loads the list of words from a text file in the format read by
+// ParseRules.
+type FileLoader struct {
+	Path string
+}
+
+func (l FileLoader) Load(ctx context.Context) ([]Rule, error) {
+	file, err := os.Open(l.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseRules(file)
+}
+
+// ParseRules reads a list of words with one word per line, optionally
+// followed by the action to take, like this:
+//
+//	# words that are masked when no action is given
+//	kerfuffle
+//	sharbert flag
+//	fornax reject
+//
+// Blank lines and lines starting with # are ignored.
+func ParseRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		rule := Rule{Word: fields[0], Action: ActionMask}
+		switch len(fields) {
+		case 1:
+		case 2:
+			action, err := ParseAction(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			rule.Action = action
+		default:
+			return nil, fmt.Errorf("line %d: expected a word and an optional action", line)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Moderator checks text against the latest list of words it loaded. It's safe
+// for concurrent use, also while reloading.
+type Moderator struct {
+	loader Loader
+	filter atomic.Pointer[Filter]
+}
+
+// NewModerator returns a moderator for the list of words of the loader, which
+// must load successfully.
+func NewModerator(ctx context.Context, loader Loader) (*Moderator, error) {
+	moderator := &Moderator{loader: loader}
+	if err := moderator.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return moderator, nil
+}
+
+// Reload loads the list of words again. When that fails, the moderator keeps
+// using the list it had.
+func (m *Moderator) Reload(ctx context.Context) error {
+	rules, err := m.loader.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("loading words: %w", err)
+	}
+	filter, err := NewFilter(rules)
+	if err != nil {
+		return fmt.Errorf("compiling words: %w", err)
+	}
+	m.filter.Store(filter)
+	return nil
+}
+
+// Check looks for the words of the list in text as described in Filter.Check.
+func (m *Moderator) Check(text string) Result {
+	return m.filter.Load().Check(text)
+}
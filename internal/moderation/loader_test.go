@@ -0,0 +1,83 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		expected  []Rule
+		expectErr bool
+	}{
+		{
+			name:  "words with and without actions",
+			input: "# a comment\nkerfuffle\n\nsharbert flag\n  fornax   REJECT  \n",
+			expected: []Rule{
+				{Word: "kerfuffle", Action: ActionMask},
+				{Word: "sharbert", Action: ActionFlag},
+				{Word: "fornax", Action: ActionReject},
+			},
+		},
+		{
+			name:      "unknown action",
+			input:     "kerfuffle hide\n",
+			expectErr: true,
+		},
+		{
+			name:      "too many fields",
+			input:     "kerfuffle mask now\n",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := ParseRules(strings.NewReader(tt.input))
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("ParseRules() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if !slices.Equal(rules, tt.expected) {
+				t.Errorf("ParseRules() = %v, expected %v", rules, tt.expected)
+			}
+		})
+	}
+}
+
+func TestModeratorReload(t *testing.T) {
+	rules := []Rule{{Word: "kerfuffle", Action: ActionMask}}
+	var loadErr error
+	loader := LoaderFunc(func(ctx context.Context) ([]Rule, error) {
+		return rules, loadErr
+	})
+
+	moderator, err := NewModerator(context.Background(), loader)
+	if err != nil {
+		t.Fatalf("NewModerator() returned error: %v", err)
+	}
+	if got := moderator.Check("kerfuffle sharbert").Text; got != "**** sharbert" {
+		t.Errorf("Check() before reloading = %q", got)
+	}
+
+	rules = []Rule{{Word: "sharbert", Action: ActionMask}}
+	if err := moderator.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload() returned error: %v", err)
+	}
+	if got := moderator.Check("kerfuffle sharbert").Text; got != "kerfuffle ****" {
+		t.Errorf("Check() after reloading = %q", got)
+	}
+
+	// a failed reload keeps the words loaded before
+	loadErr = errors.New("database is down")
+	if err := moderator.Reload(context.Background()); err == nil {
+		t.Fatal("Reload() didn't return the error of the loader")
+	}
+	if got := moderator.Check("kerfuffle sharbert").Text; got != "kerfuffle ****" {
+		t.Errorf("Check() after a failed reload = %q", got)
+	}
+}
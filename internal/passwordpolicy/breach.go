@@ -0,0 +1,72 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// prefixLength is the number of hex digits of the SHA-1 hash of a password
+// that select the range file it would be listed in.
+const prefixLength = 5
+
+// BreachList is a local copy of a list of passwords exposed in data breaches,
+// like Have I Been Pwned's Pwned Passwords, laid out as the ranges its
+// k-anonymity API serves. Dir holds one file per 5 hex digit prefix of the
+// SHA-1 hashes of the passwords, named after the prefix, as in "21BD1.txt",
+// with a "SUFFIX:COUNT" line for each hash starting with it. Such a directory
+// is what the official downloader writes when told not to merge the ranges.
+//
+// Only the file for the prefix of the checked password is read, so the list
+// can be as large as the disk allows and passwords never leave the server.
+type BreachList struct {
+	Dir string
+}
+
+// Count returns how many times a password appeared in breaches, or 0 if it
+// never did. Missing range files are treated as empty ranges.
+func (b BreachList) Count(password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+
+	file, err := os.Open(filepath.Join(b.Dir, prefix+".txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("opening range %v: %w", prefix, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" {
+			continue
+		}
+		listed, count, found := strings.Cut(entry, ":")
+		if !strings.EqualFold(listed, suffix) {
+			continue
+		}
+		if !found {
+			return 1, nil
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return 0, fmt.Errorf("range %v, line %d: invalid count %q", prefix, line, count)
+		}
+		return n, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("reading range %v: %w", prefix, err)
+	}
+	return 0, nil
+}
@@ -0,0 +1,65 @@
+package passwordpolicy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBreachListCount(t *testing.T) {
+	dir := t.TempDir()
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	ranges := map[string]string{
+		"5BAA6.txt": "003D68EB55068C33ACE09247EE4C639306B:3\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:10434004\r\n",
+		// SHA-1 of "Password" is 8BE3C943B1609FFFBFC51AAD666D0A04ADF83C9D
+		"8BE3C.txt": "003D68EB55068C33ACE09247EE4C639306B:3\n",
+		// SHA-1 of "kerfuffle" is F1CD2CBA62D54CDA26EA2E9AB8068E3ABF2FB7BE
+		"F1CD2.txt": "cba62d54cda26ea2e9ab8068e3abf2fb7be:many\n",
+	}
+	for name, content := range ranges {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		password  string
+		expected  int
+		expectErr bool
+	}{
+		{
+			name:     "listed password",
+			password: "password",
+			expected: 10434004,
+		},
+		{
+			name:     "password in an existing range but not listed",
+			password: "Password",
+			expected: 0,
+		},
+		{
+			name:     "password in a missing range",
+			password: "kd8Fj2mQpL",
+			expected: 0,
+		},
+		{
+			name:      "listed password with an invalid count",
+			password:  "kerfuffle",
+			expectErr: true,
+		},
+	}
+
+	list := BreachList{Dir: dir}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := list.Count(tt.password)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("Count() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if count != tt.expected {
+				t.Errorf("Count(%q) = %d, expected %d", tt.password, count, tt.expected)
+			}
+		})
+	}
+}
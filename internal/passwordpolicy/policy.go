@@ -0,0 +1,84 @@
+// Package passwordpolicy decides whether a password is good enough to be
+// chosen: long enough, hard enough to guess and not known to attackers.
+package passwordpolicy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Policy is what passwords must meet to be chosen. It only applies when a
+// password is set, so passwords chosen under an older policy keep working.
+type Policy struct {
+	// MinLength and MaxLength bound the length of passwords, in characters
+	MinLength int
+	MaxLength int
+	// MinScore is the lowest strength score, from 0 to 4, that's accepted
+	MinScore int
+	// Breaches, when set, rejects passwords that appeared in data breaches
+	Breaches *BreachList
+}
+
+// DefaultPolicy follows NIST SP 800-63B: at least 8 characters, room for long
+// passphrases and no passwords that are easy to guess.
+func DefaultPolicy() Policy {
+	return Policy{MinLength: 8, MaxLength: 128, MinScore: 2}
+}
+
+// Validate checks that the policy can be met.
+func (p Policy) Validate() error {
+	switch {
+	case p.MinLength < 1:
+		return errors.New("minimum length must be at least 1")
+	case p.MaxLength < p.MinLength:
+		return fmt.Errorf("maximum length %d is shorter than the minimum length %d", p.MaxLength, p.MinLength)
+	case p.MinScore < 0 || p.MinScore > 4:
+		return fmt.Errorf("minimum score %d isn't between 0 and 4", p.MinScore)
+	}
+	return nil
+}
+
+// Check returns the reasons why a password can't be chosen by the user with
+// the given email, phrased to follow the name of the field, as in "must be
+// at least 8 characters long". It returns none when the password meets the
+// policy. It only fails when the breach list can't be read.
+func (p Policy) Check(password, email string) ([]string, error) {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return []string{fmt.Sprintf("must be at least %d characters long", p.MinLength)}, nil
+	}
+	if length > p.MaxLength {
+		return []string{fmt.Sprintf("must be at most %d characters long", p.MaxLength)}, nil
+	}
+
+	var problems []string
+	local, _, _ := strings.Cut(email, "@")
+	if containsFold(password, email) || (utf8.RuneCountInString(local) >= 3 && containsFold(password, local)) {
+		problems = append(problems, "must not contain your email")
+	}
+
+	if strength := Estimate(password, local, email); strength.Score < p.MinScore {
+		problem := "is too easy to guess"
+		if strength.Warning != "" {
+			problem += ": " + strength.Warning
+		}
+		problems = append(problems, problem)
+	}
+
+	if p.Breaches != nil {
+		count, err := p.Breaches.Count(password)
+		if err != nil {
+			return nil, fmt.Errorf("checking breached passwords: %w", err)
+		}
+		if count > 0 {
+			problems = append(problems, "appeared in a data breach, so attackers already know it")
+		}
+	}
+	return problems, nil
+}
+
+func containsFold(s, substr string) bool {
+	return substr != "" && strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
@@ -0,0 +1,136 @@
+package passwordpolicy
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	dir := t.TempDir()
+	// SHA-1 of "kd8Fj2mQpL" is E0154C604D24CD800FA0DBA0A904EBA33E56E8B7
+	if err := os.WriteFile(filepath.Join(dir, "E0154.txt"), []byte("C604D24CD800FA0DBA0A904EBA33E56E8B7:2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		email    string
+		breaches *BreachList
+		expected []string
+	}{
+		{
+			name:     "strong password",
+			password: "kd8Fj2mQpL",
+			email:    "walt@example.com",
+			expected: nil,
+		},
+		{
+			name:     "empty password",
+			password: "",
+			email:    "walt@example.com",
+			expected: []string{"must be at least 8 characters long"},
+		},
+		{
+			name:     "too long",
+			password: string(make([]byte, 129)),
+			email:    "walt@example.com",
+			expected: []string{"must be at most 128 characters long"},
+		},
+		{
+			name:     "length counts characters, not bytes",
+			password: "ñandú-pájaro-7",
+			email:    "walt@example.com",
+			expected: nil,
+		},
+		{
+			name:     "common password",
+			password: "password",
+			email:    "walt@example.com",
+			expected: []string{"is too easy to guess: " + WarningCommon},
+		},
+		{
+			name:     "common password with a sequence",
+			password: "password123",
+			email:    "walt@example.com",
+			expected: []string{"is too easy to guess: " + WarningWord},
+		},
+		{
+			name:     "contains the local part of the email",
+			password: "xK9#mQ2vWALT",
+			email:    "walt@example.com",
+			expected: []string{"must not contain your email"},
+		},
+		{
+			name:     "contains the email and little else",
+			password: "walt@example.com1",
+			email:    "walt@example.com",
+			expected: []string{"must not contain your email", "is too easy to guess: " + WarningUserInput},
+		},
+		{
+			name:     "short local parts are allowed",
+			password: "xK9#mQ2vjo",
+			email:    "jo@example.com",
+			expected: nil,
+		},
+		{
+			name:     "breached password",
+			password: "kd8Fj2mQpL",
+			email:    "walt@example.com",
+			breaches: &BreachList{Dir: dir},
+			expected: []string{"appeared in a data breach, so attackers already know it"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := DefaultPolicy()
+			policy.Breaches = tt.breaches
+			problems, err := policy.Check(tt.password, tt.email)
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if !slices.Equal(problems, tt.expected) {
+				t.Errorf("Check(%q) = %q, expected %q", tt.password, problems, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    Policy
+		expectErr bool
+	}{
+		{
+			name:   "default policy",
+			policy: DefaultPolicy(),
+		},
+		{
+			name:      "no minimum length",
+			policy:    Policy{MinLength: 0, MaxLength: 64, MinScore: 2},
+			expectErr: true,
+		},
+		{
+			name:      "maximum shorter than minimum",
+			policy:    Policy{MinLength: 12, MaxLength: 8, MinScore: 2},
+			expectErr: true,
+		},
+		{
+			name:      "score out of range",
+			policy:    Policy{MinLength: 8, MaxLength: 64, MinScore: 5},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); (err != nil) != tt.expectErr {
+				t.Errorf("Validate() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,404 @@
+package passwordpolicy
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Warnings explain what makes a password easy to guess.
+const (
+	WarningCommon    = "it's a commonly used password"
+	WarningWord      = "it's based on a common word or password"
+	WarningUserInput = "it's based on your email"
+	WarningSequence  = "it contains a sequence like abc or 654"
+	WarningRepeat    = "it contains repeated characters or patterns"
+	WarningKeyboard  = "it contains a run of keys next to each other"
+	WarningYear      = "it contains a year"
+)
+
+//go:embed common.txt
+var commonList string
+
+// common ranks common passwords and words by how often they are chosen,
+// starting at 1 for the most common one.
+var common = rankWords(strings.Fields(commonList))
+
+// keyboardRows are the rows of a QWERTY keyboard, which keyboard patterns
+// follow in either direction.
+var keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+
+// unleet maps digits and symbols to the letters they usually replace.
+var unleet = map[rune]rune{
+	'0': 'o', '1': 'i', '2': 'z', '3': 'e', '4': 'a', '5': 's', '7': 't',
+	'8': 'b', '9': 'g', '@': 'a', '$': 's', '!': 'i', '|': 'i', '+': 't',
+}
+
+// bruteForceGuesses is what each character that isn't part of a pattern adds
+// to the guesses. It's lower than the size of any alphabet, as in zxcvbn, as
+// attackers don't guess characters uniformly.
+const bruteForceGuesses = 10
+
+// Strength is an estimate of how hard a password is to guess.
+type Strength struct {
+	// Guesses is the base 10 logarithm of the guesses needed
+	Guesses float64
+	// Score goes from 0, guessable within a few attempts, to 4, safe even
+	// against offline attacks, as in zxcvbn.
+	Score int
+	// Warning says what makes the password guessable, if anything does.
+	Warning string
+}
+
+// match is a pattern found at runes [start, end) of a password.
+type match struct {
+	start   int
+	end     int
+	guesses float64 // base 10 logarithm
+	warning string
+}
+
+// Estimate tells how hard a password is to guess, in the way zxcvbn does: it
+// finds the dictionary words, sequences, repetitions, keyboard patterns and
+// years in it and looks for the cheapest way to guess it as a series of
+// those patterns and random characters in between. Words in userInputs, like
+// the email of the user, are treated as the most common words of all.
+func Estimate(password string, userInputs ...string) Strength {
+	dictionary := common
+	if len(userInputs) > 0 {
+		dictionary = make(map[string]int, len(common)+len(userInputs))
+		for word, rank := range common {
+			dictionary[word] = rank
+		}
+		for _, input := range userInputs {
+			if input = strings.ToLower(input); len([]rune(input)) >= 3 {
+				dictionary[input] = 0
+			}
+		}
+	}
+
+	runes := []rune(password)
+	guesses, warning := cheapest(runes, findMatches(runes, dictionary))
+	return Strength{Guesses: guesses, Score: score(guesses), Warning: warning}
+}
+
+// score turns guesses into a score using the thresholds of zxcvbn.
+func score(guesses float64) int {
+	switch {
+	case guesses < 3:
+		return 0
+	case guesses < 6:
+		return 1
+	case guesses < 8:
+		return 2
+	case guesses < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// cheapest finds the series of matches and random characters that covers
+// the password with the fewest guesses. It returns those guesses and the
+// warning of the longest match in the series.
+func cheapest(runes []rune, matches []match) (float64, string) {
+	type step struct {
+		guesses float64
+		// from is the match ending here in the best series, or -1 when the
+		// rune before here is guessed at random
+		from int
+	}
+
+	best := make([]step, len(runes)+1)
+	for end := 1; end <= len(runes); end++ {
+		best[end] = step{guesses: best[end-1].guesses + math.Log10(bruteForceGuesses), from: -1}
+		for i, m := range matches {
+			if m.end == end && best[m.start].guesses+m.guesses < best[end].guesses {
+				best[end] = step{guesses: best[m.start].guesses + m.guesses, from: i}
+			}
+		}
+	}
+
+	warning, longest := "", 0
+	for end := len(runes); end > 0; {
+		from := best[end].from
+		if from < 0 {
+			end--
+			continue
+		}
+		if m := matches[from]; m.end-m.start > longest {
+			warning, longest = m.warning, m.end-m.start
+		}
+		end = matches[from].start
+	}
+	return best[len(runes)].guesses, warning
+}
+
+// findMatches finds every pattern in a password.
+func findMatches(runes []rune, dictionary map[string]int) []match {
+	var matches []match
+	matches = append(matches, dictionaryMatches(runes, dictionary)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, repeatMatches(runes, dictionary)...)
+	matches = append(matches, keyboardMatches(runes)...)
+	matches = append(matches, yearMatches(runes)...)
+	return matches
+}
+
+// dictionaryMatches finds words of the dictionary, regardless of case,
+// written backwards or with digits and symbols standing for letters.
+func dictionaryMatches(runes []rune, dictionary map[string]int) []match {
+	lower := []rune(strings.ToLower(string(runes)))
+	if len(lower) != len(runes) {
+		// lowercasing changed the length, so offsets wouldn't line up
+		lower = runes
+	}
+	unleeted := make([]rune, len(lower))
+	for i, r := range lower {
+		if letter, ok := unleet[r]; ok {
+			r = letter
+		}
+		unleeted[i] = r
+	}
+
+	var matches []match
+	for start := range runes {
+		for end := start + 1; end <= len(runes); end++ {
+			word := string(lower[start:end])
+			variations := caseVariations(runes[start:end])
+			for _, candidate := range []struct {
+				word       string
+				variations float64
+			}{
+				{word, variations},
+				{string(unleeted[start:end]), variations + leetVariations(lower[start:end])},
+				{reverse(word), variations + math.Log10(2)},
+			} {
+				rank, ok := dictionary[candidate.word]
+				if !ok || (candidate.word != word && end-start < 3) {
+					continue
+				}
+				warning := WarningWord
+				switch {
+				case rank == 0:
+					warning = WarningUserInput
+				case start == 0 && end == len(runes):
+					warning = WarningCommon
+				}
+				matches = append(matches, match{
+					start:   start,
+					end:     end,
+					guesses: math.Log10(float64(rank+1)) + candidate.variations,
+					warning: warning,
+				})
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// caseVariations returns the base 10 logarithm of the ways a word could have
+// been capitalized, given how it was. Lowercase words, capitalized ones and
+// all caps are the usual choices and cost little.
+func caseVariations(word []rune) float64 {
+	upper, lower := 0, 0
+	for _, r := range word {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	switch {
+	case upper == 0:
+		return 0
+	case lower == 0 || (upper == 1 && unicode.IsUpper(word[0])):
+		return math.Log10(2)
+	default:
+		return logBinomial(upper+lower, min(upper, lower))
+	}
+}
+
+// leetVariations returns the base 10 logarithm of the ways a word could have
+// had letters replaced by the digits and symbols it has.
+func leetVariations(word []rune) float64 {
+	substituted := 0
+	for _, r := range word {
+		if _, ok := unleet[r]; ok {
+			substituted++
+		}
+	}
+	if substituted == 0 {
+		return 0
+	}
+	return logBinomial(len(word), substituted)
+}
+
+// logBinomial returns the base 10 logarithm of n choose k.
+func logBinomial(n, k int) float64 {
+	result := 0.0
+	for i := 1; i <= k; i++ {
+		result += math.Log10(float64(n-k+i)) - math.Log10(float64(i))
+	}
+	return result
+}
+
+// sequenceMatches finds runs of three or more letters or digits that go up
+// or down one at a time, like abc or 987.
+func sequenceMatches(runes []rune) []match {
+	var matches []match
+	for start := 0; start < len(runes)-2; {
+		delta := runes[start+1] - runes[start]
+		end := start + 1
+		for end < len(runes) && runes[end]-runes[end-1] == delta && sameClass(runes[end], runes[start]) {
+			end++
+		}
+		if (delta == 1 || delta == -1) && end-start >= 3 {
+			base := 26.0
+			switch {
+			case strings.ContainsRune("aAzZ019", runes[start]):
+				base = 4
+			case unicode.IsDigit(runes[start]):
+				base = 10
+			}
+			guesses := math.Log10(base * float64(end-start))
+			if delta < 0 {
+				guesses += math.Log10(2)
+			}
+			matches = append(matches, match{start: start, end: end, guesses: guesses, warning: WarningSequence})
+			start = end
+			continue
+		}
+		start++
+	}
+	return matches
+}
+
+// sameClass reports whether two runes are both lowercase letters, uppercase
+// letters or digits.
+func sameClass(a, b rune) bool {
+	return (unicode.IsLower(a) && unicode.IsLower(b)) ||
+		(unicode.IsUpper(a) && unicode.IsUpper(b)) ||
+		(unicode.IsDigit(a) && unicode.IsDigit(b))
+}
+
+// repeatMatches finds characters or chunks repeated back to back, like aaa
+// or abcabc. Guessing them costs guessing the chunk once and then how many
+// times it's repeated. Only the longest repetition starting at each rune is
+// kept, made of the shortest chunk.
+func repeatMatches(runes []rune, dictionary map[string]int) []match {
+	var matches []match
+	for start := range runes {
+		size, end := 0, 0
+		for s := 1; start+2*s <= len(runes); s++ {
+			e := start + s
+			for e+s <= len(runes) && string(runes[e:e+s]) == string(runes[start:start+s]) {
+				e += s
+			}
+			if e-start >= 2*s && e > end {
+				size, end = s, e
+			}
+		}
+		if size == 0 || end-start < 3 {
+			continue
+		}
+		chunk := runes[start : start+size]
+		chunkGuesses, _ := cheapest(chunk, findMatches(chunk, dictionary))
+		matches = append(matches, match{
+			start:   start,
+			end:     end,
+			guesses: chunkGuesses + math.Log10(float64((end-start)/size)),
+			warning: WarningRepeat,
+		})
+	}
+	return matches
+}
+
+// keyboardMatches finds runs of three or more keys next to each other on the
+// same row of the keyboard, like qwerty or lkjh.
+func keyboardMatches(runes []rune) []match {
+	lower := []rune(strings.ToLower(string(runes)))
+	if len(lower) != len(runes) {
+		return nil
+	}
+
+	var matches []match
+	for start := 0; start < len(lower)-2; {
+		end := start + 1
+		direction := 0
+		for end < len(lower) {
+			step := keyboardStep(lower[end-1], lower[end])
+			if step == 0 || (direction != 0 && step != direction) {
+				break
+			}
+			direction = step
+			end++
+		}
+		if end-start >= 3 {
+			matches = append(matches, match{
+				start:   start,
+				end:     end,
+				guesses: math.Log10(float64(len(keyboardRows)) * 11 * float64(end-start)),
+				warning: WarningKeyboard,
+			})
+			start = end
+			continue
+		}
+		start++
+	}
+	return matches
+}
+
+// keyboardStep returns 1 when b is the key to the right of a on the same
+// row, -1 when it's the one to the left and 0 otherwise.
+func keyboardStep(a, b rune) int {
+	for _, row := range keyboardRows {
+		i := strings.IndexRune(row, a)
+		if i < 0 {
+			continue
+		}
+		switch strings.IndexRune(row, b) - i {
+		case 1:
+			return 1
+		case -1:
+			return -1
+		}
+	}
+	return 0
+}
+
+// yearMatches finds years from 1900 to 2099.
+func yearMatches(runes []rune) []match {
+	var matches []match
+	for start := 0; start+4 <= len(runes); start++ {
+		year := string(runes[start : start+4])
+		if (strings.HasPrefix(year, "19") || strings.HasPrefix(year, "20")) &&
+			unicode.IsDigit(runes[start+2]) && unicode.IsDigit(runes[start+3]) {
+			matches = append(matches, match{start: start, end: start + 4, guesses: math.Log10(200), warning: WarningYear})
+		}
+	}
+	return matches
+}
+
+// rankWords ranks words by their position in a list, starting at 1.
+func rankWords(words []string) map[string]int {
+	ranks := make(map[string]int, len(words))
+	for i, word := range words {
+		word = strings.ToLower(word)
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = i + 1
+		}
+	}
+	return ranks
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
@@ -0,0 +1,110 @@
+package passwordpolicy
+
+import "testing"
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		name            string
+		password        string
+		userInputs      []string
+		maxScore        int
+		minScore        int
+		expectedWarning string
+	}{
+		{
+			name:            "common password",
+			password:        "password",
+			maxScore:        0,
+			expectedWarning: WarningCommon,
+		},
+		{
+			name:            "capitalized common password with leetspeak",
+			password:        "P@ssw0rd",
+			maxScore:        0,
+			expectedWarning: WarningCommon,
+		},
+		{
+			name:            "common password backwards",
+			password:        "drowssap",
+			maxScore:        0,
+			expectedWarning: WarningCommon,
+		},
+		{
+			name:            "common word and a year",
+			password:        "dragon1990",
+			maxScore:        1,
+			expectedWarning: WarningWord,
+		},
+		{
+			name:            "sequence",
+			password:        "abcdefghijk",
+			maxScore:        1,
+			expectedWarning: WarningSequence,
+		},
+		{
+			name:            "descending digits",
+			password:        "98765432",
+			maxScore:        1,
+			expectedWarning: WarningSequence,
+		},
+		{
+			name:            "repeated character",
+			password:        "aaaaaaaaaaaa",
+			maxScore:        0,
+			expectedWarning: WarningRepeat,
+		},
+		{
+			name:            "repeated chunk",
+			password:        "xk9mxk9mxk9m",
+			maxScore:        2,
+			expectedWarning: WarningRepeat,
+		},
+		{
+			name:            "keyboard pattern",
+			password:        "lkjhgfds",
+			maxScore:        1,
+			expectedWarning: WarningKeyboard,
+		},
+		{
+			name:            "user input",
+			password:        "kerfuffle",
+			userInputs:      []string{"kerfuffle"},
+			maxScore:        0,
+			expectedWarning: WarningUserInput,
+		},
+		{
+			name:     "random characters",
+			password: "kd8Fj2mQpL",
+			minScore: 4,
+			maxScore: 4,
+		},
+		{
+			name:     "passphrase",
+			password: "correct horse battery staple",
+			minScore: 4,
+			maxScore: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strength := Estimate(tt.password, tt.userInputs...)
+			if strength.Score < tt.minScore || strength.Score > tt.maxScore {
+				t.Errorf("Estimate(%q).Score = %d, expected between %d and %d", tt.password, strength.Score, tt.minScore, tt.maxScore)
+			}
+			if strength.Warning != tt.expectedWarning {
+				t.Errorf("Estimate(%q).Warning = %q, expected %q", tt.password, strength.Warning, tt.expectedWarning)
+			}
+		})
+	}
+}
+
+func TestEstimateLongPassword(t *testing.T) {
+	password := ""
+	for range 32 {
+		password += "abab"
+	}
+	if strength := Estimate(password); strength.Score > 1 || strength.Warning != WarningRepeat {
+		t.Errorf("Estimate(%q) = %+v, expected a low score for repeating", password, strength)
+	}
+}
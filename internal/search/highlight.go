@@ -0,0 +1,23 @@
+package search
+
+import (
+	"html"
+	"strings"
+)
+
+// StartSel and StopSel are the selectors ts_headline wraps the matches of a
+// snippet in. They're private-use characters instead of <mark> tags, so that
+// the text of the chirp can be escaped before the tags are added.
+const (
+	StartSel = "\uE000"
+	StopSel  = "\uE001"
+)
+
+var highlighter = strings.NewReplacer(StartSel, "<mark>", StopSel, "</mark>")
+
+// Highlight turns a snippet made by ts_headline with StartSel and StopSel into
+// HTML whose only markup is the <mark> elements around the matches. Anything
+// else in the text of the chirp, like tags, is escaped.
+func Highlight(snippet string) string {
+	return highlighter.Replace(html.EscapeString(snippet))
+}
@@ -0,0 +1,35 @@
+package search
+
+import "testing"
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		snippet  string
+		expected string
+	}{
+		{
+			name:     "matches",
+			snippet:  "the " + StartSel + "gopher" + StopSel + " chirps",
+			expected: "the <mark>gopher</mark> chirps",
+		},
+		{
+			name:     "html in the chirp",
+			snippet:  `<img src=x onerror="alert(1)"> ` + StartSel + "gopher" + StopSel,
+			expected: "&lt;img src=x onerror=&#34;alert(1)&#34;&gt; <mark>gopher</mark>",
+		},
+		{
+			name:     "mark tags in the chirp",
+			snippet:  "<mark>fake</mark> & " + StartSel + "real" + StopSel,
+			expected: "&lt;mark&gt;fake&lt;/mark&gt; &amp; <mark>real</mark>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Highlight(tt.snippet); got != tt.expected {
+				t.Errorf("Highlight() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
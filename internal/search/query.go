@@ -0,0 +1,148 @@
+package search
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrEmptyQuery    = errors.New("search query is empty")
+	ErrOnlyNegations = errors.New("search query must look for at least one term on each side of OR")
+)
+
+type term struct {
+	words   []string
+	prefix  bool
+	negated bool
+}
+
+// ToTSQuery translates a user search query into the syntax expected by
+// PostgreSQL's to_tsquery. It understands the following:
+//
+//   - words separated by spaces must all match: `gopher chirp`
+//   - quoted words must match as a phrase: `"little gopher"`
+//   - words ending in * match as a prefix: `goph*`
+//   - words starting with - must not match: `-java`
+//   - OR between terms matches any of them: `gopher OR rust`
+//
+// Each side of OR must look for at least one term, as one made only of
+// negations, like `-java`, would match nearly every chirp.
+//
+// Every other character is treated as a word separator, so the result never
+// carries syntax of its own that could make to_tsquery fail.
+func ToTSQuery(query string) (string, error) {
+	var groups [][]term
+	var group []term
+	onlyNegations := false
+	closeGroup := func() {
+		if len(group) == 0 {
+			return
+		}
+		if !slices.ContainsFunc(group, func(t term) bool { return !t.negated }) {
+			onlyNegations = true
+		}
+		groups = append(groups, group)
+		group = nil
+	}
+
+	for _, token := range tokenize(query) {
+		if token == "OR" {
+			closeGroup()
+			continue
+		}
+
+		var t term
+		if !strings.HasPrefix(token, `"`) {
+			if strings.HasPrefix(token, "-") {
+				t.negated = true
+				token = token[1:]
+			}
+			if strings.HasSuffix(token, "*") {
+				t.prefix = true
+				token = strings.TrimRight(token, "*")
+			}
+		}
+		t.words = splitWords(token)
+		if len(t.words) == 0 {
+			continue
+		}
+		group = append(group, t)
+	}
+	closeGroup()
+
+	if len(groups) == 0 {
+		return "", ErrEmptyQuery
+	}
+	if onlyNegations {
+		return "", ErrOnlyNegations
+	}
+
+	alternatives := make([]string, len(groups))
+	for i, group := range groups {
+		terms := make([]string, len(group))
+		for j, t := range group {
+			terms[j] = t.String()
+		}
+		alternatives[i] = strings.Join(terms, " & ")
+	}
+	return strings.Join(alternatives, " | "), nil
+}
+
+func (t term) String() string {
+	s := strings.Join(t.words, " <-> ")
+	if t.prefix {
+		s += ":*"
+	}
+	if len(t.words) > 1 {
+		s = "(" + s + ")"
+	}
+	if t.negated {
+		s = "!" + s
+	}
+	return s
+}
+
+// tokenize splits the query at whitespace, keeping quoted phrases (quotes
+// included) as a single token. An unterminated quote runs to the end.
+func tokenize(query string) []string {
+	var tokens []string
+	var current strings.Builder
+	inQuotes := false
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range query {
+		switch {
+		case r == '"':
+			if inQuotes {
+				current.WriteRune(r)
+				flush()
+			} else {
+				flush()
+				current.WriteRune(r)
+			}
+			inQuotes = !inQuotes
+		case unicode.IsSpace(r) && !inQuotes:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// splitWords keeps the runs of letters and digits found in s, lowercased.
+func splitWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,108 @@
+package search
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestToTSQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+		err      error
+	}{
+		{
+			name:     "single word",
+			query:    "gopher",
+			expected: "gopher",
+		},
+		{
+			name:     "every word must match",
+			query:    "  Gopher   chirp ",
+			expected: "gopher & chirp",
+		},
+		{
+			name:     "phrase",
+			query:    `"little gopher" chirp`,
+			expected: "(little <-> gopher) & chirp",
+		},
+		{
+			name:     "unterminated phrase",
+			query:    `chirp "little gopher`,
+			expected: "chirp & (little <-> gopher)",
+		},
+		{
+			name:     "prefix",
+			query:    "goph*",
+			expected: "goph:*",
+		},
+		{
+			name:     "negation",
+			query:    "gopher -java",
+			expected: "gopher & !java",
+		},
+		{
+			name:     "alternatives",
+			query:    "gopher chirp OR rust",
+			expected: "gopher & chirp | rust",
+		},
+		{
+			name:     "dangling OR",
+			query:    "OR gopher OR",
+			expected: "gopher",
+		},
+		{
+			name:     "punctuation splits words",
+			query:    "don't&panic",
+			expected: "(don <-> t <-> panic)",
+		},
+		{
+			name:     "tsquery syntax is not passed through",
+			query:    "a:* | !b <-> (c)",
+			expected: "a:* & b & c",
+		},
+		{
+			name:     "unicode words",
+			query:    "Ñandú café",
+			expected: "ñandú & café",
+		},
+		{
+			name:  "empty query",
+			query: "   ",
+			err:   ErrEmptyQuery,
+		},
+		{
+			name:  "only punctuation",
+			query: `"" - * !!`,
+			err:   ErrEmptyQuery,
+		},
+		{
+			name:  "only negations",
+			query: "-java -rust",
+			err:   ErrOnlyNegations,
+		},
+		{
+			name:  "alternative with only negations",
+			query: "gopher OR -java",
+			err:   ErrOnlyNegations,
+		},
+		{
+			name:     "negation in each alternative",
+			query:    "gopher -java OR rust -java",
+			expected: "gopher & !java | rust & !java",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ToTSQuery(test.query)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if got != test.expected {
+				t.Errorf("got %q when expecting %q", got, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/neira-daniel/go-chirpy/internal/auth"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+)
+
+// likedChirp is a chirp along with the time a user liked it, which is what
+// the likes of a user are sorted by.
+type likedChirp struct {
+	chirp   database.Chirp
+	likedAt time.Time
+}
+
+// likedChirpFromRow splits a row of liked chirps into the chirp and the time
+// it was liked.
+func likedChirpFromRow(row database.ListLikedChirpsDescendingRow) likedChirp {
+	return likedChirp{
+		chirp: database.Chirp{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			Body:      row.Body,
+			UserID:    row.UserID,
+			InReplyTo: row.InReplyTo,
+			ThreadID:  row.ThreadID,
+			RechirpOf: row.RechirpOf,
+			IsQuote:   row.IsQuote,
+			EditedAt:  row.EditedAt,
+		},
+		likedAt: row.LikedAt,
+	}
+}
+
+func likedChirpCursor(liked likedChirp) pageCursor {
+	return pageCursor{CreatedAt: liked.likedAt, ID: liked.chirp.ID}
+}
+
+func (cfg *apiConfig) handlerLikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+	chirp, ok := cfg.chirpFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// liking a chirp twice is not an error: the like just stays put
+	if err := cfg.db.LikeChirp(r.Context(), database.LikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirp.ID,
+	}); err != nil {
+		log.Print(fmt.Errorf("%v storing like in the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't like chirp")
+		return
+	}
+
+	log.Printf("%v user %q likes chirp %q", successTag, userID, chirp.ID)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+	chirp, ok := cfg.chirpFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	rowsAffected, err := cfg.db.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirp.ID,
+	})
+	if err != nil {
+		log.Print(fmt.Errorf("%v deleting like from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't unlike chirp")
+		return
+	}
+
+	if rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "chirp isn't liked")
+		return
+	}
+
+	log.Printf("%v user %q unliked chirp %q", successTag, userID, chirp.ID)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGETUserLikes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.userFromPath(w, r)
+	if !ok {
+		return
+	}
+	page, err := parsePageRequest(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("request error: %v", err))
+		return
+	}
+
+	// likes are listed newest first, so going back means reading upwards
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	var rows []database.ListLikedChirpsDescendingRow
+	if page.backward() {
+		var ascending []database.ListLikedChirpsAscendingRow
+		ascending, err = cfg.db.ListLikedChirpsAscending(r.Context(), database.ListLikedChirpsAscendingParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageSize:        page.fetchSize(),
+		})
+		// both queries return the same columns, so their rows convert into each other
+		for _, row := range ascending {
+			rows = append(rows, database.ListLikedChirpsDescendingRow(row))
+		}
+	} else {
+		rows, err = cfg.db.ListLikedChirpsDescending(r.Context(), database.ListLikedChirpsDescendingParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageSize:        page.fetchSize(),
+		})
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting liked chirps from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve liked chirps")
+		return
+	}
+	likes := make([]likedChirp, 0, len(rows))
+	for _, row := range rows {
+		likes = append(likes, likedChirpFromRow(row))
+	}
+
+	likes, next, prev := paginate(likes, page, likedChirpCursor)
+	chirps := make([]database.Chirp, len(likes))
+	for i, liked := range likes {
+		chirps[i] = liked.chirp
+	}
+	chirpsWithTags, err := cfg.presentChirps(r.Context(), cfg.viewerID(r), chirps)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting chirp details from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve liked chirps")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, ChirpsPage{
+		Chirps:     chirpsWithTags,
+		Pagination: setPaginationLinks(w, r, page, next, prev),
+	})
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/neira-daniel/go-chirpy/internal/database"
+	"github.com/neira-daniel/go-chirpy/internal/lockout"
+)
+
+var (
+	// accountLockoutPolicy protects each account from password guessing,
+	// wherever the guesses come from
+	accountLockoutPolicy = lockout.Policy{
+		FreeAttempts:    5,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAttempts: 10,
+		LockoutDuration: 15 * time.Minute,
+		Window:          24 * time.Hour,
+	}
+	// addressLockoutPolicy stops a single client from guessing the passwords
+	// of many accounts. It's more lenient than accountLockoutPolicy, as many
+	// users may share an address behind a NAT
+	addressLockoutPolicy = lockout.Policy{
+		FreeAttempts:    20,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAttempts: 100,
+		LockoutDuration: time.Hour,
+		Window:          24 * time.Hour,
+	}
+)
+
+// dbLockoutStore keeps failed logins in the database, so that every instance
+// of the server shares them.
+type dbLockoutStore struct {
+	db   *database.Queries
+	conn *sql.DB
+
+	mu sync.Mutex
+	// pruned is when failures that are too old to matter were last deleted
+	pruned time.Time
+}
+
+func (s *dbLockoutStore) Get(ctx context.Context, key string) (lockout.State, error) {
+	attempt, err := s.db.GetLoginAttempt(ctx, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return lockout.State{}, nil
+	}
+	if err != nil {
+		return lockout.State{}, err
+	}
+	return lockout.State{Failures: int(attempt.Failures), LastFailure: attempt.LastFailureAt}, nil
+}
+
+func (s *dbLockoutStore) Fail(ctx context.Context, key string, now, forgetBefore time.Time) (lockout.State, error) {
+	s.prune(ctx, now, forgetBefore)
+	attempt, err := s.db.RecordLoginFailure(ctx, database.RecordLoginFailureParams{
+		Key:          key,
+		Now:          now.UTC(),
+		ForgetBefore: forgetBefore.UTC(),
+	})
+	if err != nil {
+		return lockout.State{}, err
+	}
+	return lockout.State{Failures: int(attempt.Failures), LastFailure: attempt.LastFailureAt}, nil
+}
+
+// Attempt locks the row of the key while the attempt is checked, so that
+// concurrent attempts wait for each other. Keys without a row have no
+// failures, which every policy allows, so they don't need a lock.
+func (s *dbLockoutStore) Attempt(ctx context.Context, key string, now, forgetBefore time.Time, allowed func(lockout.State) bool) (lockout.State, bool, error) {
+	s.prune(ctx, now, forgetBefore)
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return lockout.State{}, false, err
+	}
+	// rolling back a committed transaction is a no-op
+	defer tx.Rollback()
+	qtx := s.db.WithTx(tx)
+
+	var state lockout.State
+	attempt, err := qtx.LockLoginAttempt(ctx, key)
+	switch {
+	case err == nil:
+		state = lockout.State{Failures: int(attempt.Failures), LastFailure: attempt.LastFailureAt}
+	case !errors.Is(err, sql.ErrNoRows):
+		return lockout.State{}, false, err
+	}
+	if !allowed(state) {
+		return state, false, nil
+	}
+
+	attempt, err = qtx.RecordLoginFailure(ctx, database.RecordLoginFailureParams{
+		Key:          key,
+		Now:          now.UTC(),
+		ForgetBefore: forgetBefore.UTC(),
+	})
+	if err != nil {
+		return lockout.State{}, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return lockout.State{}, false, err
+	}
+	return lockout.State{Failures: int(attempt.Failures), LastFailure: attempt.LastFailureAt}, true, nil
+}
+
+func (s *dbLockoutStore) Forgive(ctx context.Context, key string) error {
+	return s.db.ForgiveLoginFailure(ctx, key)
+}
+
+func (s *dbLockoutStore) Reset(ctx context.Context, key string) error {
+	return s.db.DeleteLoginAttempt(ctx, key)
+}
+
+// prune deletes failures that are too old to matter, at most once per window.
+// Failing to do so is only logged, as they're ignored anyway.
+func (s *dbLockoutStore) prune(ctx context.Context, now, forgetBefore time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.pruned.Before(forgetBefore) {
+		return
+	}
+	if err := s.db.DeleteStaleLoginAttempts(ctx, forgetBefore.UTC()); err != nil {
+		log.Print(fmt.Errorf("%v deleting stale login attempts: %w", warningTag, err))
+		return
+	}
+	s.pruned = now
+}
+
+// newLockoutStore returns where failed logins are kept: the database by
+// default, or memory when LOGIN_ATTEMPTS_STORE is "memory", which only works
+// with a single instance of the server.
+func newLockoutStore(db *database.Queries, conn *sql.DB) (lockout.Store, error) {
+	switch store := os.Getenv("LOGIN_ATTEMPTS_STORE"); store {
+	case "", "postgres":
+		return &dbLockoutStore{db: db, conn: conn}, nil
+	case "memory":
+		return lockout.NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown LOGIN_ATTEMPTS_STORE %q", store)
+	}
+}
+
+// accountLockoutKey and addressLockoutKey are the keys failed logins are kept
+// under. Emails are lowercased so that changing their case doesn't give an
+// attacker more attempts. IPv6 addresses are grouped by /64, as a single
+// client usually gets a whole one.
+func accountLockoutKey(email string) string {
+	return "account:" + strings.ToLower(email)
+}
+
+func addressLockoutKey(address string) string {
+	if ip := net.ParseIP(address); ip != nil {
+		if ip.To4() == nil {
+			ip = ip.Mask(net.CIDRMask(64, 128))
+		}
+		address = ip.String()
+	}
+	return "ip:" + address
+}
+
+// setRetryAfter tells the client how many seconds to wait before trying
+// again.
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
+
+// loginAttempt is an attempt to log in to the account with the given email,
+// which counts as failed until it succeeds.
+type loginAttempt struct {
+	email string
+	// wait is how long the client must wait before trying again once the
+	// attempt fails, or right away when it wasn't allowed. locked tells
+	// whether that's a lockout rather than a delay
+	wait   time.Duration
+	locked bool
+}
+
+// reserveLogin records an attempt to log in to the account with the given
+// email as a failure before the credentials are checked, both for the account
+// and for the address of the client, so that a burst of concurrent guesses
+// can't get past the limits. When the client has to wait instead, nothing is
+// recorded, allowed is false and the attempt tells how long to wait.
+func (cfg *apiConfig) reserveLogin(r *http.Request, email string) (attempt loginAttempt, allowed bool, err error) {
+	addressKey := addressLockoutKey(clientIP(r))
+	address, err := cfg.addressLimiter.Reserve(r.Context(), addressKey)
+	if err != nil {
+		return loginAttempt{}, false, err
+	}
+	account, err := cfg.accountLimiter.Reserve(r.Context(), accountLockoutKey(email))
+	if err != nil {
+		if address.Allowed {
+			giveBackLogin(r.Context(), cfg.addressLimiter, addressKey)
+		}
+		return loginAttempt{}, false, err
+	}
+
+	if address.Allowed && account.Allowed {
+		return loginAttempt{
+			email:  email,
+			wait:   max(address.Wait, account.Wait),
+			locked: account.Locked,
+		}, true, nil
+	}
+	// an attempt reserved on one side only is given back
+	if address.Allowed {
+		giveBackLogin(r.Context(), cfg.addressLimiter, addressKey)
+	}
+	if account.Allowed {
+		giveBackLogin(r.Context(), cfg.accountLimiter, accountLockoutKey(email))
+	}
+	attempt = loginAttempt{email: email}
+	for _, reservation := range []lockout.Reservation{address, account} {
+		if !reservation.Allowed {
+			attempt.wait = max(attempt.wait, reservation.Wait)
+			attempt.locked = attempt.locked || reservation.Locked
+		}
+	}
+	log.Printf("%v login to %q from %v throttled for %v", securityTag, email, clientIP(r), attempt.wait)
+	return attempt, false, nil
+}
+
+// giveBackLogin forgives an attempt that was reserved with reserveLogin but
+// didn't fail. Failing to do so is only logged, as it only delays the client.
+func giveBackLogin(ctx context.Context, limiter *lockout.Limiter, key string) {
+	if err := limiter.Forgive(ctx, key); err != nil {
+		log.Print(fmt.Errorf("%v giving back login attempt: %w", warningTag, err))
+	}
+}
+
+// forgiveLogin gives back an attempt reserved with reserveLogin that neither
+// failed nor succeeded, keeping the failures before it.
+func (cfg *apiConfig) forgiveLogin(r *http.Request, email string) {
+	giveBackLogin(r.Context(), cfg.addressLimiter, addressLockoutKey(clientIP(r)))
+	giveBackLogin(r.Context(), cfg.accountLimiter, accountLockoutKey(email))
+}
+
+// checkLoginAllowed reserves an attempt to log in to the account with the
+// given email with reserveLogin. When the client has to wait, or that can't
+// be known, it responds to the client itself and returns false.
+func (cfg *apiConfig) checkLoginAllowed(w http.ResponseWriter, r *http.Request, email string) (loginAttempt, bool) {
+	attempt, allowed, err := cfg.reserveLogin(r, email)
+	if err != nil {
+		log.Print(fmt.Errorf("%v checking failed logins: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't check failed logins")
+		return loginAttempt{}, false
+	}
+	if allowed {
+		return attempt, true
+	}
+	setRetryAfter(w, attempt.wait)
+	if attempt.locked {
+		respondWithError(w, http.StatusTooManyRequests, "too many failed logins: temporarily locked out")
+	} else {
+		respondWithError(w, http.StatusTooManyRequests, "too many failed logins: try again later")
+	}
+	return loginAttempt{}, false
+}
+
+// recordFailedLogin records a failed login to the account with the given
+// email that wasn't reserved with reserveLogin, like a wrong one-time
+// password. Failing to record it is only logged, so that the login is still
+// rejected.
+func (cfg *apiConfig) recordFailedLogin(r *http.Request, email string) loginAttempt {
+	accountWait, accountLocked, err := cfg.accountLimiter.Fail(r.Context(), accountLockoutKey(email))
+	if err != nil {
+		log.Print(fmt.Errorf("%v recording failed login: %w", errorTag, err))
+	}
+	addressWait, _, err := cfg.addressLimiter.Fail(r.Context(), addressLockoutKey(clientIP(r)))
+	if err != nil {
+		log.Print(fmt.Errorf("%v recording failed login: %w", errorTag, err))
+	}
+	return loginAttempt{email: email, wait: max(accountWait, addressWait), locked: accountLocked}
+}
+
+// failLogin rejects a failed login, which was already recorded. When the next
+// attempt has to wait, the client is told how long.
+func failLogin(w http.ResponseWriter, attempt loginAttempt, message string) {
+	setLoginRetry(w, attempt)
+	respondWithError(w, http.StatusUnauthorized, message)
+}
+
+// setLoginRetry tells the client how long to wait before trying to log in
+// again after a failed attempt, if it has to.
+func setLoginRetry(w http.ResponseWriter, attempt loginAttempt) {
+	if attempt.locked {
+		log.Printf("%v account %q locked out after too many failed logins", securityTag, attempt.email)
+	}
+	if attempt.wait > 0 {
+		setRetryAfter(w, attempt.wait)
+	}
+}
+
+// succeedLogin forgets the failed logins to an account. From the address of
+// the client, only the attempt that succeeded is forgiven, so that logging in
+// to an account of their own doesn't let an attacker keep guessing the
+// passwords of others.
+func (cfg *apiConfig) succeedLogin(r *http.Request, email string) {
+	if err := cfg.accountLimiter.Reset(r.Context(), accountLockoutKey(email)); err != nil {
+		log.Print(fmt.Errorf("%v forgetting failed logins: %w", warningTag, err))
+	}
+	if err := cfg.addressLimiter.Forgive(r.Context(), addressLockoutKey(clientIP(r))); err != nil {
+		log.Print(fmt.Errorf("%v forgetting failed logins: %w", warningTag, err))
+	}
+}
+
+func (cfg *apiConfig) handlerUnlockLogin(w http.ResponseWriter, r *http.Request) {
+	admin := accessTokenFromContext(r.Context())
+
+	type payload struct {
+		Email     string `json:"email"`
+		IPAddress string `json:"ip_address"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	var data payload
+	if err := decoder.Decode(&data); err != nil {
+		log.Print(fmt.Errorf("%v decoding non-conforming JSON request: %w", errorTag, err))
+		respondWithError(w, http.StatusBadRequest, "non-conforming JSON received")
+		return
+	}
+	if data.Email == "" && data.IPAddress == "" {
+		respondWithError(w, http.StatusBadRequest, "request error: send the email of an account, an IP address or both")
+		return
+	}
+	if data.IPAddress != "" && net.ParseIP(data.IPAddress) == nil {
+		respondWithError(w, http.StatusBadRequest, "request error: not a valid IP address")
+		return
+	}
+
+	if data.Email != "" {
+		if err := cfg.accountLimiter.Reset(r.Context(), accountLockoutKey(data.Email)); err != nil {
+			log.Print(fmt.Errorf("%v unlocking account: %w", errorTag, err))
+			respondWithError(w, http.StatusInternalServerError, "database error: couldn't unlock account")
+			return
+		}
+		log.Printf("%v account %q unlocked by administrator %q", securityTag, data.Email, admin.UserID)
+	}
+	if data.IPAddress != "" {
+		if err := cfg.addressLimiter.Reset(r.Context(), addressLockoutKey(data.IPAddress)); err != nil {
+			log.Print(fmt.Errorf("%v unlocking IP address: %w", errorTag, err))
+			respondWithError(w, http.StatusInternalServerError, "database error: couldn't unlock IP address")
+			return
+		}
+		log.Printf("%v IP address %v unlocked by administrator %q", securityTag, data.IPAddress, admin.UserID)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/neira-daniel/go-chirpy/internal/mail"
+)
+
+// mailTimeout is how long we wait for the mail server to take an email.
+const mailTimeout = 30 * time.Second
+
+// newMailer returns the mailer configured in the environment: the SMTP server
+// in SMTP_ADDR or, for local development, files written to MAIL_DIR or, when
+// neither is set, the log.
+func newMailer() mail.Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Chirpy <no-reply@localhost>"
+	}
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		return mail.SMTPMailer{
+			Addr:     addr,
+			From:     from,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}
+	}
+	if dir := os.Getenv("MAIL_DIR"); dir != "" {
+		return mail.FileMailer{Dir: dir, From: from}
+	}
+	log.Printf("%v neither SMTP_ADDR nor MAIL_DIR is set: emails will be written to the log", warningTag)
+	return mail.LogMailer{}
+}
+
+// sendEmail sends an email in the background, so that responses neither wait
+// for the mail server nor tell by their timing whether an email was sent.
+func (cfg *apiConfig) sendEmail(msg mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+		if err := cfg.mailer.Send(ctx, msg); err != nil {
+			log.Print(fmt.Errorf("%v sending email with subject %q: %w", errorTag, msg.Subject, err))
+			return
+		}
+		log.Printf("%v sent email with subject %q", successTag, msg.Subject)
+	}()
+}
@@ -1,76 +1,242 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"sort"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/neira-daniel/go-chirpy/internal/auth"
+	"github.com/neira-daniel/go-chirpy/internal/chirptext"
 	"github.com/neira-daniel/go-chirpy/internal/database"
+	"github.com/neira-daniel/go-chirpy/internal/lockout"
+	"github.com/neira-daniel/go-chirpy/internal/mail"
+	"github.com/neira-daniel/go-chirpy/internal/moderation"
+	"github.com/neira-daniel/go-chirpy/internal/passwordpolicy"
 )
 
 const (
-	successTag = "[ success ]"
-	warningTag = "[ warning ]"
-	errorTag   = "[  error  ]"
-	okTag      = "[   ok    ]"
+	successTag  = "[ success ]"
+	warningTag  = "[ warning ]"
+	errorTag    = "[  error  ]"
+	okTag       = "[   ok    ]"
+	securityTag = "[security ]"
 )
 
 type User struct {
-	Id           uuid.UUID `json:"id"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	Email        string    `json:"email"`
-	Token        string    `json:"token,omitempty"`
-	RefreshToken string    `json:"refresh_token,omitempty"`
-	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	Id               uuid.UUID `json:"id"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	Email            string    `json:"email"`
+	Handle           string    `json:"handle,omitempty"`
+	Token            string    `json:"token,omitempty"`
+	RefreshToken     string    `json:"refresh_token,omitempty"`
+	IsChirpyRed      bool      `json:"is_chirpy_red"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	EmailVerified    bool      `json:"email_verified"`
+	PendingEmail     string    `json:"pending_email,omitempty"`
+	Role             string    `json:"role"`
 }
 
 func addTagsToUser(user database.User, token string, refreshToken string) User {
 	return User{
-		Id:           user.ID,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-		Email:        user.Email,
-		Token:        token,
-		RefreshToken: refreshToken,
-		IsChirpyRed:  user.IsChirpyRed,
+		Id:               user.ID,
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
+		Email:            user.Email,
+		Handle:           user.Handle.String,
+		Token:            token,
+		RefreshToken:     refreshToken,
+		IsChirpyRed:      user.IsChirpyRed,
+		TwoFactorEnabled: user.TotpEnabledAt.Valid,
+		EmailVerified:    user.EmailVerifiedAt.Valid,
+		PendingEmail:     user.PendingEmail.String,
+		Role:             user.Role,
 	}
 }
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Body      string     `json:"body"`
+	UserID    uuid.UUID  `json:"user_id"`
+	InReplyTo *uuid.UUID `json:"in_reply_to"`
+	ThreadID  uuid.UUID  `json:"thread_id"`
+	EditedAt  *time.Time `json:"edited_at"`
+	Mentions  []Mention  `json:"mentions"`
+	LikeCount int64      `json:"like_count"`
+	LikedByMe bool       `json:"liked_by_me"`
+	// RechirpOf is nil for rechirps whose original was deleted, which only
+	// quote chirps survive. RechirpedChirp embeds the original one level deep.
+	RechirpOf      *uuid.UUID `json:"rechirp_of"`
+	IsQuote        bool       `json:"is_quote"`
+	RechirpedChirp *Chirp     `json:"rechirped_chirp"`
+	RechirpCount   int64      `json:"rechirp_count"`
+	QuoteCount     int64      `json:"quote_count"`
 }
 
 func addTagsToChirp(chirp database.Chirp) Chirp {
+	var inReplyTo *uuid.UUID
+	if chirp.InReplyTo.Valid {
+		inReplyTo = &chirp.InReplyTo.UUID
+	}
+	var editedAt *time.Time
+	if chirp.EditedAt.Valid {
+		editedAt = &chirp.EditedAt.Time
+	}
+	var rechirpOf *uuid.UUID
+	if chirp.RechirpOf.Valid {
+		rechirpOf = &chirp.RechirpOf.UUID
+	}
 	return Chirp{
 		ID:        chirp.ID,
 		CreatedAt: chirp.CreatedAt,
 		UpdatedAt: chirp.UpdatedAt,
 		Body:      chirp.Body,
 		UserID:    chirp.UserID,
+		InReplyTo: inReplyTo,
+		ThreadID:  chirp.ThreadID,
+		EditedAt:  editedAt,
+		Mentions:  []Mention{},
+		RechirpOf: rechirpOf,
+		IsQuote:   chirp.IsQuote,
 	}
 }
 
+// presentChirps turns chirps into their JSON representation, loading what
+// they reference for all of them at once instead of chirp by chirp. The viewer
+// is the user the chirps are presented to, if known.
+func (cfg *apiConfig) presentChirps(ctx context.Context, viewer uuid.NullUUID, chirps []database.Chirp) ([]Chirp, error) {
+	if len(chirps) == 0 {
+		return []Chirp{}, nil
+	}
+
+	// the chirps that rechirps embed are loaded along with everything else
+	var originalIDs []uuid.UUID
+	for _, chirp := range chirps {
+		if chirp.RechirpOf.Valid {
+			originalIDs = append(originalIDs, chirp.RechirpOf.UUID)
+		}
+	}
+	if len(originalIDs) == 0 {
+		return cfg.addDetailsToChirps(ctx, viewer, chirps)
+	}
+	originals, err := cfg.db.GetChirpsByIDs(ctx, originalIDs)
+	if err != nil {
+		return nil, fmt.Errorf("getting rechirped chirps: %w", err)
+	}
+
+	chirpsWithTags, err := cfg.addDetailsToChirps(ctx, viewer, append(chirps[:len(chirps):len(chirps)], originals...))
+	if err != nil {
+		return nil, err
+	}
+	originalsByID := make(map[uuid.UUID]Chirp, len(originals))
+	for _, original := range chirpsWithTags[len(chirps):] {
+		originalsByID[original.ID] = original
+	}
+	chirpsWithTags = chirpsWithTags[:len(chirps)]
+	for i := range chirpsWithTags {
+		if chirpsWithTags[i].RechirpOf == nil {
+			continue
+		}
+		if original, ok := originalsByID[*chirpsWithTags[i].RechirpOf]; ok {
+			chirpsWithTags[i].RechirpedChirp = &original
+		}
+	}
+	return chirpsWithTags, nil
+}
+
+// addDetailsToChirps does the work of presentChirps save for embedding the
+// chirps that rechirps repost.
+func (cfg *apiConfig) addDetailsToChirps(ctx context.Context, viewer uuid.NullUUID, chirps []database.Chirp) ([]Chirp, error) {
+	chirpIDs := make([]uuid.UUID, len(chirps))
+	for i, chirp := range chirps {
+		chirpIDs[i] = chirp.ID
+	}
+
+	mentions, err := cfg.db.GetMentionsByChirps(ctx, chirpIDs)
+	if err != nil {
+		return nil, fmt.Errorf("getting mentions: %w", err)
+	}
+	mentionsByChirp := make(map[uuid.UUID][]database.Mention)
+	for _, mention := range mentions {
+		mentionsByChirp[mention.ChirpID] = append(mentionsByChirp[mention.ChirpID], mention)
+	}
+
+	likeCounts, err := cfg.db.GetLikeCounts(ctx, chirpIDs)
+	if err != nil {
+		return nil, fmt.Errorf("getting like counts: %w", err)
+	}
+	likeCountByChirp := make(map[uuid.UUID]int64, len(likeCounts))
+	for _, likeCount := range likeCounts {
+		likeCountByChirp[likeCount.ChirpID] = likeCount.LikeCount
+	}
+
+	likedByViewer := make(map[uuid.UUID]struct{})
+	if viewer.Valid {
+		likedChirpIDs, err := cfg.db.GetLikedChirpIDs(ctx, database.GetLikedChirpIDsParams{
+			UserID:   viewer.UUID,
+			ChirpIds: chirpIDs,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("getting chirps liked by the viewer: %w", err)
+		}
+		for _, chirpID := range likedChirpIDs {
+			likedByViewer[chirpID] = struct{}{}
+		}
+	}
+
+	rechirpCounts, err := cfg.db.GetRechirpCounts(ctx, chirpIDs)
+	if err != nil {
+		return nil, fmt.Errorf("getting rechirp counts: %w", err)
+	}
+	rechirpCountsByChirp := make(map[uuid.UUID]database.GetRechirpCountsRow, len(rechirpCounts))
+	for _, rechirpCount := range rechirpCounts {
+		rechirpCountsByChirp[rechirpCount.ChirpID] = rechirpCount
+	}
+
+	chirpsWithTags := make([]Chirp, len(chirps))
+	for i, chirp := range chirps {
+		chirpsWithTags[i] = addTagsToChirp(chirp)
+		chirpsWithTags[i].Mentions = addTagsToMentions(chirp.Body, mentionsByChirp[chirp.ID])
+		chirpsWithTags[i].LikeCount = likeCountByChirp[chirp.ID]
+		_, chirpsWithTags[i].LikedByMe = likedByViewer[chirp.ID]
+		chirpsWithTags[i].RechirpCount = rechirpCountsByChirp[chirp.ID].RechirpCount
+		chirpsWithTags[i].QuoteCount = rechirpCountsByChirp[chirp.ID].QuoteCount
+	}
+	return chirpsWithTags, nil
+}
+
+type ChirpsPage struct {
+	Chirps     []Chirp    `json:"chirps"`
+	Pagination Pagination `json:"pagination"`
+}
+
 type apiConfig struct {
 	db             *database.Queries
-	platform       string
-	signingSecret  string
+	conn           *sql.DB
+	signingKeys    atomic.Pointer[auth.KeySet]
+	validator      auth.Validator
+	mailer         mail.Mailer
+	baseURL        string
+	verifyToPost   bool // users must verify their email before posting
+	passwords      auth.Passwords
+	passwordPolicy passwordpolicy.Policy
+	accountLimiter *lockout.Limiter
+	addressLimiter *lockout.Limiter
 	polkaKey       string
+	moderator      *moderation.Moderator
 	fileserverHits atomic.Int32 // safe across goroutines
 }
 
@@ -94,11 +260,6 @@ func (cfg *apiConfig) handlerMetrics(w http.ResponseWriter, _ *http.Request) {
 }
 
 func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
-	if cfg.platform != "dev" {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
 	if err := cfg.db.ResetDatabase(r.Context()); err != nil {
 		log.Printf("%v resetting the database", errorTag)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -122,6 +283,23 @@ func respondWithError(w http.ResponseWriter, statusCode int, message string) {
 	respondWithJSON(w, statusCode, errorResponse{Error: message})
 }
 
+// respondWithFieldErrors rejects a request because of the values of some of
+// its fields, listing what's wrong with each of them by field name.
+func respondWithFieldErrors(w http.ResponseWriter, statusCode int, message string, fields map[string][]string) {
+	type errorResponse struct {
+		Error  string              `json:"error"`
+		Fields map[string][]string `json:"fields"`
+	}
+	respondWithJSON(w, statusCode, errorResponse{Error: message, Fields: fields})
+}
+
+// isUniqueViolation reports whether err was caused by a row that would have
+// broken the given unique constraint or index.
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
 func respondWithJSON(w http.ResponseWriter, statusCode int, payload any) {
 	jsonResponse, err := json.Marshal(payload)
 	if err != nil {
@@ -134,6 +312,190 @@ func respondWithJSON(w http.ResponseWriter, statusCode int, payload any) {
 	w.Write(jsonResponse)
 }
 
+// validateJWT validates an access token, rejecting those issued before the
+// credentials of their user last changed.
+func (cfg *apiConfig) validateJWT(ctx context.Context, tokenString string) (auth.AccessToken, error) {
+	var role auth.Role
+	accessToken, err := cfg.validator.Validate(cfg.signingKeys.Load(), tokenString, func(userID uuid.UUID) (int32, error) {
+		user, err := cfg.db.GetUserTokenVersionAndRole(ctx, userID)
+		role = auth.Role(user.Role)
+		return user.TokenVersion, err
+	})
+	if err != nil {
+		return auth.AccessToken{}, err
+	}
+	// the tokens of a login carry the role of the user, so they stop working
+	// when it changes. Tokens limited to scopes have none and keep working
+	if accessToken.Role != "" && accessToken.Role != role {
+		return auth.AccessToken{}, auth.ErrTokenRevoked
+	}
+	return accessToken, nil
+}
+
+// validateAccessToken validates a bearer token, which is either a JWT or a
+// personal access token.
+func (cfg *apiConfig) validateAccessToken(ctx context.Context, tokenString string) (auth.AccessToken, error) {
+	if auth.IsPersonalAccessToken(tokenString) {
+		return cfg.validatePersonalAccessToken(ctx, tokenString)
+	}
+	accessToken, err := cfg.validateJWT(ctx, tokenString)
+	if err != nil {
+		return auth.AccessToken{}, err
+	}
+	// access tokens stop working as soon as the session or OAuth grant they
+	// were issued under is revoked, rather than when they expire
+	if accessToken.SessionID != uuid.Nil {
+		active, err := cfg.db.IsTokenFamilyActive(ctx, accessToken.SessionID)
+		if err != nil {
+			return auth.AccessToken{}, fmt.Errorf("checking session: %w", err)
+		}
+		if !active {
+			return auth.AccessToken{}, auth.ErrTokenRevoked
+		}
+	}
+	return accessToken, nil
+}
+
+// viewerID returns the ID of the user making the request on endpoints that
+// don't require authentication but can tailor their response to the user.
+// Anonymous requests, and those with an invalid access token or one without
+// the chirps:read scope, have no viewer.
+func (cfg *apiConfig) viewerID(r *http.Request) uuid.NullUUID {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	accessToken, err := cfg.validateAccessToken(r.Context(), bearerToken)
+	if err != nil || !accessToken.HasScope(auth.ScopeChirpsRead) {
+		return uuid.NullUUID{}
+	}
+	userID := accessToken.UserID
+	return uuid.NullUUID{UUID: userID, Valid: true}
+}
+
+// withTx runs fn inside a database transaction that is committed only when fn
+// succeeds.
+func (cfg *apiConfig) withTx(ctx context.Context, fn func(qtx *database.Queries) error) error {
+	tx, err := cfg.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	// rolling back a committed transaction is a no-op
+	defer tx.Rollback()
+
+	if err := fn(cfg.db.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// loginOnly is the scope of endpoints that tokens limited to scopes can't be
+// used for, like the ones managing sessions and tokens. Only the access tokens
+// of a login are accepted by them.
+const loginOnly auth.Scope = ""
+
+// authenticate validates the access token sent in the Authorization header and
+// checks that it can be used for the given scope. When that's not possible, it
+// responds to the client itself and returns false.
+func (cfg *apiConfig) authenticate(w http.ResponseWriter, r *http.Request, scope auth.Scope) (auth.AccessToken, bool) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting bearer token: %w", warningTag, err))
+		w.Header().Set("WWW-Authenticate", `Bearer realm="chirpy", error="invalid_request"`)
+		respondWithError(w, http.StatusBadRequest, "invalid request")
+		return auth.AccessToken{}, false
+	}
+	accessToken, err := cfg.validateAccessToken(r.Context(), bearerToken)
+	if err != nil {
+		log.Print(fmt.Errorf("%v validating access token: %w", warningTag, err))
+		respondUnauthorized(w, err)
+		return auth.AccessToken{}, false
+	}
+	if !accessToken.HasScope(scope) {
+		log.Printf("%v access token of user %q lacks scope %q for %v", warningTag, accessToken.UserID, scope, r.URL.Path)
+		respondInsufficientScope(w, scope)
+		return auth.AccessToken{}, false
+	}
+	return accessToken, true
+}
+
+// authenticateUser validates the access token sent in the Authorization header,
+// checks that it can be used for the given scope and returns the ID of the
+// user it was issued to. When that's not possible, it responds to the client
+// itself and returns false.
+func (cfg *apiConfig) authenticateUser(w http.ResponseWriter, r *http.Request, scope auth.Scope) (uuid.UUID, bool) {
+	accessToken, ok := cfg.authenticate(w, r, scope)
+	if !ok {
+		return uuid.Nil, false
+	}
+	return accessToken.UserID, true
+}
+
+// respondInsufficientScope tells the client that its access token can't be
+// used for the endpoint and which scope it would need, as RFC 6750 asks.
+func respondInsufficientScope(w http.ResponseWriter, scope auth.Scope) {
+	if scope == loginOnly {
+		description := "access token is limited to scopes, log in instead"
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="chirpy", error="insufficient_scope", error_description=%q`, description))
+		respondWithError(w, http.StatusForbidden, description)
+		return
+	}
+	description := fmt.Sprintf("access token lacks the %v scope", scope)
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="chirpy", error="insufficient_scope", error_description=%q, scope=%q`, description, scope))
+	respondWithError(w, http.StatusForbidden, description)
+}
+
+// respondUnauthorized tells the client why its access token was rejected, both
+// in the body and in the WWW-Authenticate header, as RFC 6750 asks.
+func respondUnauthorized(w http.ResponseWriter, err error) {
+	var description string
+	switch {
+	case errors.Is(err, auth.ErrTokenExpired):
+		description = "access token expired"
+	case errors.Is(err, auth.ErrTokenNotValidYet):
+		description = "access token isn't valid yet"
+	case errors.Is(err, auth.ErrTokenSignatureInvalid):
+		description = "access token signature is invalid"
+	case errors.Is(err, auth.ErrTokenWrongIssuer):
+		description = "access token wasn't issued by chirpy"
+	case errors.Is(err, auth.ErrTokenWrongAudience):
+		description = "access token wasn't issued for this API"
+	case errors.Is(err, auth.ErrTokenMissingClaim):
+		description = "access token is missing required claims"
+	case errors.Is(err, auth.ErrTokenRevoked):
+		description = "access token was revoked"
+	default:
+		description = "access token is invalid"
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="chirpy", error="invalid_token", error_description=%q`, description))
+	respondWithError(w, http.StatusUnauthorized, description)
+}
+
+// chirpFromPath returns the chirp named in the URL. When that's not possible,
+// it responds to the client itself and returns false.
+func (cfg *apiConfig) chirpFromPath(w http.ResponseWriter, r *http.Request) (database.Chirp, bool) {
+	match := r.PathValue("chirpID")
+	if match == "" {
+		respondWithError(w, http.StatusBadRequest, "request error: missing chirp ID")
+		return database.Chirp{}, false
+	}
+
+	chirpID, err := uuid.Parse(match)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "request error: not a valid chirp UUID")
+		return database.Chirp{}, false
+	}
+
+	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		log.Print(fmt.Errorf("%v chirp id=%q not found: %w", warningTag, chirpID, err))
+		respondWithError(w, http.StatusNotFound, "chirp doesn't exist")
+		return database.Chirp{}, false
+	}
+
+	return chirp, true
+}
+
 func validateChirp(chirp string) error {
 	const maxChirpLength = 140
 	if chirpLength := len([]rune(chirp)); chirpLength > maxChirpLength {
@@ -142,27 +504,11 @@ func validateChirp(chirp string) error {
 	return nil
 }
 
-func censorChirp(message string, badWords map[string]struct{}) string {
-	// With strings.Fields we're splitting the message at every instance of 1+
-	// consecutive whitespace characters according to what's defined in
-	// unicode.IsSpace (where,  for example, \n is considered whitespace).
-	// The alternative: strings.Split(message, " "), where we'd split the message at
-	// every instance of " ".
-	// What to actually use in the end will depend on the app requirements.
-	words := strings.Fields(message)
-	for i, word := range words {
-		_, ok := badWords[strings.ToLower(word)]
-		if ok {
-			words[i] = "****"
-		}
-	}
-	return strings.Join(words, " ")
-}
-
 func (cfg *apiConfig) handlerUser(w http.ResponseWriter, r *http.Request) {
 	type jsonRequest struct {
 		Password string `json:"password"`
 		Email    string `json:"email"`
+		Handle   string `json:"handle"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -174,43 +520,71 @@ func (cfg *apiConfig) handlerUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hashedPassword, err := auth.HashPassword(data.Password)
+	email, ok := parseEmail(w, data.Email)
+	if !ok {
+		return
+	}
+	handle, ok := parseHandle(w, data.Handle)
+	if !ok {
+		return
+	}
+	if !cfg.checkPassword(w, data.Password, email) {
+		return
+	}
+
+	hashedPassword, err := cfg.passwords.Hash(data.Password)
 	if err != nil {
 		log.Print(fmt.Errorf("%v couldn't hash password for user %q: %w", errorTag, data.Email, err))
 		respondWithError(w, http.StatusInternalServerError, "server error: couldn't hash password")
 		return
 	}
 
-	user, err := cfg.db.CreateUser(r.Context(), database.CreateUserParams{
-		Email:          data.Email,
-		HashedPassword: hashedPassword,
+	var user database.User
+	var verificationToken string
+	err = cfg.withTx(r.Context(), func(qtx *database.Queries) error {
+		var err error
+		user, err = qtx.CreateUser(r.Context(), database.CreateUserParams{
+			Email:          email,
+			HashedPassword: hashedPassword,
+			Handle:         handle,
+		})
+		if err != nil {
+			return err
+		}
+		verificationToken, err = startEmailVerification(r.Context(), qtx, user.ID, user.Email)
+		return err
 	})
+	if isUniqueViolation(err, "users_handle_idx") {
+		respondWithError(w, http.StatusConflict, "handle already taken")
+		return
+	}
+	if isUniqueViolation(err, "users_email_key") {
+		respondWithError(w, http.StatusConflict, "email already taken")
+		return
+	}
 	if err != nil {
 		log.Print(fmt.Errorf("%v creating new database user for %q: %w", errorTag, data.Email, err))
 		respondWithError(w, http.StatusInternalServerError, "database error: couldn't create user")
 		return
 	}
+	cfg.sendVerificationEmail(user.Email, verificationToken)
 
 	log.Printf("%v user %q created", successTag, user.Email)
 	respondWithJSON(w, http.StatusCreated, addTagsToUser(user, "", ""))
 }
 
 func (cfg *apiConfig) handlerChirps(w http.ResponseWriter, r *http.Request) {
-	jwt, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Print(fmt.Errorf("%v getting bearer token: %w", warningTag, err))
-		respondWithError(w, http.StatusBadRequest, "invalid request")
+	userID, ok := cfg.authenticateUser(w, r, auth.ScopeChirpsWrite)
+	if !ok {
 		return
 	}
-	userID, err := auth.ValidateJWT(jwt, cfg.signingSecret)
-	if err != nil {
-		log.Print(fmt.Errorf("%v validating JWT: %w", warningTag, err))
-		respondWithError(w, http.StatusUnauthorized, "unauthorized action")
+	if !cfg.canPost(w, r, userID) {
 		return
 	}
 
 	type jsonRequest struct {
-		Body string `json:"body"`
+		Body      string     `json:"body"`
+		InReplyTo *uuid.UUID `json:"in_reply_to"`
 	}
 	// we use JSON Decode instead of Unmarshal because we're dealing with a stream
 	// of data instead of a []byte in memory
@@ -219,6 +593,7 @@ func (cfg *apiConfig) handlerChirps(w http.ResponseWriter, r *http.Request) {
 	if err := decoder.Decode(&data); err != nil {
 		log.Print(fmt.Errorf("%v decoding non-conforming JSON request: %w", errorTag, err))
 		respondWithError(w, http.StatusBadRequest, "non-conforming JSON received")
+		return
 	}
 
 	if err := validateChirp(data.Body); err != nil {
@@ -227,36 +602,129 @@ func (cfg *apiConfig) handlerChirps(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	badWords := map[string]struct{}{
-		"kerfuffle": {},
-		"sharbert":  {},
-		"fornax":    {},
+	moderated, ok := cfg.moderateChirp(w, data.Body)
+	if !ok {
+		return
+	}
+
+	// replies join the thread of the chirp they answer to
+	var inReplyTo, threadID uuid.NullUUID
+	if data.InReplyTo != nil {
+		parent, err := cfg.db.GetChirpByID(r.Context(), *data.InReplyTo)
+		if err != nil {
+			log.Print(fmt.Errorf("%v chirp id=%q to reply to not found: %w", warningTag, *data.InReplyTo, err))
+			respondWithError(w, http.StatusNotFound, "chirp to reply to doesn't exist")
+			return
+		}
+		inReplyTo = uuid.NullUUID{UUID: parent.ID, Valid: true}
+		threadID = uuid.NullUUID{UUID: parent.ThreadID, Valid: true}
 	}
-	censoredChirp := censorChirp(data.Body, badWords)
 
-	chirp, err := cfg.db.SaveChirp(r.Context(), database.SaveChirpParams{Body: censoredChirp, UserID: userID})
+	chirp, err := cfg.storeChirp(r.Context(), database.SaveChirpParams{
+		Body:      moderated.Text,
+		UserID:    userID,
+		InReplyTo: inReplyTo,
+		ThreadID:  threadID,
+	}, moderated.Words(moderation.ActionFlag))
 	if err != nil {
 		log.Print(fmt.Errorf("%v storing chirp in the database: %w", errorTag, err))
 		respondWithError(w, http.StatusInternalServerError, "database error: couldn't store chirp")
 		return
 	}
 
+	chirpsWithTags, err := cfg.presentChirps(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, []database.Chirp{chirp})
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting stored chirp details: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve chirp")
+		return
+	}
+
 	log.Printf("%v chirp stored in the database", successTag)
-	respondWithJSON(w, http.StatusCreated, addTagsToChirp(chirp))
+	respondWithJSON(w, http.StatusCreated, chirpsWithTags[0])
+}
+
+// storeChirp saves a chirp along with the hashtags and mentions in its body,
+// and flags it for review when it contains flagged words.
+func (cfg *apiConfig) storeChirp(ctx context.Context, params database.SaveChirpParams, flaggedWords []string) (database.Chirp, error) {
+	var chirp database.Chirp
+	err := cfg.withTx(ctx, func(qtx *database.Queries) error {
+		var err error
+		chirp, err = qtx.SaveChirp(ctx, params)
+		if err != nil {
+			return fmt.Errorf("saving chirp: %w", err)
+		}
+		if err := flagChirp(ctx, qtx, chirp.ID, flaggedWords); err != nil {
+			return err
+		}
+		return indexChirp(ctx, qtx, chirp)
+	})
+	return chirp, err
+}
+
+// indexChirp saves the hashtags and mentions in the body of a chirp.
+func indexChirp(ctx context.Context, qtx *database.Queries, chirp database.Chirp) error {
+	if tags := chirptext.Hashtags(chirp.Body); len(tags) > 0 {
+		if err := qtx.TagChirp(ctx, database.TagChirpParams{
+			ChirpID:   chirp.ID,
+			Tags:      tags,
+			CreatedAt: chirp.CreatedAt,
+		}); err != nil {
+			return fmt.Errorf("saving hashtags: %w", err)
+		}
+	}
+
+	if err := saveMentions(ctx, qtx, chirp); err != nil {
+		return fmt.Errorf("saving mentions: %w", err)
+	}
+	return nil
 }
 
 func (cfg *apiConfig) handlerGETChirps(w http.ResponseWriter, r *http.Request) {
-	var chirps []database.Chirp
-	var err error
-	if match := r.URL.Query().Get("author_id"); match == "" {
-		chirps, err = cfg.db.GetChirps(r.Context())
-	} else {
+	page, err := parsePageRequest(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("request error: %v", err))
+		return
+	}
+
+	var authorID uuid.NullUUID
+	if match := r.URL.Query().Get("author_id"); match != "" {
 		userID, err := uuid.Parse(match)
 		if err != nil {
-			respondWithError(w, http.StatusBadRequest, "request error: not a valid chirp UUID")
+			respondWithError(w, http.StatusBadRequest, "request error: not a valid author UUID")
 			return
 		}
-		chirps, err = cfg.db.GetChirpsByAuthor(r.Context(), userID)
+		authorID = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+
+	var ascending bool
+	switch r.URL.Query().Get("sort") {
+	case "", "asc":
+		ascending = true
+	case "desc":
+		ascending = false
+	default:
+		respondWithError(w, http.StatusBadRequest, "request error: sort must be either asc or desc")
+		return
+	}
+
+	// walking back to the previous page means reading the rows in the
+	// opposite order and then flipping them around
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	var chirps []database.Chirp
+	if ascending != page.backward() {
+		chirps, err = cfg.db.ListChirpsAscending(r.Context(), database.ListChirpsAscendingParams{
+			AuthorID:        authorID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageSize:        page.fetchSize(),
+		})
+	} else {
+		chirps, err = cfg.db.ListChirpsDescending(r.Context(), database.ListChirpsDescendingParams{
+			AuthorID:        authorID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageSize:        page.fetchSize(),
+		})
 	}
 	if err != nil {
 		log.Print(fmt.Errorf("%v getting chirps from the database: %w", errorTag, err))
@@ -264,15 +732,18 @@ func (cfg *apiConfig) handlerGETChirps(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if match := r.URL.Query().Get("sort"); match == "desc" {
-		sort.Slice(chirps, func(i, j int) bool { return chirps[i].CreatedAt.After(chirps[j].CreatedAt) })
+	chirps, next, prev := paginate(chirps, page, chirpCursor)
+	chirpsWithTags, err := cfg.presentChirps(r.Context(), cfg.viewerID(r), chirps)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting chirp details from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve chirps")
+		return
 	}
 
-	chirpsWithTags := make([]Chirp, len(chirps))
-	for i, chirp := range chirps {
-		chirpsWithTags[i] = addTagsToChirp(chirp)
-	}
-	respondWithJSON(w, http.StatusOK, chirpsWithTags)
+	respondWithJSON(w, http.StatusOK, ChirpsPage{
+		Chirps:     chirpsWithTags,
+		Pagination: setPaginationLinks(w, r, page, next, prev),
+	})
 }
 
 func (cfg *apiConfig) handlerGETChirpByID(w http.ResponseWriter, r *http.Request) {
@@ -295,13 +766,21 @@ func (cfg *apiConfig) handlerGETChirpByID(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, addTagsToChirp(chirp))
+	chirpsWithTags, err := cfg.presentChirps(r.Context(), cfg.viewerID(r), []database.Chirp{chirp})
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting chirp details from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve chirp")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirpsWithTags[0])
 }
 
 func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 	type payload struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
+		Password   string `json:"password"`
+		Email      string `json:"email"`
+		DeviceName string `json:"device_name"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -313,21 +792,65 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	deviceName, ok := parseDeviceName(w, data.DeviceName)
+	if !ok {
+		return
+	}
+	attempt, ok := cfg.checkLoginAllowed(w, r, data.Email)
+	if !ok {
+		return
+	}
+
+	// unknown emails and wrong passwords get the same response, so as not to
+	// reveal which accounts exist
 	user, err := cfg.db.GetUserByEmail(r.Context(), data.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("%v login to unknown account %q", warningTag, data.Email)
+		failLogin(w, attempt, "wrong email or password")
+		return
+	}
 	if err != nil {
 		log.Print(fmt.Errorf("%v getting user from the database: %w", errorTag, err))
 		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve user")
 		return
 	}
 
-	if err := auth.CheckPasswordHash(user.HashedPassword, data.Password); err != nil {
+	rehash, err := cfg.passwords.Check(user.HashedPassword, data.Password)
+	if errors.Is(err, auth.ErrPasswordMismatch) {
 		log.Printf("%v wrong password for %q", warningTag, data.Email)
-		respondWithError(w, http.StatusUnauthorized, "wrong password")
+		failLogin(w, attempt, "wrong email or password")
+		return
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v checking password of %q: %w", errorTag, data.Email, err))
+		respondWithError(w, http.StatusInternalServerError, "server error: couldn't check password")
 		return
 	}
+	if rehash {
+		cfg.rehashPassword(r.Context(), user, data.Password)
+	}
+
+	// the attempt counts as failed until the second factor is checked too
+	if user.TotpEnabledAt.Valid {
+		cfg.startMFAChallenge(w, r, user, deviceName)
+		return
+	}
+	cfg.succeedLogin(r, user.Email)
+	cfg.startSession(w, r, user, deviceName)
+}
 
+// startSession logs in a user whose credentials were checked, issuing them an
+// access token and the first refresh token of a new session.
+func (cfg *apiConfig) startSession(w http.ResponseWriter, r *http.Request, user database.User, deviceName sql.NullString) {
+	// every login starts a new family of refresh tokens
+	familyID := uuid.New()
 	JWTDuration := 1 * time.Hour
-	jwt, err := auth.MakeJWT(user.ID, cfg.signingSecret, JWTDuration)
+	jwt, err := cfg.signingKeys.Load().MakeJWT(auth.AccessToken{
+		UserID:       user.ID,
+		SessionID:    familyID,
+		TokenVersion: user.TokenVersion,
+		Role:         auth.Role(user.Role),
+	}, JWTDuration)
 	if err != nil {
 		log.Print(fmt.Errorf("%v couldn't sign token: %w", errorTag, err))
 		respondWithError(w, http.StatusInternalServerError, "couldn't create authentication string")
@@ -336,19 +859,44 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 	refreshToken, _ := auth.MakeRefreshToken()
 	var refreshTokenDuration int32 = 60
 	if err := cfg.db.StoreRefreshToken(r.Context(), database.StoreRefreshTokenParams{
-		Token:  refreshToken,
-		UserID: user.ID,
-		Days:   refreshTokenDuration,
+		TokenHash:  auth.HashRefreshToken(refreshToken),
+		UserID:     user.ID,
+		Days:       refreshTokenDuration,
+		FamilyID:   familyID,
+		UserAgent:  r.UserAgent(),
+		IpAddress:  clientIP(r),
+		DeviceName: deviceName,
 	}); err != nil {
 		log.Print(fmt.Errorf("%v couldn't store refreshToken: %w", errorTag, err))
 		respondWithError(w, http.StatusInternalServerError, "database error: couldn't store refresh token")
 		return
 	}
 
-	log.Printf("%v user %q has logged-in", successTag, data.Email)
+	log.Printf("%v user %q has logged-in", successTag, user.Email)
 	respondWithJSON(w, http.StatusOK, addTagsToUser(user, jwt, refreshToken))
 }
 
+// rehashPassword replaces the hash of the password of a user, which was just
+// checked, with one made by the current hasher. Failing to do it doesn't stop
+// the user from logging in, so errors are only logged.
+func (cfg *apiConfig) rehashPassword(ctx context.Context, user database.User, password string) {
+	newHash, err := cfg.passwords.Hash(password)
+	if err != nil {
+		log.Print(fmt.Errorf("%v rehashing password of user %q: %w", warningTag, user.ID, err))
+		return
+	}
+	// the password may have changed since we read its hash
+	if _, err := cfg.db.RehashPassword(ctx, database.RehashPasswordParams{
+		NewHash: newHash,
+		ID:      user.ID,
+		OldHash: user.HashedPassword,
+	}); err != nil {
+		log.Print(fmt.Errorf("%v storing rehashed password of user %q: %w", warningTag, user.ID, err))
+		return
+	}
+	log.Printf("%v password of user %q rehashed", successTag, user.ID)
+}
+
 func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 	refreshTokenReceived, err := auth.GetBearerToken(r.Header)
 	if err != nil {
@@ -357,26 +905,131 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	refreshTokenDB, err := cfg.db.GetRefreshToken(r.Context(), refreshTokenReceived)
-	if err != nil || refreshTokenDB.RevokedAt.Valid || time.Now().UTC().After(refreshTokenDB.ExpiresAt) {
+	refreshTokenDB, err := cfg.db.GetRefreshToken(r.Context(), auth.HashRefreshToken(refreshTokenReceived))
+	if err != nil {
 		log.Printf("%v got invalid refresh token", warningTag)
 		respondWithError(w, http.StatusUnauthorized, "invalid refresh token")
 		return
 	}
+	if usable, reused := refreshTokenUsable(refreshTokenDB); !usable {
+		if reused {
+			cfg.revokeTokenFamily(r.Context(), refreshTokenDB)
+		} else {
+			log.Printf("%v got invalid refresh token", warningTag)
+		}
+		respondWithError(w, http.StatusUnauthorized, "invalid refresh token")
+		return
+	}
+	// refreshing the tokens of an OAuth client here would drop their scopes
+	if refreshTokenDB.ClientID.Valid {
+		log.Printf("%v got refresh token of OAuth client %q", warningTag, refreshTokenDB.ClientID.UUID)
+		respondWithError(w, http.StatusUnauthorized, "invalid refresh token")
+		return
+	}
 
+	// the access token is signed before the refresh token is rotated, so
+	// that failing to sign it doesn't leave the client without a token. The
+	// role may have changed since the session started
+	user, err := cfg.db.GetUserByID(r.Context(), refreshTokenDB.UserID)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting user from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve user")
+		return
+	}
 	JWTDuration := 1 * time.Hour
-	jwt, err := auth.MakeJWT(refreshTokenDB.UserID, cfg.signingSecret, JWTDuration)
+	jwt, err := cfg.signingKeys.Load().MakeJWT(auth.AccessToken{
+		UserID:       refreshTokenDB.UserID,
+		SessionID:    refreshTokenDB.FamilyID,
+		TokenVersion: user.TokenVersion,
+		Role:         auth.Role(user.Role),
+	}, JWTDuration)
 	if err != nil {
 		log.Print(fmt.Errorf("%v couldn't sign token: %w", errorTag, err))
 		respondWithError(w, http.StatusInternalServerError, "couldn't create authentication string")
 		return
 	}
 
+	refreshToken, reused, err := cfg.rotateRefreshToken(r, refreshTokenDB)
+	if err != nil {
+		log.Print(fmt.Errorf("%v couldn't rotate refresh token: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't rotate refresh token")
+		return
+	}
+	if reused {
+		cfg.revokeTokenFamily(r.Context(), refreshTokenDB)
+		respondWithError(w, http.StatusUnauthorized, "invalid refresh token")
+		return
+	}
+
 	type payload struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 	log.Printf("%v JWT renewed", successTag)
-	respondWithJSON(w, http.StatusOK, payload{Token: jwt})
+	respondWithJSON(w, http.StatusOK, payload{Token: jwt, RefreshToken: refreshToken})
+}
+
+// refreshTokenUsable tells whether a refresh token can be exchanged for a new
+// one. reused reports whether it was exchanged already, which means that
+// someone else holds a copy of it. Tokens revoked some other way, like on
+// logout, are only unusable, even if they were rotated before.
+func refreshTokenUsable(refreshToken database.RefreshToken) (usable, reused bool) {
+	// rotating a token revokes it at the same time
+	revokedByRotation := refreshToken.RotatedAt.Valid && refreshToken.RevokedAt.Time.Equal(refreshToken.RotatedAt.Time)
+	switch {
+	case refreshToken.RevokedAt.Valid && !revokedByRotation:
+		return false, false
+	case refreshToken.RotatedAt.Valid:
+		return false, true
+	}
+	return !time.Now().UTC().After(refreshToken.ExpiresAt), false
+}
+
+// rotateRefreshToken exchanges a refresh token for a new one of the same
+// family, which keeps its client and scopes. reused reports whether someone
+// else rotated the token first, in which case no new token is issued.
+func (cfg *apiConfig) rotateRefreshToken(r *http.Request, refreshTokenDB database.RefreshToken) (refreshToken string, reused bool, err error) {
+	refreshToken, _ = auth.MakeRefreshToken()
+	var refreshTokenDuration int32 = 60
+	err = cfg.withTx(r.Context(), func(qtx *database.Queries) error {
+		rowsAffected, err := qtx.RotateRefreshToken(r.Context(), refreshTokenDB.TokenHash)
+		if err != nil {
+			return fmt.Errorf("rotating refresh token: %w", err)
+		}
+		// someone else rotated the token since we read it
+		if rowsAffected == 0 {
+			reused = true
+			return nil
+		}
+		if err := qtx.StoreRefreshToken(r.Context(), database.StoreRefreshTokenParams{
+			TokenHash:  auth.HashRefreshToken(refreshToken),
+			UserID:     refreshTokenDB.UserID,
+			Days:       refreshTokenDuration,
+			FamilyID:   refreshTokenDB.FamilyID,
+			UserAgent:  r.UserAgent(),
+			IpAddress:  clientIP(r),
+			DeviceName: refreshTokenDB.DeviceName,
+			ClientID:   refreshTokenDB.ClientID,
+			Scopes:     refreshTokenDB.Scopes,
+		}); err != nil {
+			return fmt.Errorf("storing refresh token: %w", err)
+		}
+		return nil
+	})
+	if err != nil || reused {
+		return "", reused, err
+	}
+	return refreshToken, false, nil
+}
+
+// revokeTokenFamily revokes every refresh token descending from the same login
+// as a refresh token that was used after being rotated. Only one of the
+// parties holding the token can be its owner, so neither can be trusted.
+func (cfg *apiConfig) revokeTokenFamily(ctx context.Context, refreshToken database.RefreshToken) {
+	log.Printf("%v rotated refresh token reused for user %q: revoking token family %q", securityTag, refreshToken.UserID, refreshToken.FamilyID)
+	if err := cfg.db.RevokeTokenFamily(ctx, refreshToken.FamilyID); err != nil {
+		log.Print(fmt.Errorf("%v couldn't revoke token family %q: %w", errorTag, refreshToken.FamilyID, err))
+	}
 }
 
 func (cfg *apiConfig) handlerRevokeAccess(w http.ResponseWriter, r *http.Request) {
@@ -387,7 +1040,7 @@ func (cfg *apiConfig) handlerRevokeAccess(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	result, err := cfg.db.RevokeAccess(r.Context(), refreshTokenReceived)
+	result, err := cfg.db.RevokeAccess(r.Context(), auth.HashRefreshToken(refreshTokenReceived))
 	if err != nil {
 		log.Print(fmt.Errorf("%v couldn't revoke refresh token access: %w", errorTag, err))
 		respondWithError(w, http.StatusInternalServerError, "database error: couldn't revoke refresh token")
@@ -413,22 +1066,17 @@ func (cfg *apiConfig) handlerRevokeAccess(w http.ResponseWriter, r *http.Request
 }
 
 func (cfg *apiConfig) handlerUpdateCredentials(w http.ResponseWriter, r *http.Request) {
-	jwt, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Print(fmt.Errorf("%v getting bearer token: %w", warningTag, err))
-		respondWithError(w, http.StatusUnauthorized, "invalid request")
-		return
-	}
-	userID, err := auth.ValidateJWT(jwt, cfg.signingSecret)
-	if err != nil {
-		log.Print(fmt.Errorf("%v validating JWT: %w", warningTag, err))
-		respondWithError(w, http.StatusUnauthorized, "unauthorized action")
+	accessToken, ok := cfg.authenticate(w, r, auth.ScopeAccountWrite)
+	if !ok {
 		return
 	}
+	userID := accessToken.UserID
 
 	type payload struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
+		Password           string `json:"password"`
+		Email              string `json:"email"`
+		Handle             string `json:"handle"`
+		KeepCurrentSession bool   `json:"keep_current_session"`
 	}
 	decoder := json.NewDecoder(r.Body)
 	var data payload
@@ -438,26 +1086,150 @@ func (cfg *apiConfig) handlerUpdateCredentials(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	hashedPassword, err := auth.HashPassword(data.Password)
+	email, ok := parseEmail(w, data.Email)
+	if !ok {
+		return
+	}
+	handle, ok := parseHandle(w, data.Handle)
+	if !ok {
+		return
+	}
+
+	currentUser, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting user from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve user")
+		return
+	}
+	// whoever knew the old password may still be logged in, so changing it
+	// logs out every session. Sessions logged in before they were tracked
+	// can't be told apart and can't be kept
+	_, err = cfg.passwords.Check(currentUser.HashedPassword, data.Password)
+	if err != nil && !errors.Is(err, auth.ErrPasswordMismatch) {
+		log.Print(fmt.Errorf("%v checking password of user %q: %w", errorTag, userID, err))
+		respondWithError(w, http.StatusInternalServerError, "server error: couldn't check password")
+		return
+	}
+	credentialsChanged := err != nil
+	keepSession := credentialsChanged && data.KeepCurrentSession && accessToken.SessionID != uuid.Nil
+
+	// the policy only applies to new passwords, so users whose password
+	// predates it can still change everything else
+	if credentialsChanged && !cfg.checkPassword(w, data.Password, email) {
+		return
+	}
+	hashedPassword, err := cfg.passwords.Hash(data.Password)
 	if err != nil {
 		log.Print(fmt.Errorf("%v couldn't hash password: %w", errorTag, err))
 		respondWithError(w, http.StatusInternalServerError, "server error: couldn't hash password")
 		return
 	}
 
-	user, err := cfg.db.UpdateCredentials(r.Context(), database.UpdateCredentialsParams{
-		Email:          data.Email,
-		HashedPassword: hashedPassword,
-		ID:             userID,
+	// a new email only replaces the current one once it's verified, while
+	// sending the current one cancels any pending change
+	emailChanged := email != currentUser.Email
+	if emailChanged {
+		_, err := cfg.db.GetUserByEmail(r.Context(), email)
+		if err == nil {
+			respondWithError(w, http.StatusConflict, "email already taken")
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Print(fmt.Errorf("%v getting user from the database: %w", errorTag, err))
+			respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve user")
+			return
+		}
+		// every change sends an email to an address of the user's choosing
+		if !cfg.canSendVerificationEmail(w, r, userID) {
+			return
+		}
+	}
+
+	var user database.User
+	var verificationToken string
+	err = cfg.withTx(r.Context(), func(qtx *database.Queries) error {
+		var err error
+		switch {
+		case emailChanged:
+			if err := qtx.SetPendingEmail(r.Context(), database.SetPendingEmailParams{
+				ID:           userID,
+				PendingEmail: sql.NullString{String: email, Valid: true},
+			}); err != nil {
+				return fmt.Errorf("setting pending email: %w", err)
+			}
+			verificationToken, err = startEmailVerification(r.Context(), qtx, userID, email)
+			if err != nil {
+				return err
+			}
+		case currentUser.PendingEmail.Valid:
+			if err := qtx.SetPendingEmail(r.Context(), database.SetPendingEmailParams{ID: userID}); err != nil {
+				return fmt.Errorf("cancelling pending email: %w", err)
+			}
+		}
+
+		user, err = qtx.UpdateCredentials(r.Context(), database.UpdateCredentialsParams{
+			HashedPassword: hashedPassword,
+			Handle:         handle,
+			RevokeTokens:   credentialsChanged,
+			ID:             userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case keepSession:
+			err = qtx.RevokeOtherSessions(r.Context(), database.RevokeOtherSessionsParams{
+				UserID:       userID,
+				KeepFamilyID: accessToken.SessionID,
+			})
+		case credentialsChanged:
+			err = qtx.RevokeAllSessions(r.Context(), userID)
+		}
+		if err != nil {
+			return fmt.Errorf("revoking sessions: %w", err)
+		}
+		return nil
 	})
+	if isUniqueViolation(err, "users_handle_idx") {
+		respondWithError(w, http.StatusConflict, "handle already taken")
+		return
+	}
 	if err != nil {
 		log.Print(fmt.Errorf("%v couldn't update credentials in the database: %w", errorTag, err))
 		respondWithError(w, http.StatusInternalServerError, "database error: couldn't revoke refresh token")
 		return
 	}
 
+	// the access token of the session being kept was revoked along with the
+	// rest, so it gets a new one
+	var newJWT string
+	if keepSession {
+		JWTDuration := 1 * time.Hour
+		newJWT, err = cfg.signingKeys.Load().MakeJWT(auth.AccessToken{
+			UserID:       user.ID,
+			SessionID:    accessToken.SessionID,
+			TokenVersion: user.TokenVersion,
+			Scopes:       accessToken.Scopes,
+			ClientID:     accessToken.ClientID,
+			Role:         accessToken.Role,
+		}, JWTDuration)
+		if err != nil {
+			log.Print(fmt.Errorf("%v couldn't sign token: %w", errorTag, err))
+			respondWithError(w, http.StatusInternalServerError, "couldn't create authentication string")
+			return
+		}
+	}
+
+	if emailChanged {
+		cfg.sendVerificationEmail(email, verificationToken)
+		log.Printf("%v user %q asked to change their email", successTag, user.ID)
+	}
+	if credentialsChanged {
+		log.Printf("%v credentials of user %q changed: sessions revoked", successTag, user.ID)
+	}
 	log.Printf("%v user %q created", successTag, user.Email)
-	respondWithJSON(w, http.StatusOK, addTagsToUser(user, "", ""))
+	respondWithJSON(w, http.StatusOK, addTagsToUser(user, newJWT, ""))
 }
 
 func (cfg *apiConfig) handlerDELETEChirpByID(w http.ResponseWriter, r *http.Request) {
@@ -480,24 +1252,21 @@ func (cfg *apiConfig) handlerDELETEChirpByID(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	jwt, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Print(fmt.Errorf("%v getting bearer token: %w", warningTag, err))
-		respondWithError(w, http.StatusBadRequest, "invalid request")
-		return
-	}
-	userID, err := auth.ValidateJWT(jwt, cfg.signingSecret)
-	if err != nil {
-		log.Print(fmt.Errorf("%v validating JWT: %w", warningTag, err))
-		respondWithError(w, http.StatusUnauthorized, "unauthorized action")
+	accessToken, ok := cfg.authenticate(w, r, auth.ScopeChirpsWrite)
+	if !ok {
 		return
 	}
 
-	if userID != chirp.UserID {
+	// moderators can delete the chirps of anyone
+	isModerator := accessToken.Role.Includes(auth.RoleModerator)
+	if accessToken.UserID != chirp.UserID && !isModerator {
 		log.Printf("%v user tried to delete chirp from another user", warningTag)
 		respondWithError(w, http.StatusForbidden, "unauthorized action")
 		return
 	}
+	if accessToken.UserID != chirp.UserID {
+		log.Printf("%v moderator %q deleted chirp %q of user %q", securityTag, accessToken.UserID, chirpID, chirp.UserID)
+	}
 
 	if err := cfg.db.DeleteChirpByID(r.Context(), chirpID); err != nil {
 		log.Print(fmt.Errorf("%v couldn't delete chirp from database: %w", errorTag, err))
@@ -571,6 +1340,14 @@ func main() {
 	defer db.Close()
 	dbQueries := database.New(db)
 
+	// the server binary also takes administration commands
+	if len(os.Args) > 1 {
+		if err := runCommand(context.Background(), dbQueries, os.Args[1:]); err != nil {
+			log.Fatal(fmt.Errorf("%v %v: %w", errorTag, os.Args[1], err))
+		}
+		return
+	}
+
 	// create an HTTP request multiplexer
 	mux := http.NewServeMux()
 
@@ -582,21 +1359,57 @@ func main() {
 	}
 
 	// declare and initialize server configuration
-	tokenSecret, ok := os.LookupEnv("JWTSECRET")
-	if !ok || tokenSecret == "" {
-		log.Fatal("suitable token secret to validate JWT not found")
+	signingKeys, err := loadSigningKeys()
+	if err != nil {
+		log.Fatal(fmt.Errorf("%v loading keys to sign JWT: %w", errorTag, err))
+	}
+	validator, err := loadValidator()
+	if err != nil {
+		log.Fatal(fmt.Errorf("%v loading access token validator: %w", errorTag, err))
 	}
 	polkaKey, ok := os.LookupEnv("POLKA_KEY")
 	if !ok || polkaKey == "" {
 		log.Fatal("suitable Polka key not found")
 	}
+	baseURL := strings.TrimSuffix(os.Getenv("BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("http://localhost:%v", port)
+	}
+	passwords, err := loadPasswords()
+	if err != nil {
+		log.Fatal(fmt.Errorf("%v loading password hasher: %w", errorTag, err))
+	}
+	passwordPolicy, err := loadPasswordPolicy()
+	if err != nil {
+		log.Fatal(fmt.Errorf("%v loading password policy: %w", errorTag, err))
+	}
+	lockoutStore, err := newLockoutStore(dbQueries, db)
+	if err != nil {
+		log.Fatal(fmt.Errorf("%v preparing the store of failed logins: %w", errorTag, err))
+	}
+	moderator, err := moderation.NewModerator(context.Background(), moderationLoader(dbQueries))
+	if err != nil {
+		log.Fatal(fmt.Errorf("%v loading moderated words: %w", errorTag, err))
+	}
+	go watchModeration(context.Background(), moderator)
+
 	apiCfg := apiConfig{
 		db:             dbQueries,
-		platform:       os.Getenv("PLATFORM"),
-		signingSecret:  tokenSecret,
+		conn:           db,
 		polkaKey:       polkaKey,
+		moderator:      moderator,
+		validator:      validator,
+		mailer:         newMailer(),
+		baseURL:        baseURL,
+		verifyToPost:   os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true",
+		passwords:      passwords,
+		passwordPolicy: passwordPolicy,
+		accountLimiter: lockout.NewLimiter(lockoutStore, accountLockoutPolicy),
+		addressLimiter: lockout.NewLimiter(lockoutStore, addressLockoutPolicy),
 		fileserverHits: atomic.Int32{},
 	}
+	apiCfg.signingKeys.Store(signingKeys)
+	go apiCfg.watchSigningKeys(context.Background())
 
 	// map server folders and routes for network access
 	app := http.FileServer(http.Dir("./app"))
@@ -608,15 +1421,58 @@ func main() {
 	mux.HandleFunc("GET    /api/chirps", apiCfg.handlerGETChirps)
 	mux.HandleFunc("POST   /api/chirps", apiCfg.handlerChirps)
 	mux.HandleFunc("GET    /api/chirps/{chirpID}", apiCfg.handlerGETChirpByID)
+	mux.HandleFunc("PUT    /api/chirps/{chirpID}", apiCfg.handlerEditChirp)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDELETEChirpByID)
+	mux.HandleFunc("GET    /api/chirps/{chirpID}/history", apiCfg.handlerGETChirpHistory)
+	mux.HandleFunc("GET    /api/chirps/{chirpID}/thread", apiCfg.handlerGETThread)
+	mux.HandleFunc("PUT    /api/chirps/{chirpID}/like", apiCfg.handlerLikeChirp)
+	mux.HandleFunc("POST   /api/chirps/{chirpID}/rechirps", apiCfg.handlerRechirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/like", apiCfg.handlerUnlikeChirp)
+	mux.HandleFunc("POST   /api/email-verification/confirm", apiCfg.handlerConfirmEmailVerification)
+	mux.HandleFunc("POST   /api/email-verification/request", apiCfg.handlerRequestEmailVerification)
 	mux.HandleFunc("POST   /api/login", apiCfg.handlerLogin)
+	mux.HandleFunc("POST   /api/login/mfa", apiCfg.handlerLoginMFA)
+	mux.HandleFunc("GET    /api/oauth/clients", apiCfg.handlerGETOAuthClients)
+	mux.HandleFunc("POST   /api/oauth/clients", apiCfg.handlerCreateOAuthClient)
+	mux.HandleFunc("DELETE /api/oauth/clients/{clientID}", apiCfg.handlerDeleteOAuthClient)
+	mux.HandleFunc("POST   /api/password-reset/confirm", apiCfg.handlerConfirmPasswordReset)
+	mux.HandleFunc("POST   /api/password-reset/request", apiCfg.handlerRequestPasswordReset)
 	mux.HandleFunc("POST   /api/polka/webhooks", apiCfg.handlerUpgradeUser)
 	mux.HandleFunc("POST   /api/refresh", apiCfg.handlerRefresh)
 	mux.HandleFunc("POST   /api/revoke", apiCfg.handlerRevokeAccess)
+	mux.HandleFunc("GET    /api/search/chirps", apiCfg.handlerSearchChirps)
+	mux.HandleFunc("GET    /api/sessions", apiCfg.handlerGETSessions)
+	mux.HandleFunc("DELETE /api/sessions", apiCfg.handlerRevokeAllSessions)
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", apiCfg.handlerRevokeSession)
+	mux.HandleFunc("GET    /api/tags/trending", apiCfg.handlerTrendingTags)
+	mux.HandleFunc("GET    /api/tags/{tag}/chirps", apiCfg.handlerGETTagChirps)
+	mux.HandleFunc("GET    /api/timeline", apiCfg.handlerTimeline)
 	mux.HandleFunc("POST   /api/users", apiCfg.handlerUser)
 	mux.HandleFunc("PUT    /api/users", apiCfg.handlerUpdateCredentials)
-	mux.HandleFunc("GET    /admin/metrics", apiCfg.handlerMetrics)
-	mux.HandleFunc("POST   /admin/reset", apiCfg.handlerReset)
+	mux.HandleFunc("GET    /api/users/me/mentions", apiCfg.handlerGETMyMentions)
+	mux.HandleFunc("GET    /api/users/me/tokens", apiCfg.handlerGETPersonalAccessTokens)
+	mux.HandleFunc("POST   /api/users/me/tokens", apiCfg.handlerCreatePersonalAccessToken)
+	mux.HandleFunc("DELETE /api/users/me/tokens/{tokenID}", apiCfg.handlerRevokePersonalAccessToken)
+	mux.HandleFunc("POST   /api/users/me/totp", apiCfg.handlerEnrollTOTP)
+	mux.HandleFunc("DELETE /api/users/me/totp", apiCfg.handlerDisableTOTP)
+	mux.HandleFunc("POST   /api/users/me/totp/confirm", apiCfg.handlerConfirmTOTP)
+	mux.HandleFunc("POST   /api/users/me/totp/recovery-codes", apiCfg.handlerRegenerateRecoveryCodes)
+	mux.HandleFunc("POST   /api/users/{userID}/follow", apiCfg.handlerFollowUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.handlerUnfollowUser)
+	mux.HandleFunc("GET    /api/users/{userID}/followers", apiCfg.handlerGETFollowers)
+	mux.HandleFunc("GET    /api/users/{userID}/following", apiCfg.handlerGETFollowing)
+	mux.HandleFunc("GET    /api/users/{userID}/likes", apiCfg.handlerGETUserLikes)
+	mux.HandleFunc("GET    /.well-known/jwks.json", apiCfg.handlerJWKS)
+	mux.HandleFunc("GET    /.well-known/oauth-authorization-server", apiCfg.handlerOAuthMetadata)
+	mux.Handle("GET    /admin/metrics", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.handlerMetrics))
+	mux.Handle("POST   /admin/reset", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.handlerReset))
+	mux.Handle("POST   /admin/unlock", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.handlerUnlockLogin))
+	mux.Handle("PUT    /admin/users/{userID}/role", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.handlerSetUserRole))
+	mux.HandleFunc("GET    /oauth/authorize", apiCfg.handlerAuthorize)
+	mux.HandleFunc("POST   /oauth/authorize", apiCfg.handlerAuthorizeDecision)
+	mux.HandleFunc("POST   /oauth/introspect", apiCfg.handlerOAuthIntrospect)
+	mux.HandleFunc("POST   /oauth/revoke", apiCfg.handlerOAuthRevoke)
+	mux.HandleFunc("POST   /oauth/token", apiCfg.handlerOAuthToken)
 
 	// start the server
 	log.Printf("server is listening for requests on port %v\n", port)
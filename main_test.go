@@ -0,0 +1,63 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/neira-daniel/go-chirpy/internal/database"
+)
+
+func TestRefreshTokenUsable(t *testing.T) {
+	now := time.Now().UTC()
+	rotatedAt := sql.NullTime{Time: now.Add(-time.Second), Valid: true}
+	tests := []struct {
+		name       string
+		token      database.RefreshToken
+		wantUsable bool
+		wantReused bool
+	}{
+		{
+			name:       "fresh",
+			token:      database.RefreshToken{ExpiresAt: now.Add(time.Hour)},
+			wantUsable: true,
+		},
+		{
+			name:  "expired",
+			token: database.RefreshToken{ExpiresAt: now.Add(-time.Hour)},
+		},
+		{
+			name: "revoked",
+			token: database.RefreshToken{
+				ExpiresAt: now.Add(time.Hour),
+				RevokedAt: sql.NullTime{Time: now, Valid: true},
+			},
+		},
+		{
+			name: "rotated moments ago",
+			token: database.RefreshToken{
+				ExpiresAt: now.Add(time.Hour),
+				RotatedAt: rotatedAt,
+				RevokedAt: rotatedAt,
+			},
+			wantReused: true,
+		},
+		{
+			name: "rotated and then revoked",
+			token: database.RefreshToken{
+				ExpiresAt: now.Add(time.Hour),
+				RotatedAt: rotatedAt,
+				RevokedAt: sql.NullTime{Time: now, Valid: true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			usable, reused := refreshTokenUsable(test.token)
+			if usable != test.wantUsable || reused != test.wantReused {
+				t.Errorf("got usable=%v reused=%v when expecting usable=%v reused=%v", usable, reused, test.wantUsable, test.wantReused)
+			}
+		})
+	}
+}
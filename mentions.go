@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/neira-daniel/go-chirpy/internal/auth"
+	"github.com/neira-daniel/go-chirpy/internal/chirptext"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+)
+
+// Mention is an @handle in the body of a chirp that was resolved to a user.
+// Start and End are offsets in Unicode code points, @ included.
+type Mention struct {
+	UserID uuid.UUID `json:"user_id"`
+	Handle string    `json:"handle"`
+	Start  int       `json:"start"`
+	End    int       `json:"end"`
+}
+
+func addTagsToMentions(body string, mentions []database.Mention) []Mention {
+	mentionsWithTags := make([]Mention, 0, len(mentions))
+	runes := []rune(body)
+	for _, mention := range mentions {
+		start, end := int(mention.StartOffset), int(mention.EndOffset)
+		// offsets that don't fit the body can't be rendered by clients either
+		if start < 0 || end > len(runes) || start+1 >= end {
+			continue
+		}
+		mentionsWithTags = append(mentionsWithTags, Mention{
+			UserID: mention.UserID,
+			Handle: string(runes[start+1 : end]),
+			Start:  start,
+			End:    end,
+		})
+	}
+	return mentionsWithTags
+}
+
+// parseHandle validates the handle requested by a user. An empty handle is
+// valid and means that no handle was requested. When the handle can't be
+// accepted, it responds to the client itself and returns false.
+func parseHandle(w http.ResponseWriter, handle string) (sql.NullString, bool) {
+	if handle == "" {
+		return sql.NullString{}, true
+	}
+	if !chirptext.ValidHandle(handle) {
+		problem := fmt.Sprintf("must be 1 to %d letters, digits or underscores", chirptext.MaxHandleLength)
+		respondWithFieldErrors(w, http.StatusBadRequest, "request error: handle "+problem, map[string][]string{
+			"handle": {problem},
+		})
+		return sql.NullString{}, false
+	}
+	return sql.NullString{String: handle, Valid: true}, true
+}
+
+// saveMentions stores the @handles of a chirp that belong to registered users.
+// Handles nobody owns are left as plain text.
+func saveMentions(ctx context.Context, qtx *database.Queries, chirp database.Chirp) error {
+	mentions := chirptext.Mentions(chirp.Body)
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	handles := make([]string, len(mentions))
+	for i, mention := range mentions {
+		handles[i] = chirptext.NormalizeHandle(mention.Handle)
+	}
+	users, err := qtx.GetUsersByHandles(ctx, handles)
+	if err != nil {
+		return fmt.Errorf("resolving handles: %w", err)
+	}
+	userIDs := make(map[string]uuid.UUID, len(users))
+	for _, user := range users {
+		userIDs[chirptext.NormalizeHandle(user.Handle.String)] = user.ID
+	}
+
+	for _, mention := range mentions {
+		userID, ok := userIDs[chirptext.NormalizeHandle(mention.Handle)]
+		if !ok {
+			continue
+		}
+		if err := qtx.SaveMention(ctx, database.SaveMentionParams{
+			ChirpID:     chirp.ID,
+			UserID:      userID,
+			StartOffset: int32(mention.Start),
+			EndOffset:   int32(mention.End),
+		}); err != nil {
+			return fmt.Errorf("saving mention of %q: %w", mention.Handle, err)
+		}
+	}
+
+	return nil
+}
+
+func (cfg *apiConfig) handlerGETMyMentions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r, auth.ScopeChirpsRead)
+	if !ok {
+		return
+	}
+	page, err := parsePageRequest(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("request error: %v", err))
+		return
+	}
+
+	// mentions are listed newest first, so going back means reading upwards
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	var chirps []database.Chirp
+	if page.backward() {
+		chirps, err = cfg.db.ListMentioningChirpsAscending(r.Context(), database.ListMentioningChirpsAscendingParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageSize:        page.fetchSize(),
+		})
+	} else {
+		chirps, err = cfg.db.ListMentioningChirpsDescending(r.Context(), database.ListMentioningChirpsDescendingParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageSize:        page.fetchSize(),
+		})
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting mentions from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve mentions")
+		return
+	}
+
+	chirps, next, prev := paginate(chirps, page, chirpCursor)
+	chirpsWithTags, err := cfg.presentChirps(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, chirps)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting chirp details from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve mentions")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, ChirpsPage{
+		Chirps:     chirpsWithTags,
+		Pagination: setPaginationLinks(w, r, page, next, prev),
+	})
+}
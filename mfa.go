@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/neira-daniel/go-chirpy/internal/auth"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+)
+
+const (
+	// totpIssuer is the name authenticator apps show next to the codes
+	totpIssuer = "Chirpy"
+	// recoveryCodeCount is how many recovery codes users get at once
+	recoveryCodeCount = 10
+	// mfaChallengeDuration is how long users have to send their one-time
+	// password after their password was checked
+	mfaChallengeDuration = 5 * time.Minute
+	// maxMFAAttempts is how many codes can be tried for a single login
+	maxMFAAttempts = 5
+)
+
+// MFAChallenge is what logging in returns instead of the tokens when the user
+// has two-factor authentication enabled.
+type MFAChallenge struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// TOTPEnrollment is what an authenticator app needs to generate the one-time
+// passwords of a user.
+type TOTPEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// RecoveryCodes are shown to users only once, as we only store their hashes.
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// secondFactor is the payload of the requests that must prove the user has
+// their authenticator app or a recovery code.
+type secondFactor struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// checkSecondFactor checks a one-time password or, when it's not given, a
+// recovery code. Either is consumed when it's right, so it can't be used
+// again.
+func (cfg *apiConfig) checkSecondFactor(ctx context.Context, user database.User, factor secondFactor) (bool, error) {
+	if !user.TotpEnabledAt.Valid {
+		return false, nil
+	}
+	if factor.Code != "" {
+		step, err := auth.ValidateTOTP(user.TotpSecret.String, factor.Code, time.Now(), user.TotpLastStep)
+		if errors.Is(err, auth.ErrInvalidTOTPCode) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		// this fails when a concurrent request used the same code first
+		rowsAffected, err := cfg.db.UseTOTPStep(ctx, database.UseTOTPStepParams{ID: user.ID, TotpLastStep: step})
+		if err != nil {
+			return false, err
+		}
+		return rowsAffected == 1, nil
+	}
+	if factor.RecoveryCode != "" {
+		rowsAffected, err := cfg.db.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:   user.ID,
+			CodeHash: auth.HashRecoveryCode(factor.RecoveryCode),
+		})
+		if err != nil {
+			return false, err
+		}
+		if rowsAffected == 1 {
+			log.Printf("%v user %q used a recovery code", securityTag, user.ID)
+		}
+		return rowsAffected == 1, nil
+	}
+	return false, nil
+}
+
+// replaceRecoveryCodes invalidates the recovery codes of a user and returns
+// new ones.
+func replaceRecoveryCodes(ctx context.Context, qtx *database.Queries, user database.User) ([]string, error) {
+	if err := qtx.DeleteRecoveryCodes(ctx, user.ID); err != nil {
+		return nil, fmt.Errorf("deleting recovery codes: %w", err)
+	}
+	codes := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	for _, code := range codes {
+		if err := qtx.SaveRecoveryCode(ctx, database.SaveRecoveryCodeParams{
+			UserID:   user.ID,
+			CodeHash: auth.HashRecoveryCode(code),
+		}); err != nil {
+			return nil, fmt.Errorf("saving recovery code: %w", err)
+		}
+	}
+	return codes, nil
+}
+
+// startMFAChallenge responds to a login whose password was right with a token
+// to send along with the one-time password to POST /api/login/mfa.
+func (cfg *apiConfig) startMFAChallenge(w http.ResponseWriter, r *http.Request, user database.User, deviceName sql.NullString) {
+	if err := cfg.db.DeleteExpiredMFAChallenges(r.Context()); err != nil {
+		log.Print(fmt.Errorf("%v deleting expired MFA challenges: %w", warningTag, err))
+	}
+
+	token, _ := auth.MakeRefreshToken()
+	expiresAt := time.Now().UTC().Add(mfaChallengeDuration)
+	if err := cfg.db.CreateMFAChallenge(r.Context(), database.CreateMFAChallengeParams{
+		TokenHash:  auth.HashRefreshToken(token),
+		UserID:     user.ID,
+		DeviceName: deviceName,
+		ExpiresAt:  expiresAt,
+	}); err != nil {
+		log.Print(fmt.Errorf("%v couldn't store MFA challenge: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't store MFA challenge")
+		return
+	}
+
+	log.Printf("%v user %q must send a one-time password to log in", okTag, user.Email)
+	respondWithJSON(w, http.StatusOK, MFAChallenge{MFARequired: true, MFAToken: token, ExpiresAt: expiresAt})
+}
+
+func (cfg *apiConfig) handlerLoginMFA(w http.ResponseWriter, r *http.Request) {
+	type payload struct {
+		MFAToken string `json:"mfa_token"`
+		secondFactor
+	}
+	decoder := json.NewDecoder(r.Body)
+	var data payload
+	if err := decoder.Decode(&data); err != nil {
+		log.Print(fmt.Errorf("%v decoding non-conforming JSON request: %w", errorTag, err))
+		respondWithError(w, http.StatusBadRequest, "non-conforming JSON received")
+		return
+	}
+	if data.MFAToken == "" || (data.Code == "") == (data.RecoveryCode == "") {
+		respondWithError(w, http.StatusBadRequest, "request error: send the MFA token and either a one-time password or a recovery code")
+		return
+	}
+
+	challenge, err := cfg.db.AttemptMFAChallenge(r.Context(), database.AttemptMFAChallengeParams{
+		TokenHash:   auth.HashRefreshToken(data.MFAToken),
+		MaxAttempts: maxMFAAttempts,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("%v got invalid, expired or exhausted MFA token", warningTag)
+		respondWithError(w, http.StatusUnauthorized, "invalid MFA token")
+		return
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting MFA challenge from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve MFA challenge")
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), challenge.UserID)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting user from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve user")
+		return
+	}
+	ok, err := cfg.checkSecondFactor(r.Context(), user, data.secondFactor)
+	if err != nil {
+		log.Print(fmt.Errorf("%v checking second factor: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "server error: couldn't check one-time password")
+		return
+	}
+	if !ok {
+		log.Printf("%v wrong one-time password for %q", warningTag, user.Email)
+		failLogin(w, cfg.recordFailedLogin(r, user.Email), "wrong one-time password or recovery code")
+		return
+	}
+	cfg.succeedLogin(r, user.Email)
+
+	if err := cfg.db.DeleteMFAChallenge(r.Context(), challenge.TokenHash); err != nil {
+		log.Print(fmt.Errorf("%v deleting MFA challenge: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't delete MFA challenge")
+		return
+	}
+	cfg.startSession(w, r, user, challenge.DeviceName)
+}
+
+func (cfg *apiConfig) handlerEnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r, loginOnly)
+	if !ok {
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting user from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve user")
+		return
+	}
+
+	// enrolling again before confirming replaces the secret
+	secret := auth.GenerateTOTPSecret()
+	rowsAffected, err := cfg.db.SetTOTPSecret(r.Context(), database.SetTOTPSecretParams{
+		ID:         userID,
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+	})
+	if err != nil {
+		log.Print(fmt.Errorf("%v storing TOTP secret: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't store TOTP secret")
+		return
+	}
+	if rowsAffected == 0 {
+		respondWithError(w, http.StatusConflict, "two-factor authentication is already enabled")
+		return
+	}
+
+	log.Printf("%v user %q started enrolling in two-factor authentication", successTag, userID)
+	respondWithJSON(w, http.StatusOK, TOTPEnrollment{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+func (cfg *apiConfig) handlerConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r, loginOnly)
+	if !ok {
+		return
+	}
+
+	type payload struct {
+		Code string `json:"code"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	var data payload
+	if err := decoder.Decode(&data); err != nil {
+		log.Print(fmt.Errorf("%v decoding non-conforming JSON request: %w", errorTag, err))
+		respondWithError(w, http.StatusBadRequest, "non-conforming JSON received")
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting user from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve user")
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "two-factor authentication is already enabled")
+		return
+	}
+	if !user.TotpSecret.Valid {
+		respondWithError(w, http.StatusConflict, "two-factor authentication enrollment wasn't started")
+		return
+	}
+
+	step, err := auth.ValidateTOTP(user.TotpSecret.String, data.Code, time.Now(), 0)
+	if err != nil {
+		log.Print(fmt.Errorf("%v confirming two-factor authentication: %w", warningTag, err))
+		respondWithError(w, http.StatusBadRequest, "request error: wrong one-time password")
+		return
+	}
+
+	var codes []string
+	var enabled bool
+	err = cfg.withTx(r.Context(), func(qtx *database.Queries) error {
+		rowsAffected, err := qtx.EnableTOTP(r.Context(), database.EnableTOTPParams{ID: userID, TotpLastStep: step})
+		if err != nil {
+			return fmt.Errorf("enabling TOTP: %w", err)
+		}
+		// a concurrent request enabled it or restarted the enrollment
+		if rowsAffected == 0 {
+			return nil
+		}
+		enabled = true
+		codes, err = replaceRecoveryCodes(r.Context(), qtx, user)
+		return err
+	})
+	if err != nil {
+		log.Print(fmt.Errorf("%v enabling two-factor authentication: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't enable two-factor authentication")
+		return
+	}
+	if !enabled {
+		respondWithError(w, http.StatusConflict, "two-factor authentication enrollment changed, try again")
+		return
+	}
+
+	log.Printf("%v user %q enabled two-factor authentication", securityTag, userID)
+	respondWithJSON(w, http.StatusOK, RecoveryCodes{RecoveryCodes: codes})
+}
+
+// userWithSecondFactor returns the authenticated user once they prove they
+// have their second factor. When that's not possible, it responds to the
+// client itself and returns false.
+func (cfg *apiConfig) userWithSecondFactor(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	userID, ok := cfg.authenticateUser(w, r, loginOnly)
+	if !ok {
+		return database.User{}, false
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	var data secondFactor
+	if err := decoder.Decode(&data); err != nil {
+		log.Print(fmt.Errorf("%v decoding non-conforming JSON request: %w", errorTag, err))
+		respondWithError(w, http.StatusBadRequest, "non-conforming JSON received")
+		return database.User{}, false
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting user from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve user")
+		return database.User{}, false
+	}
+	if !user.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "two-factor authentication isn't enabled")
+		return database.User{}, false
+	}
+
+	ok, err = cfg.checkSecondFactor(r.Context(), user, data)
+	if err != nil {
+		log.Print(fmt.Errorf("%v checking second factor: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "server error: couldn't check one-time password")
+		return database.User{}, false
+	}
+	if !ok {
+		log.Printf("%v wrong one-time password for %q", warningTag, user.Email)
+		respondWithError(w, http.StatusForbidden, "wrong one-time password or recovery code")
+		return database.User{}, false
+	}
+	return user, true
+}
+
+func (cfg *apiConfig) handlerDisableTOTP(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.userWithSecondFactor(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.withTx(r.Context(), func(qtx *database.Queries) error {
+		if err := qtx.DisableTOTP(r.Context(), user.ID); err != nil {
+			return fmt.Errorf("disabling TOTP: %w", err)
+		}
+		return qtx.DeleteRecoveryCodes(r.Context(), user.ID)
+	})
+	if err != nil {
+		log.Print(fmt.Errorf("%v disabling two-factor authentication: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't disable two-factor authentication")
+		return
+	}
+
+	log.Printf("%v user %q disabled two-factor authentication", securityTag, user.ID)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.userWithSecondFactor(w, r)
+	if !ok {
+		return
+	}
+
+	var codes []string
+	err := cfg.withTx(r.Context(), func(qtx *database.Queries) error {
+		var err error
+		codes, err = replaceRecoveryCodes(r.Context(), qtx, user)
+		return err
+	})
+	if err != nil {
+		log.Print(fmt.Errorf("%v replacing recovery codes: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't replace recovery codes")
+		return
+	}
+
+	log.Printf("%v user %q replaced their recovery codes", securityTag, user.ID)
+	respondWithJSON(w, http.StatusOK, RecoveryCodes{RecoveryCodes: codes})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+	"github.com/neira-daniel/go-chirpy/internal/moderation"
+)
+
+// moderationReloadInterval is how often the list of moderated words is loaded
+// again. Sending SIGHUP to the server reloads it right away.
+const moderationReloadInterval = time.Minute
+
+// moderationLoader returns where the list of moderated words comes from: the
+// file given in MODERATION_WORDS_FILE or, by default, the database.
+func moderationLoader(db *database.Queries) moderation.Loader {
+	if path := os.Getenv("MODERATION_WORDS_FILE"); path != "" {
+		return moderation.FileLoader{Path: path}
+	}
+	return moderation.LoaderFunc(func(ctx context.Context) ([]moderation.Rule, error) {
+		words, err := db.ListModerationWords(ctx)
+		if err != nil {
+			return nil, err
+		}
+		rules := make([]moderation.Rule, len(words))
+		for i, word := range words {
+			rules[i] = moderation.Rule{Word: word.Word, Action: moderation.Action(word.Action)}
+		}
+		return rules, nil
+	})
+}
+
+// watchModeration reloads the list of moderated words periodically and on
+// SIGHUP until ctx is done. Failed reloads are logged and the previous list
+// stays in use.
+func watchModeration(ctx context.Context, moderator *moderation.Moderator) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	ticker := time.NewTicker(moderationReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-hangups:
+			log.Print("reloading moderated words")
+		}
+		if err := moderator.Reload(ctx); err != nil {
+			log.Print(fmt.Errorf("%v reloading moderated words: %w", errorTag, err))
+		}
+	}
+}
+
+// moderateChirp checks the body of a chirp against the moderated words. When
+// the chirp can't be posted, it responds to the client itself and returns
+// false.
+func (cfg *apiConfig) moderateChirp(w http.ResponseWriter, body string) (moderation.Result, bool) {
+	result := cfg.moderator.Check(body)
+	if result.Rejected() {
+		log.Printf("%v chirp rejected for containing %q", warningTag, result.Words(moderation.ActionReject))
+		respondWithError(w, http.StatusBadRequest, "chirp contains words that aren't allowed")
+		return moderation.Result{}, false
+	}
+	return result, true
+}
+
+// flagChirp marks a chirp for review because of the flagged words it contains.
+func flagChirp(ctx context.Context, qtx *database.Queries, chirpID uuid.UUID, words []string) error {
+	for _, word := range words {
+		if err := qtx.FlagChirp(ctx, database.FlagChirpParams{
+			ChirpID: chirpID,
+			Word:    word,
+		}); err != nil {
+			return fmt.Errorf("flagging chirp for %q: %w", word, err)
+		}
+	}
+	return nil
+}
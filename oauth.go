@@ -0,0 +1,956 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/neira-daniel/go-chirpy/internal/auth"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+)
+
+const (
+	// maxClientNameLength is the longest name, in characters, an OAuth client
+	// can be registered with
+	maxClientNameLength = 100
+	// maxRedirectURIs is how many redirect URIs an OAuth client can register
+	maxRedirectURIs = 10
+	// authorizationCodeDuration is how long clients have to exchange an
+	// authorization code for tokens
+	authorizationCodeDuration = 10 * time.Minute
+	// oauthAccessTokenDuration and oauthRefreshTokenDays are how long the
+	// tokens issued to OAuth clients last, the same as those of a login
+	oauthAccessTokenDuration       = time.Hour
+	oauthRefreshTokenDays    int32 = 60
+)
+
+// scopeDescriptions tell users what they grant to an OAuth client on the
+// consent screen.
+var scopeDescriptions = map[auth.Scope]string{
+	auth.ScopeChirpsRead:   "Read your timeline and your mentions",
+	auth.ScopeChirpsWrite:  "Post, edit, delete, like and rechirp chirps and follow users as you",
+	auth.ScopeAccountWrite: "Change your email, password and handle",
+}
+
+// OAuthClient is a third-party app users can grant access to their account
+// without giving it their password. The secret of confidential clients is
+// only shown once, when they're registered.
+type OAuthClient struct {
+	ID           uuid.UUID `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Confidential bool      `json:"confidential"`
+	Secret       string    `json:"client_secret,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func newOAuthClient(row database.OauthClient) OAuthClient {
+	return OAuthClient{
+		ID:           row.ID,
+		Name:         row.Name,
+		RedirectURIs: row.RedirectUris,
+		Confidential: row.SecretHash.Valid,
+		CreatedAt:    row.CreatedAt,
+	}
+}
+
+// OAuthTokens is what the token endpoint issues, as RFC 6749 describes it.
+type OAuthTokens struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// TokenIntrospection is what the introspection endpoint tells about a token,
+// as RFC 7662 describes it. Inactive tokens only have Active set.
+type TokenIntrospection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	Audience  string `json:"aud,omitempty"`
+}
+
+// respondWithOAuthError rejects a request to the token, introspection or
+// revocation endpoints with an error code of RFC 6749, like invalid_grant.
+func respondWithOAuthError(w http.ResponseWriter, statusCode int, code, description string) {
+	type errorResponse struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, statusCode, errorResponse{Error: code, ErrorDescription: description})
+}
+
+func (cfg *apiConfig) handlerCreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r, loginOnly)
+	if !ok {
+		return
+	}
+
+	type payload struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Confidential bool     `json:"confidential"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	var data payload
+	if err := decoder.Decode(&data); err != nil {
+		log.Print(fmt.Errorf("%v decoding non-conforming JSON request: %w", errorTag, err))
+		respondWithError(w, http.StatusBadRequest, "non-conforming JSON received")
+		return
+	}
+
+	fields := map[string][]string{}
+	if data.Name == "" || utf8.RuneCountInString(data.Name) > maxClientNameLength {
+		fields["name"] = []string{fmt.Sprintf("must be 1 to %d characters long", maxClientNameLength)}
+	}
+	if len(data.RedirectURIs) == 0 || len(data.RedirectURIs) > maxRedirectURIs {
+		fields["redirect_uris"] = []string{fmt.Sprintf("must have 1 to %d URIs", maxRedirectURIs)}
+	}
+	for _, uri := range data.RedirectURIs {
+		if err := auth.ValidateRedirectURI(uri); err != nil {
+			fields["redirect_uris"] = append(fields["redirect_uris"], fmt.Sprintf("%q %v", uri, err))
+		}
+	}
+	if len(fields) > 0 {
+		respondWithFieldErrors(w, http.StatusBadRequest, "request error: invalid OAuth client", fields)
+		return
+	}
+
+	// public clients, like native and browser apps, can't keep a secret
+	var secret string
+	var secretHash sql.NullString
+	if data.Confidential {
+		secret, _ = auth.MakeRefreshToken()
+		secretHash = sql.NullString{String: auth.HashRefreshToken(secret), Valid: true}
+	}
+	row, err := cfg.db.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		UserID:       userID,
+		Name:         data.Name,
+		RedirectUris: data.RedirectURIs,
+		SecretHash:   secretHash,
+	})
+	if err != nil {
+		log.Print(fmt.Errorf("%v storing OAuth client: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't store OAuth client")
+		return
+	}
+
+	log.Printf("%v user %q registered OAuth client %q", successTag, userID, row.ID)
+	client := newOAuthClient(row)
+	client.Secret = secret
+	respondWithJSON(w, http.StatusCreated, client)
+}
+
+func (cfg *apiConfig) handlerGETOAuthClients(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r, loginOnly)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.db.ListOAuthClients(r.Context(), userID)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting OAuth clients from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve OAuth clients")
+		return
+	}
+
+	clients := make([]OAuthClient, len(rows))
+	for i, row := range rows {
+		clients[i] = newOAuthClient(row)
+	}
+	respondWithJSON(w, http.StatusOK, clients)
+}
+
+func (cfg *apiConfig) handlerDeleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r, loginOnly)
+	if !ok {
+		return
+	}
+	clientID, err := uuid.Parse(r.PathValue("clientID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "request error: not a valid client UUID")
+		return
+	}
+
+	// deleting the client deletes the refresh tokens issued to it, which
+	// revokes its access tokens too. Clients of other users are reported as
+	// missing, not as forbidden, so as not to reveal that they exist
+	rowsAffected, err := cfg.db.DeleteOAuthClient(r.Context(), database.DeleteOAuthClientParams{
+		ID:     clientID,
+		UserID: userID,
+	})
+	if err != nil {
+		log.Print(fmt.Errorf("%v deleting OAuth client from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't delete OAuth client")
+		return
+	}
+	if rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "OAuth client doesn't exist")
+		return
+	}
+
+	log.Printf("%v user %q deleted OAuth client %q", successTag, userID, clientID)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorizationRequest is what a client asks for when it sends a user to the
+// authorization endpoint.
+type authorizationRequest struct {
+	Client        database.OauthClient
+	RedirectURI   string
+	Scopes        []auth.Scope
+	State         string
+	CodeChallenge string
+}
+
+// parseAuthorizationRequest checks the parameters a client sent the user to
+// the authorization endpoint with. Until the client and its redirect URI are
+// known to be right, errors are shown to the user, as redirecting to an
+// unchecked URI would make us an open redirector. After that, they're sent
+// back to the client. Either way, it responds itself and returns false.
+func (cfg *apiConfig) parseAuthorizationRequest(w http.ResponseWriter, r *http.Request) (authorizationRequest, bool) {
+	if err := r.ParseForm(); err != nil {
+		renderOAuthErrorPage(w, http.StatusBadRequest, "The request couldn't be read.")
+		return authorizationRequest{}, false
+	}
+
+	clientID, err := uuid.Parse(r.Form.Get("client_id"))
+	if err != nil {
+		renderOAuthErrorPage(w, http.StatusBadRequest, "The app didn't say who it is.")
+		return authorizationRequest{}, false
+	}
+	client, err := cfg.db.GetOAuthClient(r.Context(), clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		renderOAuthErrorPage(w, http.StatusBadRequest, "The app isn't registered with Chirpy.")
+		return authorizationRequest{}, false
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting OAuth client from the database: %w", errorTag, err))
+		renderOAuthErrorPage(w, http.StatusInternalServerError, "Something went wrong on our side. Please try again later.")
+		return authorizationRequest{}, false
+	}
+	request := authorizationRequest{
+		Client:      client,
+		RedirectURI: r.Form.Get("redirect_uri"),
+		State:       r.Form.Get("state"),
+	}
+	if !auth.MatchRedirectURI(client.RedirectUris, request.RedirectURI) {
+		log.Printf("%v OAuth client %q asked to redirect to unregistered URI %q", securityTag, client.ID, request.RedirectURI)
+		renderOAuthErrorPage(w, http.StatusBadRequest, "The app asked to send you somewhere it didn't register.")
+		return authorizationRequest{}, false
+	}
+
+	if r.Form.Get("response_type") != "code" {
+		cfg.redirectWithOAuthError(w, r, request, "unsupported_response_type", "only the code response type is supported")
+		return authorizationRequest{}, false
+	}
+	request.CodeChallenge = r.Form.Get("code_challenge")
+	if r.Form.Get("code_challenge_method") != "S256" || !auth.IsCodeChallenge(request.CodeChallenge) {
+		cfg.redirectWithOAuthError(w, r, request, "invalid_request", "a code_challenge made with the S256 method is required")
+		return authorizationRequest{}, false
+	}
+	request.Scopes, err = auth.ParseScopeString(r.Form.Get("scope"))
+	if err != nil {
+		cfg.redirectWithOAuthError(w, r, request, "invalid_scope", err.Error())
+		return authorizationRequest{}, false
+	}
+	return request, true
+}
+
+// redirectToClient sends the user back to the client with the given
+// parameters, along with the state the client sent and our issuer identifier,
+// which RFC 9207 adds to stop mix-up attacks.
+func (cfg *apiConfig) redirectToClient(w http.ResponseWriter, r *http.Request, request authorizationRequest, params url.Values) {
+	// the redirect URI was parsed when it was registered
+	target, _ := url.Parse(request.RedirectURI)
+	query := target.Query()
+	for name, values := range params {
+		query[name] = values
+	}
+	if request.State != "" {
+		query.Set("state", request.State)
+	}
+	query.Set("iss", cfg.baseURL)
+	target.RawQuery = query.Encode()
+	http.Redirect(w, r, target.String(), http.StatusSeeOther)
+}
+
+func (cfg *apiConfig) redirectWithOAuthError(w http.ResponseWriter, r *http.Request, request authorizationRequest, code, description string) {
+	cfg.redirectToClient(w, r, request, url.Values{"error": {code}, "error_description": {description}})
+}
+
+func (cfg *apiConfig) handlerAuthorize(w http.ResponseWriter, r *http.Request) {
+	request, ok := cfg.parseAuthorizationRequest(w, r)
+	if !ok {
+		return
+	}
+	renderConsentPage(w, http.StatusOK, request, "", "")
+}
+
+func (cfg *apiConfig) handlerAuthorizeDecision(w http.ResponseWriter, r *http.Request) {
+	request, ok := cfg.parseAuthorizationRequest(w, r)
+	if !ok {
+		return
+	}
+	if r.PostForm.Get("decision") != "allow" {
+		log.Printf("%v user denied OAuth client %q", okTag, request.Client.ID)
+		cfg.redirectWithOAuthError(w, r, request, "access_denied", "the user denied access")
+		return
+	}
+
+	// users log in on the consent screen itself, so that the client never
+	// sees their password
+	email := r.PostForm.Get("email")
+	attempt, allowed, err := cfg.reserveLogin(r, email)
+	if err != nil {
+		log.Print(fmt.Errorf("%v checking failed logins: %w", errorTag, err))
+		renderConsentPage(w, http.StatusInternalServerError, request, email, "Something went wrong on our side. Please try again later.")
+		return
+	}
+	if !allowed {
+		setRetryAfter(w, attempt.wait)
+		message := fmt.Sprintf("Too many failed logins. Try again in %d seconds.", int(math.Ceil(attempt.wait.Seconds())))
+		if attempt.locked {
+			message = "Too many failed logins. Your account is temporarily locked."
+		}
+		renderConsentPage(w, http.StatusTooManyRequests, request, email, message)
+		return
+	}
+	user, ok := cfg.checkConsentLogin(w, r, request, attempt)
+	if !ok {
+		return
+	}
+	cfg.succeedLogin(r, user.Email)
+
+	if err := cfg.db.DeleteExpiredAuthorizationCodes(r.Context()); err != nil {
+		log.Print(fmt.Errorf("%v deleting expired authorization codes: %w", warningTag, err))
+	}
+	code, _ := auth.MakeRefreshToken()
+	if err := cfg.db.CreateAuthorizationCode(r.Context(), database.CreateAuthorizationCodeParams{
+		CodeHash:      auth.HashRefreshToken(code),
+		ClientID:      request.Client.ID,
+		UserID:        user.ID,
+		FamilyID:      uuid.New(),
+		RedirectUri:   request.RedirectURI,
+		Scopes:        scopeNames(request.Scopes),
+		CodeChallenge: request.CodeChallenge,
+		ExpiresAt:     time.Now().UTC().Add(authorizationCodeDuration),
+	}); err != nil {
+		log.Print(fmt.Errorf("%v storing authorization code: %w", errorTag, err))
+		cfg.redirectWithOAuthError(w, r, request, "server_error", "couldn't store authorization code")
+		return
+	}
+
+	log.Printf("%v user %q authorized OAuth client %q with scopes %v", securityTag, user.ID, request.Client.ID, request.Scopes)
+	cfg.redirectToClient(w, r, request, url.Values{"code": {code}})
+}
+
+// checkConsentLogin checks the credentials, and the one-time password when
+// two-factor authentication is enabled, that a user sent from the consent
+// screen. When they're wrong, it shows the consent screen again with the
+// reason and returns false.
+func (cfg *apiConfig) checkConsentLogin(w http.ResponseWriter, r *http.Request, request authorizationRequest, attempt loginAttempt) (database.User, bool) {
+	email := attempt.email
+	fail := func(message string) {
+		setLoginRetry(w, attempt)
+		renderConsentPage(w, http.StatusUnauthorized, request, email, message)
+	}
+
+	// unknown emails and wrong passwords get the same response, so as not to
+	// reveal which accounts exist
+	user, err := cfg.db.GetUserByEmail(r.Context(), email)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("%v login to unknown account %q", warningTag, email)
+		fail("Wrong email or password.")
+		return database.User{}, false
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting user from the database: %w", errorTag, err))
+		renderConsentPage(w, http.StatusInternalServerError, request, email, "Something went wrong on our side. Please try again later.")
+		return database.User{}, false
+	}
+	password := r.PostForm.Get("password")
+	rehash, err := cfg.passwords.Check(user.HashedPassword, password)
+	if errors.Is(err, auth.ErrPasswordMismatch) {
+		log.Printf("%v wrong password for %q", warningTag, email)
+		fail("Wrong email or password.")
+		return database.User{}, false
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v checking password of %q: %w", errorTag, email, err))
+		renderConsentPage(w, http.StatusInternalServerError, request, email, "Something went wrong on our side. Please try again later.")
+		return database.User{}, false
+	}
+	if rehash {
+		cfg.rehashPassword(r.Context(), user, password)
+	}
+
+	if !user.TotpEnabledAt.Valid {
+		return user, true
+	}
+	code := strings.TrimSpace(r.PostForm.Get("code"))
+	if code == "" {
+		// the password was right, so asking for the code isn't a failure
+		cfg.forgiveLogin(r, email)
+		renderConsentPage(w, http.StatusUnauthorized, request, email, "Enter the one-time password from your authenticator app, or a recovery code.")
+		return database.User{}, false
+	}
+	// one-time passwords are all digits, unlike recovery codes
+	factor := secondFactor{RecoveryCode: code}
+	if strings.Trim(code, "0123456789") == "" {
+		factor = secondFactor{Code: code}
+	}
+	ok, err := cfg.checkSecondFactor(r.Context(), user, factor)
+	if err != nil {
+		log.Print(fmt.Errorf("%v checking second factor: %w", errorTag, err))
+		renderConsentPage(w, http.StatusInternalServerError, request, email, "Something went wrong on our side. Please try again later.")
+		return database.User{}, false
+	}
+	if !ok {
+		log.Printf("%v wrong one-time password for %q", warningTag, email)
+		fail("Wrong one-time password or recovery code.")
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// authenticateClient checks the credentials of the client calling the token,
+// introspection or revocation endpoints. Confidential clients send their
+// secret with HTTP Basic authentication or in the form, while public clients
+// only send their ID in the form. When the client can't be authenticated, it
+// responds to it itself and returns false.
+func (cfg *apiConfig) authenticateClient(w http.ResponseWriter, r *http.Request) (database.OauthClient, bool) {
+	if err := r.ParseForm(); err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "couldn't parse the form")
+		return database.OauthClient{}, false
+	}
+
+	clientIDString, secret, basic := r.BasicAuth()
+	if basic {
+		if r.PostForm.Has("client_id") || r.PostForm.Has("client_secret") {
+			respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "send the client credentials only once")
+			return database.OauthClient{}, false
+		}
+		// RFC 6749 has the credentials form-encoded before they're encoded
+		// for Basic authentication
+		var errID, errSecret error
+		clientIDString, errID = url.QueryUnescape(clientIDString)
+		secret, errSecret = url.QueryUnescape(secret)
+		if errID != nil || errSecret != nil {
+			respondInvalidClient(w)
+			return database.OauthClient{}, false
+		}
+	} else {
+		clientIDString = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+
+	clientID, err := uuid.Parse(clientIDString)
+	if err != nil {
+		respondInvalidClient(w)
+		return database.OauthClient{}, false
+	}
+	client, err := cfg.db.GetOAuthClient(r.Context(), clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondInvalidClient(w)
+		return database.OauthClient{}, false
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting OAuth client from the database: %w", errorTag, err))
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "couldn't retrieve client")
+		return database.OauthClient{}, false
+	}
+
+	if client.SecretHash.Valid {
+		hash := auth.HashRefreshToken(secret)
+		if secret == "" || subtle.ConstantTimeCompare([]byte(hash), []byte(client.SecretHash.String)) != 1 {
+			log.Printf("%v wrong secret for OAuth client %q", securityTag, client.ID)
+			respondInvalidClient(w)
+			return database.OauthClient{}, false
+		}
+	} else if secret != "" {
+		respondInvalidClient(w)
+		return database.OauthClient{}, false
+	}
+	return client, true
+}
+
+func respondInvalidClient(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+	respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+}
+
+func (cfg *apiConfig) handlerOAuthToken(w http.ResponseWriter, r *http.Request) {
+	client, ok := cfg.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+
+	switch grantType := r.PostForm.Get("grant_type"); grantType {
+	case "authorization_code":
+		cfg.exchangeAuthorizationCode(w, r, client)
+	case "refresh_token":
+		cfg.refreshOAuthTokens(w, r, client)
+	default:
+		respondWithOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", fmt.Sprintf("grant type %q isn't supported", grantType))
+	}
+}
+
+// exchangeAuthorizationCode issues the first tokens of a grant, in exchange
+// for the authorization code the client got when the user consented.
+func (cfg *apiConfig) exchangeAuthorizationCode(w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	code, err := cfg.db.GetAuthorizationCode(r.Context(), auth.HashRefreshToken(r.PostForm.Get("code")))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && code.ClientID != client.ID) {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "authorization code is invalid")
+		return
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting authorization code from the database: %w", errorTag, err))
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "couldn't retrieve authorization code")
+		return
+	}
+	// a code used twice was stolen, so the tokens issued for it are revoked
+	if code.UsedAt.Valid {
+		cfg.revokeReusedAuthorizationCode(r.Context(), code)
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "authorization code is invalid")
+		return
+	}
+	if time.Now().UTC().After(code.ExpiresAt) {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "authorization code expired")
+		return
+	}
+	if r.PostForm.Get("redirect_uri") != code.RedirectUri {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "redirect_uri doesn't match the one of the authorization request")
+		return
+	}
+	if !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		log.Printf("%v wrong code verifier for OAuth client %q", securityTag, client.ID)
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "code_verifier doesn't match the code challenge")
+		return
+	}
+
+	scopes := storedScopes(code.Scopes)
+	jwt, ok := cfg.signOAuthAccessToken(w, r, client, code.UserID, code.FamilyID, scopes)
+	if !ok {
+		return
+	}
+
+	refreshToken, _ := auth.MakeRefreshToken()
+	var reused bool
+	err = cfg.withTx(r.Context(), func(qtx *database.Queries) error {
+		rowsAffected, err := qtx.UseAuthorizationCode(r.Context(), code.CodeHash)
+		if err != nil {
+			return fmt.Errorf("using authorization code: %w", err)
+		}
+		// someone else used the code since we read it
+		if rowsAffected == 0 {
+			reused = true
+			return nil
+		}
+		if err := qtx.StoreRefreshToken(r.Context(), database.StoreRefreshTokenParams{
+			TokenHash: auth.HashRefreshToken(refreshToken),
+			UserID:    code.UserID,
+			Days:      oauthRefreshTokenDays,
+			FamilyID:  code.FamilyID,
+			UserAgent: r.UserAgent(),
+			IpAddress: clientIP(r),
+			ClientID:  uuid.NullUUID{UUID: client.ID, Valid: true},
+			Scopes:    code.Scopes,
+		}); err != nil {
+			return fmt.Errorf("storing refresh token: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Print(fmt.Errorf("%v couldn't exchange authorization code: %w", errorTag, err))
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "couldn't store refresh token")
+		return
+	}
+	if reused {
+		cfg.revokeReusedAuthorizationCode(r.Context(), code)
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "authorization code is invalid")
+		return
+	}
+
+	log.Printf("%v OAuth client %q got tokens for user %q", successTag, client.ID, code.UserID)
+	respondWithOAuthTokens(w, jwt, refreshToken, scopes)
+}
+
+func (cfg *apiConfig) revokeReusedAuthorizationCode(ctx context.Context, code database.OauthAuthorizationCode) {
+	log.Printf("%v authorization code reused for OAuth client %q: revoking token family %q", securityTag, code.ClientID, code.FamilyID)
+	if err := cfg.db.RevokeTokenFamily(ctx, code.FamilyID); err != nil {
+		log.Print(fmt.Errorf("%v couldn't revoke token family %q: %w", errorTag, code.FamilyID, err))
+	}
+}
+
+// refreshOAuthTokens rotates the refresh token of a client, like logged in
+// users do with POST /api/refresh. The client may ask for fewer scopes than
+// the user granted, which only limits the new access token.
+func (cfg *apiConfig) refreshOAuthTokens(w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	refreshTokenDB, err := cfg.db.GetRefreshToken(r.Context(), auth.HashRefreshToken(r.PostForm.Get("refresh_token")))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && refreshTokenDB.ClientID.UUID != client.ID) {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "refresh token is invalid")
+		return
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting refresh token from the database: %w", errorTag, err))
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "couldn't retrieve refresh token")
+		return
+	}
+	if usable, reused := refreshTokenUsable(refreshTokenDB); !usable {
+		if reused {
+			cfg.revokeTokenFamily(r.Context(), refreshTokenDB)
+		}
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "refresh token is invalid")
+		return
+	}
+
+	scopes := storedScopes(refreshTokenDB.Scopes)
+	if r.PostForm.Has("scope") {
+		requested, err := auth.ParseScopeString(r.PostForm.Get("scope"))
+		if err != nil {
+			respondWithOAuthError(w, http.StatusBadRequest, "invalid_scope", err.Error())
+			return
+		}
+		for _, scope := range requested {
+			if !slices.Contains(scopes, scope) {
+				respondWithOAuthError(w, http.StatusBadRequest, "invalid_scope", fmt.Sprintf("scope %q wasn't granted", scope))
+				return
+			}
+		}
+		scopes = requested
+	}
+	jwt, ok := cfg.signOAuthAccessToken(w, r, client, refreshTokenDB.UserID, refreshTokenDB.FamilyID, scopes)
+	if !ok {
+		return
+	}
+
+	refreshToken, reused, err := cfg.rotateRefreshToken(r, refreshTokenDB)
+	if err != nil {
+		log.Print(fmt.Errorf("%v couldn't rotate refresh token: %w", errorTag, err))
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "couldn't rotate refresh token")
+		return
+	}
+	if reused {
+		cfg.revokeTokenFamily(r.Context(), refreshTokenDB)
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "refresh token is invalid")
+		return
+	}
+
+	log.Printf("%v OAuth client %q renewed tokens for user %q", successTag, client.ID, refreshTokenDB.UserID)
+	respondWithOAuthTokens(w, jwt, refreshToken, scopes)
+}
+
+// signOAuthAccessToken signs an access token limited to the given scopes. It
+// must be signed before the code or the refresh token it's issued for is used
+// up, so that failing to sign it doesn't leave the client without a way to
+// retry. When that's not possible, it responds to the client itself and
+// returns false.
+func (cfg *apiConfig) signOAuthAccessToken(w http.ResponseWriter, r *http.Request, client database.OauthClient, userID, familyID uuid.UUID, scopes []auth.Scope) (string, bool) {
+	tokenVersion, err := cfg.db.GetUserTokenVersion(r.Context(), userID)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting token version from the database: %w", errorTag, err))
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "couldn't retrieve user")
+		return "", false
+	}
+	jwt, err := cfg.signingKeys.Load().MakeJWT(auth.AccessToken{
+		UserID:       userID,
+		SessionID:    familyID,
+		TokenVersion: tokenVersion,
+		Scopes:       scopes,
+		ClientID:     client.ID,
+	}, oauthAccessTokenDuration)
+	if err != nil {
+		log.Print(fmt.Errorf("%v couldn't sign token: %w", errorTag, err))
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "couldn't create access token")
+		return "", false
+	}
+	return jwt, true
+}
+
+// respondWithOAuthTokens sends an access token limited to the given scopes to
+// the client, along with its new refresh token.
+func respondWithOAuthTokens(w http.ResponseWriter, jwt, refreshToken string, scopes []auth.Scope) {
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, OAuthTokens{
+		AccessToken:  jwt,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenDuration.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        auth.FormatScopes(scopes),
+	})
+}
+
+// isJWT reports whether a token looks like a JWT rather than a refresh token,
+// which has no dots.
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// handlerOAuthIntrospect tells a client whether one of its tokens is still
+// active. Tokens issued to other clients, or to a login, are reported as
+// inactive, so that clients can't learn anything about them.
+func (cfg *apiConfig) handlerOAuthIntrospect(w http.ResponseWriter, r *http.Request) {
+	client, ok := cfg.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+
+	introspection := TokenIntrospection{}
+	if isJWT(token) {
+		accessToken, err := cfg.validateAccessToken(r.Context(), token)
+		if err == nil && accessToken.ClientID == client.ID {
+			introspection = TokenIntrospection{
+				Active:    true,
+				Scope:     auth.FormatScopes(accessToken.Scopes),
+				ClientID:  client.ID.String(),
+				Subject:   accessToken.UserID.String(),
+				TokenType: "Bearer",
+				ExpiresAt: accessToken.ExpiresAt.Unix(),
+				IssuedAt:  accessToken.IssuedAt.Unix(),
+				Issuer:    auth.Issuer,
+				Audience:  auth.Audience,
+			}
+		}
+	} else if !auth.IsPersonalAccessToken(token) {
+		refreshToken, err := cfg.db.GetRefreshToken(r.Context(), auth.HashRefreshToken(token))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Print(fmt.Errorf("%v getting refresh token from the database: %w", errorTag, err))
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "couldn't retrieve refresh token")
+			return
+		}
+		if err == nil && refreshToken.ClientID.Valid && refreshToken.ClientID.UUID == client.ID &&
+			!refreshToken.RevokedAt.Valid && time.Now().UTC().Before(refreshToken.ExpiresAt) {
+			introspection = TokenIntrospection{
+				Active:    true,
+				Scope:     auth.FormatScopes(storedScopes(refreshToken.Scopes)),
+				ClientID:  client.ID.String(),
+				Subject:   refreshToken.UserID.String(),
+				ExpiresAt: refreshToken.ExpiresAt.Unix(),
+				IssuedAt:  refreshToken.CreatedAt.Unix(),
+			}
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, introspection)
+}
+
+// handlerOAuthRevoke revokes the grant a token of the client was issued
+// under: its refresh tokens and, with them, its access tokens. As RFC 7009
+// asks, unknown tokens and those of others are ignored rather than rejected.
+func (cfg *apiConfig) handlerOAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	client, ok := cfg.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+
+	var familyID uuid.UUID
+	if isJWT(token) {
+		accessToken, err := cfg.validateJWT(r.Context(), token)
+		if err == nil && accessToken.ClientID == client.ID {
+			familyID = accessToken.SessionID
+		}
+	} else {
+		refreshToken, err := cfg.db.GetRefreshToken(r.Context(), auth.HashRefreshToken(token))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Print(fmt.Errorf("%v getting refresh token from the database: %w", errorTag, err))
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "couldn't retrieve refresh token")
+			return
+		}
+		if err == nil && refreshToken.ClientID.Valid && refreshToken.ClientID.UUID == client.ID {
+			familyID = refreshToken.FamilyID
+		}
+	}
+
+	if familyID != uuid.Nil {
+		if err := cfg.db.RevokeTokenFamily(r.Context(), familyID); err != nil {
+			log.Print(fmt.Errorf("%v couldn't revoke token family %q: %w", errorTag, familyID, err))
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "couldn't revoke token")
+			return
+		}
+		log.Printf("%v OAuth client %q revoked token family %q", successTag, client.ID, familyID)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+}
+
+// handlerOAuthMetadata describes the authorization server, as RFC 8414 asks,
+// so that clients can find its endpoints and what it supports.
+func (cfg *apiConfig) handlerOAuthMetadata(w http.ResponseWriter, r *http.Request) {
+	type metadata struct {
+		Issuer                            string   `json:"issuer"`
+		AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+		TokenEndpoint                     string   `json:"token_endpoint"`
+		IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+		RevocationEndpoint                string   `json:"revocation_endpoint"`
+		JWKSURI                           string   `json:"jwks_uri"`
+		ScopesSupported                   []string `json:"scopes_supported"`
+		ResponseTypesSupported            []string `json:"response_types_supported"`
+		GrantTypesSupported               []string `json:"grant_types_supported"`
+		CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+		TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+		AuthorizationResponseISSParameter bool     `json:"authorization_response_iss_parameter_supported"`
+		IntrospectionEndpointAuthMethods  []string `json:"introspection_endpoint_auth_methods_supported"`
+		RevocationEndpointAuthMethods     []string `json:"revocation_endpoint_auth_methods_supported"`
+	}
+	authMethods := []string{"client_secret_basic", "client_secret_post", "none"}
+	respondWithJSON(w, http.StatusOK, metadata{
+		Issuer:                            cfg.baseURL,
+		AuthorizationEndpoint:             cfg.baseURL + "/oauth/authorize",
+		TokenEndpoint:                     cfg.baseURL + "/oauth/token",
+		IntrospectionEndpoint:             cfg.baseURL + "/oauth/introspect",
+		RevocationEndpoint:                cfg.baseURL + "/oauth/revoke",
+		JWKSURI:                           cfg.baseURL + "/.well-known/jwks.json",
+		ScopesSupported:                   scopeNames(auth.Scopes),
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		TokenEndpointAuthMethodsSupported: authMethods,
+		AuthorizationResponseISSParameter: true,
+		IntrospectionEndpointAuthMethods:  authMethods,
+		RevocationEndpointAuthMethods:     authMethods,
+	})
+}
+
+// setPageHeaders keeps the pages of the authorization endpoint out of caches
+// and frames, so that other sites can't trick users into clicking on them.
+func setPageHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+}
+
+var consentPage = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>Authorize {{.ClientName}} - Chirpy</title>
+    <style>
+      body { font-family: sans-serif; max-width: 28rem; margin: 2rem auto; padding: 0 1rem; }
+      label { display: block; margin-top: 0.75rem; }
+      input[type=email], input[type=password], input[type=text] { width: 100%; padding: 0.4rem; box-sizing: border-box; }
+      .error { color: #b00020; }
+      .buttons { margin-top: 1.25rem; display: flex; gap: 0.5rem; }
+    </style>
+  </head>
+  <body>
+    <h1>Authorize {{.ClientName}}</h1>
+    <p><strong>{{.ClientName}}</strong> wants to access your Chirpy account. It will be able to:</p>
+    <ul>
+      {{range .Scopes}}<li>{{.}}</li>
+      {{end}}
+    </ul>
+    <p>You'll be sent back to <strong>{{.RedirectHost}}</strong>. Only allow apps you trust.</p>
+    {{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+    <form method="post" action="/oauth/authorize">
+      {{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+      {{end}}
+      <label>Email <input type="email" name="email" value="{{.Email}}" autocomplete="username" required></label>
+      <label>Password <input type="password" name="password" autocomplete="current-password"></label>
+      <label>One-time password, if you enabled two-factor authentication <input type="text" name="code" autocomplete="one-time-code"></label>
+      <div class="buttons">
+        <button type="submit" name="decision" value="allow">Allow</button>
+        <button type="submit" name="decision" value="deny" formnovalidate>Deny</button>
+      </div>
+    </form>
+  </body>
+</html>
+`))
+
+// renderConsentPage asks the user to log in and allow the client what it
+// asked for, showing why the last attempt failed, if it did.
+func renderConsentPage(w http.ResponseWriter, statusCode int, request authorizationRequest, email, message string) {
+	// the redirect URI was parsed when it was registered
+	target, _ := url.Parse(request.RedirectURI)
+	redirectHost := target.Host
+	if redirectHost == "" {
+		redirectHost = target.Scheme + ":"
+	}
+	scopes := make([]string, len(request.Scopes))
+	for i, scope := range request.Scopes {
+		scopes[i] = scopeDescriptions[scope]
+	}
+	params := map[string]string{
+		"response_type":         "code",
+		"client_id":             request.Client.ID.String(),
+		"redirect_uri":          request.RedirectURI,
+		"scope":                 auth.FormatScopes(request.Scopes),
+		"code_challenge":        request.CodeChallenge,
+		"code_challenge_method": "S256",
+	}
+	if request.State != "" {
+		params["state"] = request.State
+	}
+
+	setPageHeaders(w)
+	w.WriteHeader(statusCode)
+	if err := consentPage.Execute(w, map[string]any{
+		"ClientName":   request.Client.Name,
+		"Scopes":       scopes,
+		"RedirectHost": redirectHost,
+		"Error":        message,
+		"Params":       params,
+		"Email":        email,
+	}); err != nil {
+		log.Print(fmt.Errorf("%v rendering consent page: %w", errorTag, err))
+	}
+}
+
+var oauthErrorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <title>Authorization failed - Chirpy</title>
+  </head>
+  <body>
+    <h1>Authorization failed</h1>
+    <p>{{.}}</p>
+  </body>
+</html>
+`))
+
+// renderOAuthErrorPage shows an error of the authorization endpoint to the
+// user, for when it can't be sent back to the client.
+func renderOAuthErrorPage(w http.ResponseWriter, statusCode int, message string) {
+	setPageHeaders(w)
+	w.WriteHeader(statusCode)
+	if err := oauthErrorPage.Execute(w, message); err != nil {
+		log.Print(fmt.Errorf("%v rendering error page: %w", errorTag, err))
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pageCursor marks the boundary row of a page. Rows are ordered by
+// (created_at, id), so the pair is enough to resume the listing from that row
+// in either direction. Clients only ever see its opaque encoding.
+type pageCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+	Backward  bool      `json:"b,omitempty"`
+}
+
+func (c pageCursor) encode() string {
+	// marshalling a struct of time, uuid and bool values can't fail
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodePageCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("decoding cursor: %w", err)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("parsing cursor: %w", err)
+	}
+	if c.ID == uuid.Nil || c.CreatedAt.IsZero() {
+		return c, errors.New("cursor is missing its position")
+	}
+	return c, nil
+}
+
+type pageRequest struct {
+	limit  int
+	cursor *pageCursor
+}
+
+// parseLimit reads the `limit` URL parameter.
+func parseLimit(query url.Values) (int, error) {
+	match := query.Get("limit")
+	if match == "" {
+		return defaultPageSize, nil
+	}
+	limit, err := strconv.Atoi(match)
+	if err != nil || limit < 1 || limit > maxPageSize {
+		return 0, fmt.Errorf("limit must be an integer between 1 and %d", maxPageSize)
+	}
+	return limit, nil
+}
+
+// parsePageRequest reads the `limit` and `cursor` URL parameters.
+func parsePageRequest(query url.Values) (pageRequest, error) {
+	var req pageRequest
+
+	limit, err := parseLimit(query)
+	if err != nil {
+		return req, err
+	}
+	req.limit = limit
+
+	if match := query.Get("cursor"); match != "" {
+		cursor, err := decodePageCursor(match)
+		if err != nil {
+			return req, err
+		}
+		req.cursor = &cursor
+	}
+
+	return req, nil
+}
+
+// backward reports whether the client is walking towards the previous page.
+func (req pageRequest) backward() bool {
+	return req.cursor != nil && req.cursor.Backward
+}
+
+// fetchSize is the number of rows to ask the database for: one more than the
+// page size so we can tell whether there's anything past the page.
+func (req pageRequest) fetchSize() int32 {
+	return int32(req.limit + 1)
+}
+
+// cursorArgs returns the cursor position as nullable query arguments.
+func (req pageRequest) cursorArgs() (sql.NullTime, uuid.NullUUID) {
+	if req.cursor == nil {
+		return sql.NullTime{}, uuid.NullUUID{}
+	}
+	return sql.NullTime{Time: req.cursor.CreatedAt, Valid: true},
+		uuid.NullUUID{UUID: req.cursor.ID, Valid: true}
+}
+
+// Pagination is sent alongside every paginated list.
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+}
+
+// paginate trims the extra row fetched through fetchSize, puts rows read
+// backwards into display order and builds the cursors of the neighbouring pages.
+func paginate[T any](rows []T, req pageRequest, key func(T) pageCursor) ([]T, *pageCursor, *pageCursor) {
+	hasMore := len(rows) > req.limit
+	if hasMore {
+		rows = rows[:req.limit]
+	}
+	if len(rows) == 0 {
+		return rows, nil, nil
+	}
+
+	hasNext, hasPrev := hasMore, req.cursor != nil
+	if req.backward() {
+		slices.Reverse(rows)
+		hasNext, hasPrev = true, hasMore
+	}
+
+	var next, prev *pageCursor
+	if hasNext {
+		c := key(rows[len(rows)-1])
+		next = &c
+	}
+	if hasPrev {
+		c := key(rows[0])
+		c.Backward = true
+		prev = &c
+	}
+	return rows, next, prev
+}
+
+// setPaginationLinks builds the links to the neighbouring pages out of the
+// current request URL, advertises them through the Link header and returns
+// them so they can be embedded in the response body.
+func setPaginationLinks(w http.ResponseWriter, r *http.Request, req pageRequest, next, prev *pageCursor) Pagination {
+	pagination := Pagination{Limit: req.limit}
+
+	limit := strconv.Itoa(req.limit)
+	if next != nil {
+		pagination.NextCursor = next.encode()
+		pagination.Next = pageURL(r, "cursor", pagination.NextCursor, "limit", limit)
+	}
+	if prev != nil {
+		pagination.PrevCursor = prev.encode()
+		pagination.Prev = pageURL(r, "cursor", pagination.PrevCursor, "limit", limit)
+	}
+	setLinkHeader(w, pagination.Next, pagination.Prev)
+
+	return pagination
+}
+
+// pageURL returns the path and query of the current request with the given
+// key-value pairs of URL parameters overwritten.
+func pageURL(r *http.Request, keyValues ...string) string {
+	query := r.URL.Query()
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		query.Set(keyValues[i], keyValues[i+1])
+	}
+	return (&url.URL{Path: r.URL.Path, RawQuery: query.Encode()}).String()
+}
+
+// setLinkHeader advertises the neighbouring pages that exist (RFC 8288).
+func setLinkHeader(w http.ResponseWriter, next, prev string) {
+	var links []string
+	if next != "" {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"next\"", next))
+	}
+	if prev != "" {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"prev\"", prev))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+func chirpCursor(chirp database.Chirp) pageCursor {
+	return pageCursor{CreatedAt: chirp.CreatedAt, ID: chirp.ID}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/base64"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testCursor returns the cursor of the n-th row of a listing, n > 0.
+func testCursor(n int) pageCursor {
+	return pageCursor{
+		CreatedAt: time.Date(2025, 1, 1, 0, n, 0, 0, time.UTC),
+		ID:        uuid.UUID{15: byte(n)},
+	}
+}
+
+func testRows(ns ...int) []pageCursor {
+	rows := make([]pageCursor, 0, len(ns))
+	for _, n := range ns {
+		rows = append(rows, testCursor(n))
+	}
+	return rows
+}
+
+func backwardCursor(n int) *pageCursor {
+	c := testCursor(n)
+	c.Backward = true
+	return &c
+}
+
+func forwardCursor(n int) *pageCursor {
+	c := testCursor(n)
+	return &c
+}
+
+func TestDecodePageCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		cursor  string
+		want    pageCursor
+		wantErr bool
+	}{
+		{
+			name:   "forward",
+			cursor: testCursor(1).encode(),
+			want:   testCursor(1),
+		},
+		{
+			name:   "backward",
+			cursor: backwardCursor(2).encode(),
+			want:   *backwardCursor(2),
+		},
+		{
+			name:    "not base64",
+			cursor:  "not a cursor!",
+			wantErr: true,
+		},
+		{
+			name:    "not JSON",
+			cursor:  base64.RawURLEncoding.EncodeToString([]byte("not JSON")),
+			wantErr: true,
+		},
+		{
+			name:    "zero cursor",
+			cursor:  pageCursor{}.encode(),
+			wantErr: true,
+		},
+		{
+			name:    "zero ID",
+			cursor:  pageCursor{CreatedAt: testCursor(1).CreatedAt}.encode(),
+			wantErr: true,
+		},
+		{
+			name:    "zero time",
+			cursor:  pageCursor{ID: testCursor(1).ID}.encode(),
+			wantErr: true,
+		},
+		{
+			name:    "empty object",
+			cursor:  base64.RawURLEncoding.EncodeToString([]byte("{}")),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := decodePageCursor(test.cursor)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v when expecting an error to be %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if !got.CreatedAt.Equal(test.want.CreatedAt) || got.ID != test.want.ID || got.Backward != test.want.Backward {
+				t.Errorf("got %+v when expecting %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name     string
+		rows     []pageCursor
+		req      pageRequest
+		wantRows []pageCursor
+		wantNext *pageCursor
+		wantPrev *pageCursor
+	}{
+		{
+			name:     "empty listing",
+			rows:     testRows(),
+			req:      pageRequest{limit: 2},
+			wantRows: testRows(),
+		},
+		{
+			name:     "only page",
+			rows:     testRows(1, 2),
+			req:      pageRequest{limit: 2},
+			wantRows: testRows(1, 2),
+		},
+		{
+			name:     "first page of many",
+			rows:     testRows(1, 2, 3),
+			req:      pageRequest{limit: 2},
+			wantRows: testRows(1, 2),
+			wantNext: forwardCursor(2),
+		},
+		{
+			name:     "middle page going forward",
+			rows:     testRows(3, 4, 5),
+			req:      pageRequest{limit: 2, cursor: forwardCursor(2)},
+			wantRows: testRows(3, 4),
+			wantNext: forwardCursor(4),
+			wantPrev: backwardCursor(3),
+		},
+		{
+			name:     "last page going forward",
+			rows:     testRows(5),
+			req:      pageRequest{limit: 2, cursor: forwardCursor(4)},
+			wantRows: testRows(5),
+			wantPrev: backwardCursor(5),
+		},
+		{
+			name:     "nothing past the cursor",
+			rows:     testRows(),
+			req:      pageRequest{limit: 2, cursor: forwardCursor(4)},
+			wantRows: testRows(),
+		},
+		{
+			name:     "middle page going backward is reversed",
+			rows:     testRows(4, 3, 2),
+			req:      pageRequest{limit: 2, cursor: backwardCursor(5)},
+			wantRows: testRows(3, 4),
+			wantNext: forwardCursor(4),
+			wantPrev: backwardCursor(3),
+		},
+		{
+			name:     "first page going backward",
+			rows:     testRows(2, 1),
+			req:      pageRequest{limit: 2, cursor: backwardCursor(3)},
+			wantRows: testRows(1, 2),
+			wantNext: forwardCursor(2),
+		},
+	}
+
+	key := func(row pageCursor) pageCursor { return row }
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows, next, prev := paginate(test.rows, test.req, key)
+			if !slices.Equal(rows, test.wantRows) {
+				t.Errorf("got rows %v when expecting %v", rows, test.wantRows)
+			}
+			if !equalCursors(next, test.wantNext) {
+				t.Errorf("got next cursor %v when expecting %v", next, test.wantNext)
+			}
+			if !equalCursors(prev, test.wantPrev) {
+				t.Errorf("got previous cursor %v when expecting %v", prev, test.wantPrev)
+			}
+		})
+	}
+}
+
+func equalCursors(a, b *pageCursor) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/neira-daniel/go-chirpy/internal/auth"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+	"github.com/neira-daniel/go-chirpy/internal/mail"
+	"github.com/neira-daniel/go-chirpy/internal/passwordpolicy"
+)
+
+const (
+	// passwordResetDuration is how long password reset links work for
+	passwordResetDuration = time.Hour
+	// passwordResetLimit is how many password reset emails a user can get
+	// within passwordResetDuration
+	passwordResetLimit = 3
+	// passwordResetTimeout is how long the database work of a password reset
+	// request can take once the client got its response
+	passwordResetTimeout = 30 * time.Second
+)
+
+// errPasswordRejected rolls back a password reset when the new password
+// doesn't meet the policy, so that the reset link keeps working.
+var errPasswordRejected = errors.New("password doesn't meet the policy")
+
+// loadPasswords reads the parameters of the password hasher from the
+// environment, falling back to the default ones when they aren't given.
+func loadPasswords() (auth.Passwords, error) {
+	passwords := auth.DefaultPasswords()
+	if value := os.Getenv("ARGON2ID_PARAMS"); value != "" {
+		params, err := auth.ParseArgon2idParams(value)
+		if err != nil {
+			return auth.Passwords{}, fmt.Errorf("parsing ARGON2ID_PARAMS: %w", err)
+		}
+		passwords.Current = auth.Argon2idHasher{Params: params}
+	}
+	return passwords, nil
+}
+
+// loadPasswordPolicy reads the password policy from the environment, falling
+// back to the default one for the settings that aren't given.
+func loadPasswordPolicy() (passwordpolicy.Policy, error) {
+	policy := passwordpolicy.DefaultPolicy()
+	for _, setting := range []struct {
+		name  string
+		value *int
+	}{
+		{"PASSWORD_MIN_LENGTH", &policy.MinLength},
+		{"PASSWORD_MAX_LENGTH", &policy.MaxLength},
+		{"PASSWORD_MIN_SCORE", &policy.MinScore},
+	} {
+		value := os.Getenv(setting.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return passwordpolicy.Policy{}, fmt.Errorf("parsing %v: %w", setting.name, err)
+		}
+		*setting.value = n
+	}
+	if dir := os.Getenv("BREACHED_PASSWORDS_DIR"); dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return passwordpolicy.Policy{}, fmt.Errorf("opening BREACHED_PASSWORDS_DIR: %w", err)
+		}
+		if !info.IsDir() {
+			return passwordpolicy.Policy{}, fmt.Errorf("BREACHED_PASSWORDS_DIR %q isn't a directory", dir)
+		}
+		policy.Breaches = &passwordpolicy.BreachList{Dir: dir}
+	}
+	return policy, policy.Validate()
+}
+
+// checkPassword applies the password policy to a password chosen by the user
+// with the given email. When the password can't be chosen, it responds to the
+// client itself and returns false.
+func (cfg *apiConfig) checkPassword(w http.ResponseWriter, password, email string) bool {
+	problems, err := cfg.passwordPolicy.Check(password, email)
+	if err != nil {
+		log.Print(fmt.Errorf("%v checking password against the policy: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "server error: couldn't check password")
+		return false
+	}
+	if len(problems) > 0 {
+		respondWithPasswordProblems(w, problems)
+		return false
+	}
+	return true
+}
+
+func respondWithPasswordProblems(w http.ResponseWriter, problems []string) {
+	respondWithFieldErrors(w, http.StatusBadRequest, "request error: password doesn't meet the password policy", map[string][]string{
+		"password": problems,
+	})
+}
+
+func (cfg *apiConfig) handlerRequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	type payload struct {
+		Email string `json:"email"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	var data payload
+	if err := decoder.Decode(&data); err != nil {
+		log.Print(fmt.Errorf("%v decoding non-conforming JSON request: %w", errorTag, err))
+		respondWithError(w, http.StatusBadRequest, "non-conforming JSON received")
+		return
+	}
+	if data.Email == "" {
+		respondWithError(w, http.StatusBadRequest, "request error: email is required")
+		return
+	}
+
+	// the response is the same whether or not the email belongs to a user,
+	// and it's sent before looking the email up, so that neither its content
+	// nor its timing can be used to find out who has an account
+	go cfg.requestPasswordReset(data.Email)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// requestPasswordReset emails a password reset link to the user with the given
+// email, if any. It runs in the background, so errors are only logged.
+func (cfg *apiConfig) requestPasswordReset(email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), passwordResetTimeout)
+	defer cancel()
+
+	user, err := cfg.db.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("%v password reset requested for unknown email %q", warningTag, email)
+		return
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting user from the database: %w", errorTag, err))
+		return
+	}
+
+	sent, err := cfg.db.CountPasswordResetTokensSince(ctx, database.CountPasswordResetTokensSinceParams{
+		UserID: user.ID,
+		Since:  time.Now().UTC().Add(-passwordResetDuration),
+	})
+	if err != nil {
+		log.Print(fmt.Errorf("%v counting password reset tokens: %w", errorTag, err))
+		return
+	}
+	if sent >= passwordResetLimit {
+		log.Printf("%v too many password resets requested for user %q", securityTag, user.ID)
+		return
+	}
+
+	token, _ := auth.MakeRefreshToken()
+	if err := cfg.db.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashRefreshToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().UTC().Add(passwordResetDuration),
+	}); err != nil {
+		log.Print(fmt.Errorf("%v couldn't store password reset token: %w", errorTag, err))
+		return
+	}
+
+	link := fmt.Sprintf("%s/app/reset-password?token=%s", cfg.baseURL, url.QueryEscape(token))
+	cfg.sendEmail(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your Chirpy account. To choose a new one, follow this link within the next %v:\n\n%s\n\nIf it wasn't you, you can ignore this email and your password won't change.\n",
+			passwordResetDuration, link),
+	})
+
+	log.Printf("%v password reset requested for user %q", successTag, user.ID)
+}
+
+func (cfg *apiConfig) handlerConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	type payload struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	var data payload
+	if err := decoder.Decode(&data); err != nil {
+		log.Print(fmt.Errorf("%v decoding non-conforming JSON request: %w", errorTag, err))
+		respondWithError(w, http.StatusBadRequest, "non-conforming JSON received")
+		return
+	}
+	if data.Token == "" || data.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "request error: token and password are required")
+		return
+	}
+
+	var user database.User
+	var invalidToken bool
+	var problems []string
+	err := cfg.withTx(r.Context(), func(qtx *database.Queries) error {
+		resetToken, err := qtx.UsePasswordResetToken(r.Context(), auth.HashRefreshToken(data.Token))
+		if errors.Is(err, sql.ErrNoRows) {
+			invalidToken = true
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("using password reset token: %w", err)
+		}
+		user, err = qtx.GetUserByID(r.Context(), resetToken.UserID)
+		if err != nil {
+			return fmt.Errorf("getting user: %w", err)
+		}
+		problems, err = cfg.passwordPolicy.Check(data.Password, user.Email)
+		if err != nil {
+			return fmt.Errorf("checking password against the policy: %w", err)
+		}
+		if len(problems) > 0 {
+			return errPasswordRejected
+		}
+		hashedPassword, err := cfg.passwords.Hash(data.Password)
+		if err != nil {
+			return fmt.Errorf("hashing password: %w", err)
+		}
+		// increasing the token version revokes every access token
+		user, err = qtx.ResetPassword(r.Context(), database.ResetPasswordParams{
+			ID:             resetToken.UserID,
+			HashedPassword: hashedPassword,
+		})
+		if err != nil {
+			return fmt.Errorf("updating password: %w", err)
+		}
+		if err := qtx.InvalidatePasswordResetTokens(r.Context(), user.ID); err != nil {
+			return fmt.Errorf("invalidating password reset tokens: %w", err)
+		}
+		if err := qtx.RevokeAllSessions(r.Context(), user.ID); err != nil {
+			return fmt.Errorf("revoking sessions: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, errPasswordRejected) {
+		respondWithPasswordProblems(w, problems)
+		return
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v resetting password: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't reset password")
+		return
+	}
+	if invalidToken {
+		log.Printf("%v got invalid password reset token", warningTag)
+		respondWithError(w, http.StatusBadRequest, "request error: invalid or expired password reset token")
+		return
+	}
+
+	cfg.sendEmail(mail.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy password was changed",
+		Body:    "The password of your Chirpy account was just reset, and every device logged in to it was logged out.\n\nIf it wasn't you, reset your password again right away.\n",
+	})
+
+	log.Printf("%v password of user %q reset: every session revoked", securityTag, user.ID)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/neira-daniel/go-chirpy/internal/auth"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+	"github.com/neira-daniel/go-chirpy/internal/moderation"
+)
+
+// handlerRechirp reposts a chirp. Without a body, it's a plain rechirp that
+// users can make only once per chirp. With a body, it's a quote chirp.
+func (cfg *apiConfig) handlerRechirp(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+	if !cfg.canPost(w, r, userID) {
+		return
+	}
+	original, ok := cfg.chirpFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	type jsonRequest struct {
+		Body string `json:"body"`
+	}
+	// the request body is optional, as plain rechirps don't need one
+	decoder := json.NewDecoder(r.Body)
+	var data jsonRequest
+	if err := decoder.Decode(&data); err != nil && !errors.Is(err, io.EOF) {
+		log.Print(fmt.Errorf("%v decoding non-conforming JSON request: %w", errorTag, err))
+		respondWithError(w, http.StatusBadRequest, "non-conforming JSON received")
+		return
+	}
+
+	// plain rechirps have nothing to add, so reposting one reposts its original
+	if original.RechirpOf.Valid && !original.IsQuote {
+		originalID := original.RechirpOf.UUID
+		var err error
+		original, err = cfg.db.GetChirpByID(r.Context(), originalID)
+		if err != nil {
+			log.Print(fmt.Errorf("%v getting rechirped chirp %q: %w", errorTag, originalID, err))
+			respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve chirp")
+			return
+		}
+	}
+
+	params := database.SaveChirpParams{
+		UserID:    userID,
+		RechirpOf: uuid.NullUUID{UUID: original.ID, Valid: true},
+		IsQuote:   data.Body != "",
+	}
+	var flaggedWords []string
+	if params.IsQuote {
+		if err := validateChirp(data.Body); err != nil {
+			log.Printf("%v invalid chirp", warningTag)
+			respondWithError(w, http.StatusBadRequest, "invalid chirp")
+			return
+		}
+		moderated, ok := cfg.moderateChirp(w, data.Body)
+		if !ok {
+			return
+		}
+		params.Body = moderated.Text
+		flaggedWords = moderated.Words(moderation.ActionFlag)
+	}
+
+	chirp, err := cfg.storeChirp(r.Context(), params, flaggedWords)
+	if isUniqueViolation(err, "chirps_plain_rechirp_idx") {
+		respondWithError(w, http.StatusConflict, "chirp already rechirped")
+		return
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v storing rechirp in the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't store chirp")
+		return
+	}
+
+	chirpsWithTags, err := cfg.presentChirps(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, []database.Chirp{chirp})
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting stored chirp details: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve chirp")
+		return
+	}
+
+	log.Printf("%v user %q rechirped chirp %q", successTag, userID, original.ID)
+	respondWithJSON(w, http.StatusCreated, chirpsWithTags[0])
+}
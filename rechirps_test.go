@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+)
+
+func TestStorePlainRechirps(t *testing.T) {
+	cfg := testDB(t)
+	alice := testUser(t, cfg, "alice@example.com")
+	bob := testUser(t, cfg, "bob@example.com")
+
+	store := func(params database.SaveChirpParams) (database.Chirp, error) {
+		return cfg.storeChirp(t.Context(), params, nil)
+	}
+	aliceChirp, err := store(database.SaveChirpParams{Body: "hello from alice", UserID: alice.ID})
+	if err != nil {
+		t.Fatalf("storing chirp: %v", err)
+	}
+	bobChirp, err := store(database.SaveChirpParams{Body: "hello from bob", UserID: bob.ID})
+	if err != nil {
+		t.Fatalf("storing chirp: %v", err)
+	}
+
+	// plain rechirps all have an empty body
+	if _, err := store(database.SaveChirpParams{
+		UserID:    bob.ID,
+		RechirpOf: uuid.NullUUID{UUID: aliceChirp.ID, Valid: true},
+	}); err != nil {
+		t.Fatalf("storing first plain rechirp: %v", err)
+	}
+	if _, err := store(database.SaveChirpParams{
+		UserID:    alice.ID,
+		RechirpOf: uuid.NullUUID{UUID: bobChirp.ID, Valid: true},
+	}); err != nil {
+		t.Fatalf("storing second plain rechirp: %v", err)
+	}
+
+	// quotes can share their body with any other chirp
+	if _, err := store(database.SaveChirpParams{
+		Body:      "hello from alice",
+		UserID:    bob.ID,
+		RechirpOf: uuid.NullUUID{UUID: aliceChirp.ID, Valid: true},
+		IsQuote:   true,
+	}); err != nil {
+		t.Fatalf("storing quote: %v", err)
+	}
+
+	_, err = store(database.SaveChirpParams{
+		UserID:    bob.ID,
+		RechirpOf: uuid.NullUUID{UUID: aliceChirp.ID, Valid: true},
+	})
+	if !isUniqueViolation(err, "chirps_plain_rechirp_idx") {
+		t.Errorf("got %v when rechirping twice, expecting a violation of chirps_plain_rechirp_idx", err)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+	"github.com/neira-daniel/go-chirpy/internal/search"
+)
+
+// maxSearchOffset bounds how deep clients can page into search results:
+// PostgreSQL has to rank every skipped row anyway.
+const maxSearchOffset = 1000
+
+type SearchResult struct {
+	Chirp
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type SearchPagination struct {
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	Next   string `json:"next,omitempty"`
+	Prev   string `json:"prev,omitempty"`
+}
+
+type SearchResults struct {
+	Query      string           `json:"query"`
+	Results    []SearchResult   `json:"results"`
+	Pagination SearchPagination `json:"pagination"`
+}
+
+// parseTimeParameter reads an optional RFC 3339 timestamp from the URL.
+func parseTimeParameter(r *http.Request, key string) (sql.NullTime, error) {
+	match := r.URL.Query().Get(key)
+	if match == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, match)
+	if err != nil {
+		return sql.NullTime{}, fmt.Errorf("%s must be an RFC 3339 timestamp", key)
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}, nil
+}
+
+func (cfg *apiConfig) handlerSearchChirps(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	tsQuery, err := search.ToTSQuery(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("request error: %v", err))
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("request error: %v", err))
+		return
+	}
+
+	offset := 0
+	if match := r.URL.Query().Get("offset"); match != "" {
+		offset, err = strconv.Atoi(match)
+		if err != nil || offset < 0 || offset > maxSearchOffset {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("request error: offset must be an integer between 0 and %d", maxSearchOffset))
+			return
+		}
+	}
+
+	var authorID uuid.NullUUID
+	if match := r.URL.Query().Get("author_id"); match != "" {
+		userID, err := uuid.Parse(match)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "request error: not a valid author UUID")
+			return
+		}
+		authorID = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+
+	since, err := parseTimeParameter(r, "since")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("request error: %v", err))
+		return
+	}
+	until, err := parseTimeParameter(r, "until")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("request error: %v", err))
+		return
+	}
+	if since.Valid && until.Valid && !since.Time.Before(until.Time) {
+		respondWithError(w, http.StatusBadRequest, "request error: since must be earlier than until")
+		return
+	}
+
+	// we fetch one extra row to know whether there's a next page
+	rows, err := cfg.db.SearchChirps(r.Context(), database.SearchChirpsParams{
+		SearchQuery: tsQuery,
+		AuthorID:    authorID,
+		Since:       since,
+		Until:       until,
+		PageSize:    int32(limit + 1),
+		PageOffset:  int32(offset),
+	})
+	if err != nil {
+		log.Print(fmt.Errorf("%v searching chirps in the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't search chirps")
+		return
+	}
+
+	pagination := SearchPagination{Limit: limit, Offset: offset}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		if offset+limit <= maxSearchOffset {
+			pagination.Next = pageURL(r, "offset", strconv.Itoa(offset+limit), "limit", strconv.Itoa(limit))
+		}
+	}
+	if offset > 0 {
+		pagination.Prev = pageURL(r, "offset", strconv.Itoa(max(offset-limit, 0)), "limit", strconv.Itoa(limit))
+	}
+	setLinkHeader(w, pagination.Next, pagination.Prev)
+
+	chirps := make([]database.Chirp, len(rows))
+	for i, row := range rows {
+		chirps[i] = database.Chirp{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			Body:      row.Body,
+			UserID:    row.UserID,
+			InReplyTo: row.InReplyTo,
+			ThreadID:  row.ThreadID,
+			RechirpOf: row.RechirpOf,
+			IsQuote:   row.IsQuote,
+			EditedAt:  row.EditedAt,
+		}
+	}
+	chirpsWithTags, err := cfg.presentChirps(r.Context(), cfg.viewerID(r), chirps)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting chirp details from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't search chirps")
+		return
+	}
+
+	results := make([]SearchResult, len(rows))
+	for i, row := range rows {
+		results[i] = SearchResult{
+			Chirp:   chirpsWithTags[i],
+			Rank:    row.Rank,
+			Snippet: search.Highlight(row.Snippet),
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, SearchResults{
+		Query:      query,
+		Results:    results,
+		Pagination: pagination,
+	})
+}
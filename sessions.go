@@ -0,0 +1,136 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+)
+
+// maxDeviceNameLength is the longest name, in characters, users can give to
+// the device they log in from.
+const maxDeviceNameLength = 100
+
+// Session is a login of a user, which lasts for as long as its refresh tokens
+// do. ID is the same for every refresh token issued since the login. Access
+// the user granted to an OAuth client is a session too, with its ClientID.
+type Session struct {
+	ID         uuid.UUID  `json:"id"`
+	DeviceName string     `json:"device_name,omitempty"`
+	ClientID   *uuid.UUID `json:"client_id,omitempty"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	SignedInAt time.Time  `json:"signed_in_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+}
+
+// clientIP returns the address of the client the request comes from. Headers
+// like X-Forwarded-For are ignored, as anyone can set them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// parseDeviceName validates the name of the device a user logs in from. An
+// empty name is valid and means that no name was given. When the name can't
+// be accepted, it responds to the client itself and returns false.
+func parseDeviceName(w http.ResponseWriter, name string) (sql.NullString, bool) {
+	if name == "" {
+		return sql.NullString{}, true
+	}
+	if utf8.RuneCountInString(name) > maxDeviceNameLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("request error: device name must be at most %d characters long", maxDeviceNameLength))
+		return sql.NullString{}, false
+	}
+	return sql.NullString{String: name, Valid: true}, true
+}
+
+func (cfg *apiConfig) handlerGETSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r, loginOnly)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.db.ListSessions(r.Context(), userID)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting sessions from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve sessions")
+		return
+	}
+
+	sessions := make([]Session, len(rows))
+	for i, row := range rows {
+		sessions[i] = Session{
+			ID:         row.FamilyID,
+			DeviceName: row.DeviceName.String,
+			UserAgent:  row.UserAgent,
+			IPAddress:  row.IpAddress,
+			SignedInAt: row.SignedInAt,
+			LastUsedAt: row.LastUsedAt,
+			ExpiresAt:  row.ExpiresAt,
+		}
+		if row.ClientID.Valid {
+			sessions[i].ClientID = &row.ClientID.UUID
+		}
+	}
+	respondWithJSON(w, http.StatusOK, sessions)
+}
+
+func (cfg *apiConfig) handlerRevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r, loginOnly)
+	if !ok {
+		return
+	}
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "request error: not a valid session UUID")
+		return
+	}
+
+	// sessions of other users are reported as missing, not as forbidden, so as
+	// not to reveal that they exist
+	rowsAffected, err := cfg.db.RevokeSession(r.Context(), database.RevokeSessionParams{
+		FamilyID: sessionID,
+		UserID:   userID,
+	})
+	if err != nil {
+		log.Print(fmt.Errorf("%v revoking session in the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't revoke session")
+		return
+	}
+	if rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "session doesn't exist")
+		return
+	}
+
+	log.Printf("%v user %q revoked session %q", successTag, userID, sessionID)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r, loginOnly)
+	if !ok {
+		return
+	}
+
+	if err := cfg.db.RevokeAllSessions(r.Context(), userID); err != nil {
+		log.Print(fmt.Errorf("%v revoking sessions in the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't revoke sessions")
+		return
+	}
+
+	log.Printf("%v user %q logged out everywhere", successTag, userID)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/neira-daniel/go-chirpy/internal/auth"
+)
+
+// signingKeysReloadInterval is how often the keys listed in JWT_KEYS_FILE are
+// loaded again. Sending SIGHUP to the server reloads them right away.
+const signingKeysReloadInterval = time.Minute
+
+// jwksMaxAge is how long clients may cache the public keys we publish. Keys
+// should be listed in JWT_KEYS_FILE for at least this long before they become
+// active.
+const jwksMaxAge = 5 * time.Minute
+
+// loadSigningKeys returns the keys access tokens are signed and verified with.
+// When JWT_KEYS_FILE is set, those are the keys listed in that manifest, and
+// JWTSECRET, if also set, only verifies the tokens signed with it before the
+// switch. Otherwise, tokens are signed with JWTSECRET using HS256.
+func loadSigningKeys() (*auth.KeySet, error) {
+	secret := os.Getenv("JWTSECRET")
+	path := os.Getenv("JWT_KEYS_FILE")
+	if path == "" {
+		if secret == "" {
+			return nil, errors.New("neither JWT_KEYS_FILE nor JWTSECRET is set")
+		}
+		key, err := auth.NewHMACKey("", []byte(secret))
+		if err != nil {
+			return nil, err
+		}
+		return auth.NewKeySet(key)
+	}
+
+	var legacy []auth.Key
+	if secret != "" {
+		key, err := auth.NewHMACKey("", []byte(secret))
+		if err != nil {
+			return nil, err
+		}
+		legacy = append(legacy, key.VerifyOnly())
+	}
+	return auth.LoadKeySet(path, legacy...)
+}
+
+// loadValidator returns the validator of access tokens. The issuer, audiences,
+// signing algorithms and leeway it accepts can be set with JWT_ISSUER,
+// JWT_AUDIENCES, JWT_ALGORITHMS and JWT_LEEWAY, which default to those of the
+// tokens we sign. Lists are separated by commas.
+func loadValidator() (auth.Validator, error) {
+	validator := auth.DefaultValidator()
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		validator.Issuer = issuer
+	}
+	if audiences := os.Getenv("JWT_AUDIENCES"); audiences != "" {
+		validator.Audiences = splitList(audiences)
+	}
+	if algorithms := os.Getenv("JWT_ALGORITHMS"); algorithms != "" {
+		known := validator.Algorithms
+		validator.Algorithms = splitList(algorithms)
+		for _, algorithm := range validator.Algorithms {
+			if !slices.Contains(known, algorithm) {
+				return auth.Validator{}, fmt.Errorf("JWT_ALGORITHMS: unsupported algorithm %q", algorithm)
+			}
+		}
+	}
+	if leeway := os.Getenv("JWT_LEEWAY"); leeway != "" {
+		d, err := time.ParseDuration(leeway)
+		if err != nil {
+			return auth.Validator{}, fmt.Errorf("parsing JWT_LEEWAY: %w", err)
+		}
+		if d < 0 {
+			return auth.Validator{}, errors.New("JWT_LEEWAY can't be negative")
+		}
+		validator.Leeway = d
+	}
+	return validator, nil
+}
+
+// splitList returns the items of a list separated by commas, without the
+// spaces around them nor empty items.
+func splitList(list string) []string {
+	var items []string
+	for item := range strings.SplitSeq(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// watchSigningKeys reloads the signing keys periodically and on SIGHUP until
+// ctx is done, so that keys can be added to JWT_KEYS_FILE without restarting
+// the server. Failed reloads are logged and the previous keys stay in use.
+func (cfg *apiConfig) watchSigningKeys(ctx context.Context) {
+	if os.Getenv("JWT_KEYS_FILE") == "" {
+		return
+	}
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	ticker := time.NewTicker(signingKeysReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-hangups:
+			log.Print("reloading signing keys")
+		}
+		keys, err := loadSigningKeys()
+		if err != nil {
+			log.Print(fmt.Errorf("%v reloading signing keys: %w", errorTag, err))
+			continue
+		}
+		cfg.signingKeys.Store(keys)
+	}
+}
+
+// handlerJWKS publishes the public keys access tokens are signed with, so that
+// other services can verify them without sharing any secret.
+func (cfg *apiConfig) handlerJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(jwksMaxAge.Seconds())))
+	respondWithJSON(w, http.StatusOK, cfg.signingKeys.Load().JWKS(time.Now()))
+}
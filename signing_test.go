@@ -0,0 +1,74 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/neira-daniel/go-chirpy/internal/auth"
+)
+
+func TestLoadValidator(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    func(v auth.Validator) bool
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: func(v auth.Validator) bool {
+				defaults := auth.DefaultValidator()
+				return v.Issuer == defaults.Issuer &&
+					slices.Equal(v.Audiences, defaults.Audiences) &&
+					slices.Equal(v.Algorithms, defaults.Algorithms) &&
+					v.Leeway == defaults.Leeway
+			},
+		},
+		{
+			name: "everything set",
+			env: map[string]string{
+				"JWT_ISSUER":     "https://auth.example.com",
+				"JWT_AUDIENCES":  "chirpy-api, other-api,",
+				"JWT_ALGORITHMS": "RS256,EdDSA",
+				"JWT_LEEWAY":     "1m",
+			},
+			want: func(v auth.Validator) bool {
+				return v.Issuer == "https://auth.example.com" &&
+					slices.Equal(v.Audiences, []string{"chirpy-api", "other-api"}) &&
+					slices.Equal(v.Algorithms, []string{"RS256", "EdDSA"}) &&
+					v.Leeway == time.Minute
+			},
+		},
+		{
+			name:    "unsupported algorithm",
+			env:     map[string]string{"JWT_ALGORITHMS": "none"},
+			wantErr: true,
+		},
+		{
+			name:    "leeway without unit",
+			env:     map[string]string{"JWT_LEEWAY": "30"},
+			wantErr: true,
+		},
+		{
+			name:    "negative leeway",
+			env:     map[string]string{"JWT_LEEWAY": "-1s"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, name := range []string{"JWT_ISSUER", "JWT_AUDIENCES", "JWT_ALGORITHMS", "JWT_LEEWAY"} {
+				t.Setenv(name, test.env[name])
+			}
+			validator, err := loadValidator()
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v when expecting an error to be %v", err, test.wantErr)
+			}
+			if !test.wantErr && !test.want(validator) {
+				t.Errorf("got unexpected validator %+v", validator)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+-- name: SaveChirpRevision :exec
+INSERT INTO chirp_revisions (chirp_id, version, body, created_at)
+SELECT
+    sqlc.arg(chirp_id),
+    COUNT(*) + 1,
+    sqlc.arg(body),
+    sqlc.arg(created_at)
+FROM chirp_revisions
+WHERE chirp_id = sqlc.arg(chirp_id);
+
+-- name: GetChirpRevisions :many
+SELECT *
+FROM chirp_revisions
+WHERE chirp_id = $1
+ORDER BY version ASC;
@@ -0,0 +1,45 @@
+-- name: TagChirp :exec
+INSERT INTO chirp_tags (chirp_id, tag, created_at)
+SELECT sqlc.arg(chirp_id)::uuid, unnest(sqlc.arg(tags)::text[]), sqlc.arg(created_at)::timestamp
+ON CONFLICT DO NOTHING;
+
+-- name: UntagChirp :exec
+DELETE FROM chirp_tags
+WHERE chirp_id = $1;
+
+-- name: ListChirpsByTagAscending :many
+SELECT chirps.*
+FROM chirps
+JOIN chirp_tags ON chirp_tags.chirp_id = chirps.id
+WHERE chirp_tags.tag = sqlc.arg(tag)
+  AND (
+    sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid)
+  )
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListChirpsByTagDescending :many
+SELECT chirps.*
+FROM chirps
+JOIN chirp_tags ON chirp_tags.chirp_id = chirps.id
+WHERE chirp_tags.tag = sqlc.arg(tag)
+  AND (
+    sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid)
+  )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetTrendingTags :many
+SELECT
+    tag,
+    COUNT(*) AS uses,
+    SUM(
+        POWER(0.5::float8, EXTRACT(EPOCH FROM (now() AT TIME ZONE 'UTC' - created_at))::float8 / sqlc.arg(half_life_seconds)::float8)
+    )::float8 AS score
+FROM chirp_tags
+WHERE created_at >= now() AT TIME ZONE 'UTC' - sqlc.arg(window_seconds)::float8 * INTERVAL '1 second'
+GROUP BY tag
+ORDER BY score DESC, uses DESC, tag ASC
+LIMIT sqlc.arg(page_size);
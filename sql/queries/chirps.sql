@@ -1,29 +1,107 @@
 -- name: SaveChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (
-    gen_random_uuid(),
+WITH new_chirp AS (
+    SELECT gen_random_uuid() AS id
+)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, thread_id, rechirp_of, is_quote)
+SELECT
+    new_chirp.id,
     now() AT TIME ZONE 'UTC',
     now() AT TIME ZONE 'UTC',
-    $1,
-    $2
-)
+    sqlc.arg(body),
+    sqlc.arg(user_id),
+    sqlc.narg(in_reply_to),
+    COALESCE(sqlc.narg(thread_id)::uuid, new_chirp.id),
+    sqlc.narg(rechirp_of),
+    sqlc.arg(is_quote)
+FROM new_chirp
 RETURNING *;
 
--- name: GetChirps :many
+-- name: GetChirpByID :one
 SELECT *
 FROM chirps
-ORDER BY created_at ASC;
+WHERE id = $1;
 
--- name: GetChirpByID :one
+-- name: ListChirpsAscending :many
 SELECT *
 FROM chirps
-WHERE id = $1;
+WHERE (sqlc.narg(author_id)::uuid IS NULL OR user_id = sqlc.narg(author_id))
+  AND (
+    sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid)
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListChirpsDescending :many
+SELECT *
+FROM chirps
+WHERE (sqlc.narg(author_id)::uuid IS NULL OR user_id = sqlc.narg(author_id))
+  AND (
+    sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetThread :many
+SELECT *
+FROM chirps
+WHERE thread_id = $1
+ORDER BY created_at ASC, id ASC;
 
--- name: GetChirpsByAuthor :many
+-- name: GetChirpByIDForUpdate :one
 SELECT *
 FROM chirps
-WHERE user_id = $1;
+WHERE id = $1
+FOR UPDATE;
+
+-- name: EditChirp :one
+UPDATE chirps
+SET body = sqlc.arg(body),
+    updated_at = now() AT TIME ZONE 'UTC',
+    edited_at = now() AT TIME ZONE 'UTC'
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: GetChirpsByIDs :many
+SELECT *
+FROM chirps
+WHERE id = ANY(sqlc.arg(ids)::uuid[]);
 
 -- name: DeleteChirpByID :exec
 DELETE FROM chirps
-WHERE id = $1;
+WHERE id = $1
+   OR (rechirp_of = $1 AND NOT is_quote);
+
+-- name: GetRechirpCounts :many
+SELECT
+    rechirp_of::uuid AS chirp_id,
+    COUNT(*) FILTER (WHERE NOT is_quote) AS rechirp_count,
+    COUNT(*) FILTER (WHERE is_quote) AS quote_count
+FROM chirps
+WHERE rechirp_of = ANY(sqlc.arg(chirp_ids)::uuid[])
+GROUP BY rechirp_of;
+
+-- name: ListTimelineAscending :many
+SELECT chirps.*
+FROM chirps
+JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = sqlc.arg(user_id)
+  AND (
+    sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid)
+  )
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListTimelineDescending :many
+SELECT chirps.*
+FROM chirps
+JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = sqlc.arg(user_id)
+  AND (
+    sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid)
+  )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
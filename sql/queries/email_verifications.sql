@@ -0,0 +1,38 @@
+-- name: CreateEmailVerificationToken :exec
+INSERT INTO email_verification_tokens (token_hash, user_id, email, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    now() AT TIME ZONE 'UTC',
+    sqlc.arg(expires_at)
+);
+
+-- name: CountEmailVerificationTokensSince :one
+SELECT COUNT(*)
+FROM email_verification_tokens
+WHERE user_id = $1
+  AND created_at > sqlc.arg(since);
+
+-- name: UseEmailVerificationToken :one
+UPDATE email_verification_tokens
+SET used_at = now() AT TIME ZONE 'UTC'
+WHERE token_hash = $1
+  AND used_at IS NULL
+  AND expires_at > now() AT TIME ZONE 'UTC'
+RETURNING *;
+
+-- name: SetPendingEmail :exec
+UPDATE users
+SET updated_at = now() AT TIME ZONE 'UTC',
+    pending_email = sqlc.narg(pending_email)
+WHERE id = $1;
+
+-- name: VerifyEmail :one
+UPDATE users
+SET updated_at = now() AT TIME ZONE 'UTC',
+    email = sqlc.arg(email),
+    email_verified_at = now() AT TIME ZONE 'UTC',
+    pending_email = CASE WHEN pending_email = sqlc.arg(email) THEN NULL ELSE pending_email END
+WHERE id = sqlc.arg(id)
+RETURNING *;
@@ -0,0 +1,56 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (
+    $1,
+    $2,
+    now() AT TIME ZONE 'UTC'
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: ListFollowersAscending :many
+SELECT follower_id AS user_id, created_at
+FROM follows
+WHERE followee_id = sqlc.arg(user_id)
+  AND (
+    sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (created_at, follower_id) > (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid)
+  )
+ORDER BY created_at ASC, follower_id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListFollowersDescending :many
+SELECT follower_id AS user_id, created_at
+FROM follows
+WHERE followee_id = sqlc.arg(user_id)
+  AND (
+    sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (created_at, follower_id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid)
+  )
+ORDER BY created_at DESC, follower_id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListFollowingAscending :many
+SELECT followee_id AS user_id, created_at
+FROM follows
+WHERE follower_id = sqlc.arg(user_id)
+  AND (
+    sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (created_at, followee_id) > (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid)
+  )
+ORDER BY created_at ASC, followee_id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListFollowingDescending :many
+SELECT followee_id AS user_id, created_at
+FROM follows
+WHERE follower_id = sqlc.arg(user_id)
+  AND (
+    sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (created_at, followee_id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid)
+  )
+ORDER BY created_at DESC, followee_id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,47 @@
+-- name: LikeChirp :exec
+INSERT INTO likes (user_id, chirp_id, created_at)
+VALUES (
+    $1,
+    $2,
+    now() AT TIME ZONE 'UTC'
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnlikeChirp :execrows
+DELETE FROM likes
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetLikeCounts :many
+SELECT chirp_id, COUNT(*) AS like_count
+FROM likes
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+GROUP BY chirp_id;
+
+-- name: GetLikedChirpIDs :many
+SELECT chirp_id
+FROM likes
+WHERE user_id = sqlc.arg(user_id) AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: ListLikedChirpsAscending :many
+SELECT chirps.*, likes.created_at AS liked_at
+FROM chirps
+JOIN likes ON likes.chirp_id = chirps.id
+WHERE likes.user_id = sqlc.arg(user_id)
+  AND (
+    sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (likes.created_at, likes.chirp_id) > (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid)
+  )
+ORDER BY likes.created_at ASC, likes.chirp_id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListLikedChirpsDescending :many
+SELECT chirps.*, likes.created_at AS liked_at
+FROM chirps
+JOIN likes ON likes.chirp_id = chirps.id
+WHERE likes.user_id = sqlc.arg(user_id)
+  AND (
+    sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (likes.created_at, likes.chirp_id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid)
+  )
+ORDER BY likes.created_at DESC, likes.chirp_id DESC
+LIMIT sqlc.arg(page_size);
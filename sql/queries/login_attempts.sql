@@ -0,0 +1,32 @@
+-- name: GetLoginAttempt :one
+SELECT * FROM login_attempts
+WHERE key = $1;
+
+-- name: LockLoginAttempt :one
+SELECT * FROM login_attempts
+WHERE key = $1
+FOR UPDATE;
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_attempts (key, failures, last_failure_at)
+VALUES ($1, 1, sqlc.arg(now))
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_attempts.last_failure_at < sqlc.arg(forget_before) THEN 1
+        ELSE login_attempts.failures + 1
+    END,
+    last_failure_at = EXCLUDED.last_failure_at
+RETURNING *;
+
+-- name: ForgiveLoginFailure :exec
+UPDATE login_attempts
+SET failures = failures - 1
+WHERE key = $1 AND failures > 0;
+
+-- name: DeleteLoginAttempt :exec
+DELETE FROM login_attempts
+WHERE key = $1;
+
+-- name: DeleteStaleLoginAttempts :exec
+DELETE FROM login_attempts
+WHERE last_failure_at < $1;
@@ -0,0 +1,48 @@
+-- name: SaveMention :exec
+INSERT INTO mentions (chirp_id, user_id, start_offset, end_offset)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+);
+
+-- name: DeleteMentions :exec
+DELETE FROM mentions
+WHERE chirp_id = $1;
+
+-- name: GetMentionsByChirps :many
+SELECT *
+FROM mentions
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, start_offset;
+
+-- name: ListMentioningChirpsAscending :many
+SELECT *
+FROM chirps
+WHERE EXISTS (
+    SELECT 1
+    FROM mentions
+    WHERE mentions.chirp_id = chirps.id AND mentions.user_id = sqlc.arg(user_id)
+  )
+  AND (
+    sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid)
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListMentioningChirpsDescending :many
+SELECT *
+FROM chirps
+WHERE EXISTS (
+    SELECT 1
+    FROM mentions
+    WHERE mentions.chirp_id = chirps.id AND mentions.user_id = sqlc.arg(user_id)
+  )
+  AND (
+    sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,70 @@
+-- name: SetTOTPSecret :execrows
+UPDATE users
+SET updated_at = now() AT TIME ZONE 'UTC',
+    totp_secret = $2
+WHERE id = $1
+AND totp_enabled_at IS NULL;
+
+-- name: EnableTOTP :execrows
+UPDATE users
+SET updated_at = now() AT TIME ZONE 'UTC',
+    totp_enabled_at = now() AT TIME ZONE 'UTC',
+    totp_last_step = $2
+WHERE id = $1
+AND totp_secret IS NOT NULL
+AND totp_enabled_at IS NULL;
+
+-- name: UseTOTPStep :execrows
+UPDATE users
+SET totp_last_step = $2
+WHERE id = $1
+AND totp_last_step < $2;
+
+-- name: DisableTOTP :exec
+UPDATE users
+SET updated_at = now() AT TIME ZONE 'UTC',
+    totp_secret = NULL,
+    totp_enabled_at = NULL,
+    totp_last_step = 0
+WHERE id = $1;
+
+-- name: SaveRecoveryCode :exec
+INSERT INTO recovery_codes (user_id, code_hash, created_at)
+VALUES ($1, $2, now() AT TIME ZONE 'UTC');
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = now() AT TIME ZONE 'UTC'
+WHERE user_id = $1
+AND code_hash = $2
+AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = $1;
+
+-- name: CreateMFAChallenge :exec
+INSERT INTO mfa_challenges (token_hash, user_id, device_name, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    sqlc.narg(device_name),
+    now() AT TIME ZONE 'UTC',
+    sqlc.arg(expires_at)
+);
+
+-- name: AttemptMFAChallenge :one
+UPDATE mfa_challenges
+SET attempts = attempts + 1
+WHERE token_hash = $1
+AND attempts < sqlc.arg(max_attempts)::integer
+AND expires_at > now() AT TIME ZONE 'UTC'
+RETURNING *;
+
+-- name: DeleteMFAChallenge :exec
+DELETE FROM mfa_challenges
+WHERE token_hash = $1;
+
+-- name: DeleteExpiredMFAChallenges :exec
+DELETE FROM mfa_challenges
+WHERE expires_at <= now() AT TIME ZONE 'UTC';
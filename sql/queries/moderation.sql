@@ -0,0 +1,12 @@
+-- name: ListModerationWords :many
+SELECT word, action
+FROM moderation_words;
+
+-- name: FlagChirp :exec
+INSERT INTO chirp_flags (chirp_id, word, created_at)
+VALUES (
+    $1,
+    $2,
+    now() AT TIME ZONE 'UTC'
+)
+ON CONFLICT DO NOTHING;
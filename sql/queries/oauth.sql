@@ -0,0 +1,64 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, user_id, name, redirect_uris, secret_hash, created_at)
+VALUES (
+    gen_random_uuid(),
+    sqlc.arg(user_id),
+    sqlc.arg(name),
+    sqlc.arg(redirect_uris)::text[],
+    sqlc.narg(secret_hash),
+    now() AT TIME ZONE 'UTC'
+)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = $1;
+
+-- name: ListOAuthClients :many
+SELECT * FROM oauth_clients
+WHERE user_id = $1
+ORDER BY created_at DESC, id DESC;
+
+-- name: DeleteOAuthClient :execrows
+DELETE FROM oauth_clients
+WHERE id = $1
+AND user_id = $2;
+
+-- name: CreateAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (
+    code_hash, client_id, user_id, family_id, redirect_uri, scopes,
+    code_challenge, created_at, expires_at
+)
+VALUES (
+    sqlc.arg(code_hash),
+    sqlc.arg(client_id),
+    sqlc.arg(user_id),
+    sqlc.arg(family_id),
+    sqlc.arg(redirect_uri),
+    sqlc.arg(scopes)::text[],
+    sqlc.arg(code_challenge),
+    now() AT TIME ZONE 'UTC',
+    sqlc.arg(expires_at)
+);
+
+-- name: GetAuthorizationCode :one
+SELECT * FROM oauth_authorization_codes
+WHERE code_hash = $1;
+
+-- name: UseAuthorizationCode :execrows
+UPDATE oauth_authorization_codes
+SET used_at = now() AT TIME ZONE 'UTC'
+WHERE code_hash = $1
+AND used_at IS NULL;
+
+-- name: DeleteExpiredAuthorizationCodes :exec
+DELETE FROM oauth_authorization_codes
+WHERE expires_at < now() AT TIME ZONE 'UTC';
+
+-- name: IsTokenFamilyActive :one
+SELECT EXISTS (
+    SELECT 1 FROM refresh_tokens
+    WHERE family_id = $1
+    AND revoked_at IS NULL
+    AND expires_at > now() AT TIME ZONE 'UTC'
+);
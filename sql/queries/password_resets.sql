@@ -0,0 +1,37 @@
+-- name: CreatePasswordResetToken :exec
+INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    now() AT TIME ZONE 'UTC',
+    sqlc.arg(expires_at)
+);
+
+-- name: CountPasswordResetTokensSince :one
+SELECT COUNT(*)
+FROM password_reset_tokens
+WHERE user_id = $1
+  AND created_at > sqlc.arg(since);
+
+-- name: UsePasswordResetToken :one
+UPDATE password_reset_tokens
+SET used_at = now() AT TIME ZONE 'UTC'
+WHERE token_hash = $1
+  AND used_at IS NULL
+  AND expires_at > now() AT TIME ZONE 'UTC'
+RETURNING *;
+
+-- name: InvalidatePasswordResetTokens :exec
+UPDATE password_reset_tokens
+SET used_at = now() AT TIME ZONE 'UTC'
+WHERE user_id = $1
+  AND used_at IS NULL;
+
+-- name: ResetPassword :one
+UPDATE users
+SET updated_at = now() AT TIME ZONE 'UTC',
+    hashed_password = $2,
+    token_version = token_version + 1,
+    email_verified_at = COALESCE(email_verified_at, now() AT TIME ZONE 'UTC')
+WHERE id = $1
+RETURNING *;
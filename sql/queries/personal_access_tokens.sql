@@ -0,0 +1,35 @@
+-- name: CreatePersonalAccessToken :one
+INSERT INTO personal_access_tokens (id, user_id, name, token_hash, scopes, token_version, created_at, expires_at)
+SELECT
+    gen_random_uuid(),
+    users.id,
+    sqlc.arg(name),
+    sqlc.arg(token_hash),
+    sqlc.arg(scopes)::text[],
+    users.token_version,
+    now() AT TIME ZONE 'UTC',
+    sqlc.narg(expires_at)
+FROM users
+WHERE users.id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: GetPersonalAccessToken :one
+SELECT personal_access_tokens.*, users.token_version AS user_token_version
+FROM personal_access_tokens
+JOIN users ON users.id = personal_access_tokens.user_id
+WHERE personal_access_tokens.token_hash = $1;
+
+-- name: TouchPersonalAccessToken :exec
+UPDATE personal_access_tokens
+SET last_used_at = now() AT TIME ZONE 'UTC'
+WHERE id = $1;
+
+-- name: ListPersonalAccessTokens :many
+SELECT * FROM personal_access_tokens
+WHERE user_id = $1
+ORDER BY created_at DESC, id DESC;
+
+-- name: DeletePersonalAccessToken :execrows
+DELETE FROM personal_access_tokens
+WHERE id = $1
+AND user_id = $2;
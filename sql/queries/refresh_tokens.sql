@@ -1,20 +1,88 @@
 -- name: StoreRefreshToken :exec
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
+INSERT INTO refresh_tokens (
+    token_hash, created_at, updated_at, user_id, expires_at, family_id,
+    user_agent, ip_address, device_name, last_used_at, client_id, scopes
+)
 VALUES (
     $1,
     now() AT TIME ZONE 'UTC',
     now() AT TIME ZONE 'UTC',
     $2,
-    now() AT TIME ZONE 'UTC' + sqlc.arg(days)::integer * INTERVAL '1 day'
+    now() AT TIME ZONE 'UTC' + sqlc.arg(days)::integer * INTERVAL '1 day',
+    sqlc.arg(family_id),
+    sqlc.arg(user_agent),
+    sqlc.arg(ip_address),
+    sqlc.narg(device_name),
+    now() AT TIME ZONE 'UTC',
+    sqlc.narg(client_id),
+    sqlc.narg(scopes)::text[]
 );
 
 -- name: GetRefreshToken :one
 SELECT *
 FROM refresh_tokens
-WHERE token = $1;
+WHERE token_hash = $1;
 
 -- name: RevokeAccess :execresult
 UPDATE refresh_tokens
 SET updated_at = now() AT TIME ZONE 'UTC',
     revoked_at = now() AT TIME ZONE 'UTC'
-WHERE token = $1;
+WHERE token_hash = $1;
+
+-- name: RotateRefreshToken :execrows
+UPDATE refresh_tokens
+SET updated_at = now() AT TIME ZONE 'UTC',
+    revoked_at = now() AT TIME ZONE 'UTC',
+    rotated_at = now() AT TIME ZONE 'UTC'
+WHERE token_hash = $1
+  AND revoked_at IS NULL;
+
+-- name: RevokeTokenFamily :exec
+UPDATE refresh_tokens
+SET updated_at = now() AT TIME ZONE 'UTC',
+    revoked_at = now() AT TIME ZONE 'UTC'
+WHERE family_id = $1
+  AND revoked_at IS NULL;
+
+-- name: ListSessions :many
+SELECT
+    family_id,
+    (
+        SELECT MIN(family.created_at)
+        FROM refresh_tokens AS family
+        WHERE family.family_id = refresh_tokens.family_id
+    )::timestamp AS signed_in_at,
+    last_used_at,
+    expires_at,
+    user_agent,
+    ip_address,
+    device_name,
+    client_id
+FROM refresh_tokens
+WHERE user_id = $1
+  AND revoked_at IS NULL
+  AND expires_at > now() AT TIME ZONE 'UTC'
+ORDER BY last_used_at DESC, family_id;
+
+-- name: RevokeSession :execrows
+UPDATE refresh_tokens
+SET updated_at = now() AT TIME ZONE 'UTC',
+    revoked_at = now() AT TIME ZONE 'UTC'
+WHERE family_id = sqlc.arg(family_id)
+  AND user_id = sqlc.arg(user_id)
+  AND revoked_at IS NULL;
+
+-- name: RevokeAllSessions :exec
+UPDATE refresh_tokens
+SET updated_at = now() AT TIME ZONE 'UTC',
+    revoked_at = now() AT TIME ZONE 'UTC'
+WHERE user_id = $1
+  AND revoked_at IS NULL;
+
+-- name: RevokeOtherSessions :exec
+UPDATE refresh_tokens
+SET updated_at = now() AT TIME ZONE 'UTC',
+    revoked_at = now() AT TIME ZONE 'UTC'
+WHERE user_id = sqlc.arg(user_id)
+  AND family_id <> sqlc.arg(keep_family_id)
+  AND revoked_at IS NULL;
@@ -0,0 +1,12 @@
+-- name: SearchChirps :many
+SELECT chirps.*,
+    ts_rank_cd(to_tsvector('english', chirps.body), query)::real AS rank,
+    ts_headline('english', chirps.body, query, E'StartSel=\uE000, StopSel=\uE001, HighlightAll=true') AS snippet
+FROM chirps, to_tsquery('english', sqlc.arg(search_query)) AS query
+WHERE to_tsvector('english', chirps.body) @@ query
+  AND (sqlc.narg(author_id)::uuid IS NULL OR chirps.user_id = sqlc.narg(author_id))
+  AND (sqlc.narg(since)::timestamp IS NULL OR chirps.created_at >= sqlc.narg(since))
+  AND (sqlc.narg(until)::timestamp IS NULL OR chirps.created_at < sqlc.narg(until))
+ORDER BY rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size)
+OFFSET sqlc.arg(page_offset);
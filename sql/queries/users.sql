@@ -1,27 +1,54 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     gen_random_uuid(),
     now() AT TIME ZONE 'UTC',
     now() AT TIME ZONE 'UTC',
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
 -- name: GetUserByEmail :one
 SELECT * FROM users
 WHERE email = $1;
 
+-- name: GetUserTokenVersion :one
+SELECT token_version
+FROM users
+WHERE id = $1;
+
+-- name: GetUserTokenVersionAndRole :one
+SELECT token_version, role
+FROM users
+WHERE id = $1;
+
+-- name: GetUsersByHandles :many
+SELECT id, handle
+FROM users
+WHERE LOWER(handle) = ANY(sqlc.arg(handles)::text[]);
+
+-- name: RehashPassword :execrows
+UPDATE users
+SET hashed_password = sqlc.arg(new_hash)
+WHERE id = sqlc.arg(id)
+  AND hashed_password = sqlc.arg(old_hash);
+
 -- name: ResetDatabase :exec
 DELETE FROM users;
 
 -- name: UpdateCredentials :one
 UPDATE users
 SET updated_at = now() AT TIME ZONE 'UTC',
-    email = $1,
-    hashed_password = $2
-WHERE id = $3
+    hashed_password = sqlc.arg(hashed_password),
+    handle = COALESCE(sqlc.narg(handle), handle),
+    token_version = token_version + CASE WHEN sqlc.arg(revoke_tokens)::boolean THEN 1 ELSE 0 END
+WHERE id = sqlc.arg(id)
 RETURNING *;
 
 -- name: UpgradeUser :one
@@ -29,3 +56,21 @@ UPDATE users
 SET is_chirpy_red = true
 WHERE id = $1
 RETURNING *;
+
+-- name: SetUserRole :one
+UPDATE users
+SET updated_at = now() AT TIME ZONE 'UTC',
+    role = sqlc.arg(role)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: CountAdmins :one
+SELECT count(*)
+FROM users
+WHERE role = 'admin';
+
+-- name: LockAdmins :many
+SELECT id
+FROM users
+WHERE role = 'admin'
+FOR UPDATE;
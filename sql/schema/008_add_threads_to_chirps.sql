@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN in_reply_to UUID DEFAULT NULL REFERENCES chirps(id) ON DELETE SET NULL,
+ADD COLUMN thread_id UUID;
+
+UPDATE chirps
+SET thread_id = id;
+
+ALTER TABLE chirps
+ALTER COLUMN thread_id SET NOT NULL;
+
+CREATE INDEX chirps_in_reply_to_idx ON chirps (in_reply_to);
+CREATE INDEX chirps_thread_id_created_at_idx ON chirps (thread_id, created_at, id);
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN thread_id,
+DROP COLUMN in_reply_to;
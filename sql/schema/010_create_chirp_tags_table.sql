@@ -0,0 +1,29 @@
+-- +goose Up
+-- created_at mirrors the creation time of the chirp so that trending tags can
+-- be computed without reading the chirps table
+CREATE TABLE chirp_tags (
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  tag TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (chirp_id, tag)
+);
+
+CREATE INDEX chirp_tags_tag_created_at_idx ON chirp_tags (tag, created_at, chirp_id);
+CREATE INDEX chirp_tags_created_at_idx ON chirp_tags (created_at);
+
+-- chirps posted before hashtags were tracked get theirs the way
+-- chirptext.Hashtags finds them. Regular expressions have no class for
+-- combining marks, so bodies are composed first, which covers accented letters
+INSERT INTO chirp_tags (chirp_id, tag, created_at)
+SELECT DISTINCT chirps.id, normalize(lower(normalize(matches.groups[2], NFKC)), NFC), chirps.created_at
+FROM chirps
+CROSS JOIN LATERAL regexp_matches(
+  normalize(chirps.body, NFC),
+  '(^|[^[:alnum:]_#])#([[:alnum:]_]+)',
+  'g'
+) AS matches(groups)
+WHERE matches.groups[2] ~ '[[:alpha:]]'
+  AND char_length(matches.groups[2]) <= 100;
+
+-- +goose Down
+DROP TABLE chirp_tags;
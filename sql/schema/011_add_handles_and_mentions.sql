@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN handle TEXT DEFAULT NULL;
+
+CREATE UNIQUE INDEX users_handle_idx ON users (LOWER(handle));
+
+-- start_offset and end_offset delimit the @handle in the body of the chirp
+-- and are measured in Unicode code points
+CREATE TABLE mentions (
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  start_offset INTEGER NOT NULL,
+  end_offset INTEGER NOT NULL,
+  PRIMARY KEY (chirp_id, start_offset)
+);
+
+CREATE INDEX mentions_user_id_idx ON mentions (user_id);
+
+-- +goose Down
+DROP TABLE mentions;
+
+DROP INDEX users_handle_idx;
+
+ALTER TABLE users
+DROP COLUMN handle;
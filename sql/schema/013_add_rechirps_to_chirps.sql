@@ -0,0 +1,20 @@
+-- +goose Up
+-- plain rechirps have an empty body and are deleted along with the chirp they
+-- repost, while quote chirps outlive it with rechirp_of set to NULL. Bodies
+-- can't be unique anymore, as every plain rechirp has the same one
+ALTER TABLE chirps
+DROP CONSTRAINT chirps_body_key,
+ADD COLUMN rechirp_of UUID DEFAULT NULL REFERENCES chirps(id) ON DELETE SET NULL,
+ADD COLUMN is_quote BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX chirps_rechirp_of_idx ON chirps (rechirp_of);
+CREATE UNIQUE INDEX chirps_plain_rechirp_idx ON chirps (user_id, rechirp_of) WHERE NOT is_quote;
+
+-- +goose Down
+DELETE FROM chirps
+WHERE rechirp_of IS NOT NULL AND NOT is_quote;
+
+ALTER TABLE chirps
+DROP COLUMN is_quote,
+DROP COLUMN rechirp_of,
+ADD CONSTRAINT chirps_body_key UNIQUE (body);
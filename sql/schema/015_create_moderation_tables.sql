@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE moderation_words (
+  word TEXT PRIMARY KEY,
+  action TEXT NOT NULL DEFAULT 'mask' CHECK (action IN ('mask', 'flag', 'reject')),
+  created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'UTC')
+);
+
+-- the words that used to be hard-coded in the server
+INSERT INTO moderation_words (word, action)
+VALUES
+  ('kerfuffle', 'mask'),
+  ('sharbert', 'mask'),
+  ('fornax', 'mask');
+
+-- chirps with flagged words, waiting for someone to review them
+CREATE TABLE chirp_flags (
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  word TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (chirp_id, word)
+);
+
+CREATE INDEX chirp_flags_created_at_idx ON chirp_flags (created_at);
+
+-- +goose Down
+DROP TABLE chirp_flags;
+
+DROP TABLE moderation_words;
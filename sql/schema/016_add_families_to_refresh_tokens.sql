@@ -0,0 +1,20 @@
+-- +goose Up
+-- refresh tokens are single-use: refreshing rotates the token into a new one
+-- of the same family. rotated_at tells rotated tokens apart from revoked ones,
+-- as presenting a rotated token means that it was stolen
+ALTER TABLE refresh_tokens
+ADD COLUMN family_id UUID,
+ADD COLUMN rotated_at TIMESTAMP DEFAULT NULL;
+
+UPDATE refresh_tokens
+SET family_id = gen_random_uuid();
+
+ALTER TABLE refresh_tokens
+ALTER COLUMN family_id SET NOT NULL;
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+DROP COLUMN rotated_at,
+DROP COLUMN family_id;
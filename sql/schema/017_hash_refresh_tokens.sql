@@ -0,0 +1,15 @@
+-- +goose Up
+-- only the SHA-256 hash of refresh tokens is stored, hex-encoded. Existing
+-- tokens are hashed in place so that their sessions keep working
+ALTER TABLE refresh_tokens
+RENAME COLUMN token TO token_hash;
+
+UPDATE refresh_tokens
+SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+
+-- +goose Down
+-- hashes can't be turned back into tokens, so every session is logged out
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens
+RENAME COLUMN token_hash TO token;
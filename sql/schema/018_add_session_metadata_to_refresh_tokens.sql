@@ -0,0 +1,26 @@
+-- +goose Up
+-- a session is a family of refresh tokens, identified by family_id. Its
+-- metadata is copied into every token of the family, so the token that's
+-- still active describes the whole session
+ALTER TABLE refresh_tokens
+ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+ADD COLUMN ip_address TEXT NOT NULL DEFAULT '',
+ADD COLUMN device_name TEXT DEFAULT NULL,
+ADD COLUMN last_used_at TIMESTAMP;
+
+UPDATE refresh_tokens
+SET last_used_at = created_at;
+
+ALTER TABLE refresh_tokens
+ALTER COLUMN last_used_at SET NOT NULL;
+
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_user_id_idx;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN last_used_at,
+DROP COLUMN device_name,
+DROP COLUMN ip_address,
+DROP COLUMN user_agent;
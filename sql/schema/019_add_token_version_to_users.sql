@@ -0,0 +1,9 @@
+-- +goose Up
+-- access tokens carry the token version of their user when they were issued
+-- and stop being valid as soon as it's increased
+ALTER TABLE users
+ADD COLUMN token_version INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN token_version;
@@ -0,0 +1,36 @@
+-- +goose Up
+-- totp_secret is set when the user starts enrolling and totp_enabled_at once
+-- they confirm it with a code. totp_last_step is the time step of the last
+-- code used, so that no code is accepted twice
+ALTER TABLE users
+ADD COLUMN totp_secret TEXT DEFAULT NULL,
+ADD COLUMN totp_enabled_at TIMESTAMP DEFAULT NULL,
+ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE recovery_codes (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP DEFAULT NULL,
+    PRIMARY KEY (user_id, code_hash)
+);
+
+-- a login whose password was checked and that waits for the second factor
+CREATE TABLE mfa_challenges (
+    token_hash TEXT PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    device_name TEXT DEFAULT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE mfa_challenges;
+
+DROP TABLE recovery_codes;
+
+ALTER TABLE users
+DROP COLUMN totp_last_step,
+DROP COLUMN totp_enabled_at,
+DROP COLUMN totp_secret;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- like refresh tokens, reset tokens are stored as their SHA-256 hash
+CREATE TABLE password_reset_tokens (
+    token_hash TEXT PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX password_reset_tokens_user_id_idx ON password_reset_tokens (user_id, created_at);
+
+-- +goose Down
+DROP TABLE password_reset_tokens;
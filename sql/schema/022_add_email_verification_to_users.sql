@@ -0,0 +1,27 @@
+-- +goose Up
+-- email_verified_at is set once the user proves they own their email.
+-- pending_email is the email the user asked to change to, which replaces
+-- email once it's verified
+ALTER TABLE users
+ADD COLUMN email_verified_at TIMESTAMP DEFAULT NULL,
+ADD COLUMN pending_email TEXT DEFAULT NULL;
+
+-- email is the address the token was sent to, as the user may ask to change
+-- it again before following the link
+CREATE TABLE email_verification_tokens (
+    token_hash TEXT PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX email_verification_tokens_user_id_idx ON email_verification_tokens (user_id, created_at);
+
+-- +goose Down
+DROP TABLE email_verification_tokens;
+
+ALTER TABLE users
+DROP COLUMN pending_email,
+DROP COLUMN email_verified_at;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- failed logins in a row, by account or by client address, shared by every
+-- instance of the server. key is like "account:user@example.com" or
+-- "ip:192.0.2.1"
+CREATE TABLE login_attempts (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX login_attempts_last_failure_at_idx ON login_attempts (last_failure_at);
+
+-- +goose Down
+DROP TABLE login_attempts;
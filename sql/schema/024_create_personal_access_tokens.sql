@@ -0,0 +1,20 @@
+-- +goose Up
+-- token_version is the token version of the user when the token was created,
+-- so that changing credentials revokes personal access tokens along with
+-- every other access token
+CREATE TABLE personal_access_tokens (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,
+    token_version INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP DEFAULT NULL,
+    last_used_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE UNIQUE INDEX personal_access_tokens_user_id_name_idx ON personal_access_tokens (user_id, name);
+
+-- +goose Down
+DROP TABLE personal_access_tokens;
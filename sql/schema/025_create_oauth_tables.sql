@@ -0,0 +1,42 @@
+-- +goose Up
+-- OAuth clients are registered by users. Public clients, like native and
+-- browser apps, can't keep a secret, so they have no secret_hash
+CREATE TABLE oauth_clients (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    redirect_uris TEXT[] NOT NULL,
+    secret_hash TEXT DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX oauth_clients_user_id_idx ON oauth_clients (user_id);
+
+-- family_id is chosen when the code is issued and given to the refresh tokens
+-- it's exchanged for, so that they can be revoked when the code is reused
+CREATE TABLE oauth_authorization_codes (
+    code_hash TEXT PRIMARY KEY,
+    client_id UUID NOT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    family_id UUID NOT NULL,
+    redirect_uri TEXT NOT NULL,
+    scopes TEXT[] NOT NULL,
+    code_challenge TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP DEFAULT NULL
+);
+
+-- refresh tokens issued to OAuth clients are limited to the scopes the user
+-- granted. Those of a login have neither a client nor scopes
+ALTER TABLE refresh_tokens
+ADD COLUMN client_id UUID DEFAULT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+ADD COLUMN scopes TEXT[] DEFAULT NULL;
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+DROP COLUMN scopes,
+DROP COLUMN client_id;
+
+DROP TABLE oauth_authorization_codes;
+DROP TABLE oauth_clients;
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/neira-daniel/go-chirpy/internal/chirptext"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+)
+
+// trendingWindow is how far back we look for hashtags when computing what's
+// trending. Within the window, every use of a hashtag loses half its weight
+// each halfLife, so recent chirps count for more than older ones.
+type trendingWindow struct {
+	window   time.Duration
+	halfLife time.Duration
+}
+
+const defaultTrendingWindow = "day"
+
+var trendingWindows = map[string]trendingWindow{
+	"hour": {window: time.Hour, halfLife: 15 * time.Minute},
+	"day":  {window: 24 * time.Hour, halfLife: 6 * time.Hour},
+	"week": {window: 7 * 24 * time.Hour, halfLife: 24 * time.Hour},
+}
+
+type TrendingTag struct {
+	Tag   string  `json:"tag"`
+	Uses  int64   `json:"uses"`
+	Score float64 `json:"score"`
+}
+
+type TrendingTags struct {
+	Window string        `json:"window"`
+	Tags   []TrendingTag `json:"tags"`
+}
+
+func (cfg *apiConfig) handlerGETTagChirps(w http.ResponseWriter, r *http.Request) {
+	tag := chirptext.NormalizeHashtag(r.PathValue("tag"))
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "request error: missing hashtag")
+		return
+	}
+	page, err := parsePageRequest(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("request error: %v", err))
+		return
+	}
+
+	// chirps are listed newest first, so going back means reading upwards
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	var chirps []database.Chirp
+	if page.backward() {
+		chirps, err = cfg.db.ListChirpsByTagAscending(r.Context(), database.ListChirpsByTagAscendingParams{
+			Tag:             tag,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageSize:        page.fetchSize(),
+		})
+	} else {
+		chirps, err = cfg.db.ListChirpsByTagDescending(r.Context(), database.ListChirpsByTagDescendingParams{
+			Tag:             tag,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageSize:        page.fetchSize(),
+		})
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting chirps tagged %q from the database: %w", errorTag, tag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve chirps")
+		return
+	}
+
+	chirps, next, prev := paginate(chirps, page, chirpCursor)
+	chirpsWithTags, err := cfg.presentChirps(r.Context(), cfg.viewerID(r), chirps)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting chirp details from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve chirps")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, ChirpsPage{
+		Chirps:     chirpsWithTags,
+		Pagination: setPaginationLinks(w, r, page, next, prev),
+	})
+}
+
+func (cfg *apiConfig) handlerTrendingTags(w http.ResponseWriter, r *http.Request) {
+	windowName := r.URL.Query().Get("window")
+	if windowName == "" {
+		windowName = defaultTrendingWindow
+	}
+	window, ok := trendingWindows[windowName]
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "request error: window must be one of hour, day or week")
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("request error: %v", err))
+		return
+	}
+
+	rows, err := cfg.db.GetTrendingTags(r.Context(), database.GetTrendingTagsParams{
+		HalfLifeSeconds: window.halfLife.Seconds(),
+		WindowSeconds:   window.window.Seconds(),
+		PageSize:        int32(limit),
+	})
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting trending tags from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve trending tags")
+		return
+	}
+
+	tags := make([]TrendingTag, len(rows))
+	for i, row := range rows {
+		tags[i] = TrendingTag(row)
+	}
+	respondWithJSON(w, http.StatusOK, TrendingTags{Window: windowName, Tags: tags})
+}
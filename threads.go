@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// ThreadNode is a chirp within a conversation along with the replies it got.
+// Depth is 0 for the chirp that started the thread.
+type ThreadNode struct {
+	Chirp
+	Depth   int           `json:"depth"`
+	Replies []*ThreadNode `json:"replies"`
+}
+
+type Thread struct {
+	ThreadID uuid.UUID     `json:"thread_id"`
+	Chirps   []*ThreadNode `json:"chirps"`
+}
+
+// buildThread arranges the chirps of a thread into a tree. Replies whose
+// parent was deleted can't be attached anywhere, so they are returned at the
+// top level next to the root, as conversations of their own.
+func buildThread(chirps []Chirp) []*ThreadNode {
+	nodes := make(map[uuid.UUID]*ThreadNode, len(chirps))
+	for _, chirp := range chirps {
+		nodes[chirp.ID] = &ThreadNode{Chirp: chirp, Replies: []*ThreadNode{}}
+	}
+
+	// chirps come sorted by time of creation, and so will be the replies
+	roots := []*ThreadNode{}
+	for _, chirp := range chirps {
+		node := nodes[chirp.ID]
+		if chirp.InReplyTo != nil {
+			if parent, ok := nodes[*chirp.InReplyTo]; ok {
+				parent.Replies = append(parent.Replies, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	var setDepth func(node *ThreadNode, depth int)
+	setDepth = func(node *ThreadNode, depth int) {
+		node.Depth = depth
+		for _, reply := range node.Replies {
+			setDepth(reply, depth+1)
+		}
+	}
+	for _, root := range roots {
+		setDepth(root, 0)
+	}
+
+	return roots
+}
+
+func (cfg *apiConfig) handlerGETThread(w http.ResponseWriter, r *http.Request) {
+	chirp, ok := cfg.chirpFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	chirps, err := cfg.db.GetThread(r.Context(), chirp.ThreadID)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting thread %q from the database: %w", errorTag, chirp.ThreadID, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve thread")
+		return
+	}
+
+	chirpsWithTags, err := cfg.presentChirps(r.Context(), cfg.viewerID(r), chirps)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting chirp details from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve thread")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, Thread{
+		ThreadID: chirp.ThreadID,
+		Chirps:   buildThread(chirpsWithTags),
+	})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// threadChirp returns a chirp with an ID derived from n, replying to the one
+// derived from parent unless parent is 0.
+func threadChirp(n, parent byte) Chirp {
+	chirp := Chirp{ID: uuid.UUID{15: n}}
+	if parent != 0 {
+		inReplyTo := uuid.UUID{15: parent}
+		chirp.InReplyTo = &inReplyTo
+	}
+	return chirp
+}
+
+// threadShape is the part of a ThreadNode that buildThread decides.
+type threadShape struct {
+	id      byte
+	depth   int
+	replies []threadShape
+}
+
+func TestBuildThread(t *testing.T) {
+	tests := []struct {
+		name   string
+		chirps []Chirp
+		want   []threadShape
+	}{
+		{
+			name:   "no chirps",
+			chirps: []Chirp{},
+			want:   []threadShape{},
+		},
+		{
+			name:   "root only",
+			chirps: []Chirp{threadChirp(1, 0)},
+			want:   []threadShape{{id: 1}},
+		},
+		{
+			name: "nested replies keep their order",
+			chirps: []Chirp{
+				threadChirp(1, 0),
+				threadChirp(2, 1),
+				threadChirp(3, 2),
+				threadChirp(4, 1),
+				threadChirp(5, 3),
+			},
+			want: []threadShape{
+				{id: 1, replies: []threadShape{
+					{id: 2, depth: 1, replies: []threadShape{
+						{id: 3, depth: 2, replies: []threadShape{
+							{id: 5, depth: 3},
+						}},
+					}},
+					{id: 4, depth: 1},
+				}},
+			},
+		},
+		{
+			name: "reply to a deleted chirp becomes a root",
+			chirps: []Chirp{
+				threadChirp(1, 0),
+				threadChirp(3, 2),
+				threadChirp(4, 3),
+				threadChirp(5, 1),
+			},
+			want: []threadShape{
+				{id: 1, replies: []threadShape{
+					{id: 5, depth: 1},
+				}},
+				{id: 3, replies: []threadShape{
+					{id: 4, depth: 1},
+				}},
+			},
+		},
+		{
+			name: "deleted root",
+			chirps: []Chirp{
+				threadChirp(2, 1),
+				threadChirp(3, 1),
+				threadChirp(4, 2),
+			},
+			want: []threadShape{
+				{id: 2, replies: []threadShape{
+					{id: 4, depth: 1},
+				}},
+				{id: 3},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkThread(t, "roots", buildThread(test.chirps), test.want)
+		})
+	}
+}
+
+// checkThread compares the nodes of a thread to their expected shape, naming
+// them by path in errors.
+func checkThread(t *testing.T, path string, got []*ThreadNode, want []threadShape) {
+	t.Helper()
+	if got == nil {
+		t.Errorf("%v: got nil when expecting a list", path)
+		return
+	}
+	if len(got) != len(want) {
+		t.Errorf("%v: got %d nodes when expecting %d", path, len(got), len(want))
+		return
+	}
+	for i, node := range got {
+		id := uuid.UUID{15: want[i].id}
+		if node.ID != id {
+			t.Errorf("%v[%d]: got chirp %v when expecting %v", path, i, node.ID, id)
+			continue
+		}
+		if node.Depth != want[i].depth {
+			t.Errorf("%v[%d]: got depth %d when expecting %d", path, i, node.Depth, want[i].depth)
+		}
+		checkThread(t, node.ID.String(), node.Replies, want[i].replies)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/neira-daniel/go-chirpy/internal/auth"
+	"github.com/neira-daniel/go-chirpy/internal/database"
+)
+
+const (
+	// maxTokenNameLength is the longest name, in characters, users can give
+	// to a personal access token
+	maxTokenNameLength = 100
+	// maxTokenDays is the longest, in days, a personal access token can be
+	// set to last for. Tokens can also be set to never expire
+	maxTokenDays = 365
+	// tokenLastUsedPrecision is how often the last use of a personal access
+	// token is written down, so that busy scripts don't write on every request
+	tokenLastUsedPrecision = time.Minute
+)
+
+// errUnknownPersonalAccessToken is the error of personal access tokens that
+// don't exist or were deleted.
+var errUnknownPersonalAccessToken = errors.New("unknown personal access token")
+
+// PersonalAccessToken is a token users create for bots and scripts to call
+// the API on their behalf, limited to some scopes. The token itself is only
+// shown once, when it's created.
+type PersonalAccessToken struct {
+	ID         uuid.UUID    `json:"id"`
+	Name       string       `json:"name"`
+	Scopes     []auth.Scope `json:"scopes"`
+	Token      string       `json:"token,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ExpiresAt  *time.Time   `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time   `json:"last_used_at,omitempty"`
+}
+
+// scopeNames and storedScopes convert scopes to and from the names they're
+// stored as. Tokens without stored scopes aren't limited to scopes.
+func scopeNames(scopes []auth.Scope) []string {
+	if scopes == nil {
+		return nil
+	}
+	names := make([]string, len(scopes))
+	for i, scope := range scopes {
+		names[i] = string(scope)
+	}
+	return names
+}
+
+func storedScopes(names []string) []auth.Scope {
+	if names == nil {
+		return nil
+	}
+	scopes := make([]auth.Scope, len(names))
+	for i, name := range names {
+		scopes[i] = auth.Scope(name)
+	}
+	return scopes
+}
+
+func newPersonalAccessToken(row database.PersonalAccessToken) PersonalAccessToken {
+	token := PersonalAccessToken{
+		ID:        row.ID,
+		Name:      row.Name,
+		Scopes:    storedScopes(row.Scopes),
+		CreatedAt: row.CreatedAt,
+	}
+	if row.ExpiresAt.Valid {
+		token.ExpiresAt = &row.ExpiresAt.Time
+	}
+	if row.LastUsedAt.Valid {
+		token.LastUsedAt = &row.LastUsedAt.Time
+	}
+	return token
+}
+
+// validatePersonalAccessToken validates a personal access token, rejecting
+// those that expired or were created before the credentials of their user
+// last changed, and notes when it was used.
+func (cfg *apiConfig) validatePersonalAccessToken(ctx context.Context, tokenString string) (auth.AccessToken, error) {
+	row, err := cfg.db.GetPersonalAccessToken(ctx, auth.HashRefreshToken(tokenString))
+	if errors.Is(err, sql.ErrNoRows) {
+		return auth.AccessToken{}, errUnknownPersonalAccessToken
+	}
+	if err != nil {
+		return auth.AccessToken{}, fmt.Errorf("getting personal access token: %w", err)
+	}
+	now := time.Now().UTC()
+	if row.ExpiresAt.Valid && !now.Before(row.ExpiresAt.Time) {
+		return auth.AccessToken{}, auth.ErrTokenExpired
+	}
+	if row.TokenVersion != row.UserTokenVersion {
+		return auth.AccessToken{}, auth.ErrTokenRevoked
+	}
+
+	if !row.LastUsedAt.Valid || now.Sub(row.LastUsedAt.Time) >= tokenLastUsedPrecision {
+		if err := cfg.db.TouchPersonalAccessToken(ctx, row.ID); err != nil {
+			log.Print(fmt.Errorf("%v noting use of personal access token %q: %w", warningTag, row.ID, err))
+		}
+	}
+
+	return auth.AccessToken{
+		UserID:       row.UserID,
+		TokenVersion: row.TokenVersion,
+		Scopes:       storedScopes(row.Scopes),
+	}, nil
+}
+
+func (cfg *apiConfig) handlerCreatePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r, loginOnly)
+	if !ok {
+		return
+	}
+
+	type payload struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays *int32   `json:"expires_in_days"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	var data payload
+	if err := decoder.Decode(&data); err != nil {
+		log.Print(fmt.Errorf("%v decoding non-conforming JSON request: %w", errorTag, err))
+		respondWithError(w, http.StatusBadRequest, "non-conforming JSON received")
+		return
+	}
+
+	fields := map[string][]string{}
+	if data.Name == "" || utf8.RuneCountInString(data.Name) > maxTokenNameLength {
+		fields["name"] = []string{fmt.Sprintf("must be 1 to %d characters long", maxTokenNameLength)}
+	}
+	scopes, err := auth.ParseScopes(data.Scopes)
+	if err != nil {
+		fields["scopes"] = []string{err.Error()}
+	}
+	var expiresAt sql.NullTime
+	if data.ExpiresInDays != nil {
+		if *data.ExpiresInDays < 1 || *data.ExpiresInDays > maxTokenDays {
+			fields["expires_in_days"] = []string{fmt.Sprintf("must be between 1 and %d", maxTokenDays)}
+		}
+		expiresAt = sql.NullTime{Time: time.Now().UTC().AddDate(0, 0, int(*data.ExpiresInDays)), Valid: true}
+	}
+	if len(fields) > 0 {
+		respondWithFieldErrors(w, http.StatusBadRequest, "request error: invalid personal access token", fields)
+		return
+	}
+
+	tokenString := auth.MakePersonalAccessToken()
+	row, err := cfg.db.CreatePersonalAccessToken(r.Context(), database.CreatePersonalAccessTokenParams{
+		Name:      data.Name,
+		TokenHash: auth.HashRefreshToken(tokenString),
+		Scopes:    scopeNames(scopes),
+		ExpiresAt: expiresAt,
+		UserID:    userID,
+	})
+	if isUniqueViolation(err, "personal_access_tokens_user_id_name_idx") {
+		respondWithError(w, http.StatusConflict, "a personal access token with that name already exists")
+		return
+	}
+	if err != nil {
+		log.Print(fmt.Errorf("%v storing personal access token: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't store personal access token")
+		return
+	}
+
+	log.Printf("%v user %q created personal access token %q with scopes %v", securityTag, userID, row.ID, scopes)
+	token := newPersonalAccessToken(row)
+	token.Token = tokenString
+	respondWithJSON(w, http.StatusCreated, token)
+}
+
+func (cfg *apiConfig) handlerGETPersonalAccessTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r, loginOnly)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.db.ListPersonalAccessTokens(r.Context(), userID)
+	if err != nil {
+		log.Print(fmt.Errorf("%v getting personal access tokens from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't retrieve personal access tokens")
+		return
+	}
+
+	tokens := make([]PersonalAccessToken, len(rows))
+	for i, row := range rows {
+		tokens[i] = newPersonalAccessToken(row)
+	}
+	respondWithJSON(w, http.StatusOK, tokens)
+}
+
+func (cfg *apiConfig) handlerRevokePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r, loginOnly)
+	if !ok {
+		return
+	}
+	tokenID, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "request error: not a valid token UUID")
+		return
+	}
+
+	// tokens of other users are reported as missing, not as forbidden, so as
+	// not to reveal that they exist
+	rowsAffected, err := cfg.db.DeletePersonalAccessToken(r.Context(), database.DeletePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err != nil {
+		log.Print(fmt.Errorf("%v deleting personal access token from the database: %w", errorTag, err))
+		respondWithError(w, http.StatusInternalServerError, "database error: couldn't revoke personal access token")
+		return
+	}
+	if rowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "personal access token doesn't exist")
+		return
+	}
+
+	log.Printf("%v user %q revoked personal access token %q", securityTag, userID, tokenID)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusNoContent)
+}